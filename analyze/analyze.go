@@ -0,0 +1,65 @@
+package analyze
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/magodo/azlist/azlist"
+)
+
+// Finding is an issue found by an analyzer on a resource.
+type Finding struct {
+	Analyzer string `json:"analyzer"`
+	Id       string `json:"id"`
+	Message  string `json:"message"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("[%s] %s: %s", f.Analyzer, f.Id, f.Message)
+}
+
+type Analyzer struct {
+	Name        string
+	Description string
+	Analyze     func(result *azlist.ListResult) []Finding
+}
+
+// Analyzers are the builtin analyzers, keyed by their names.
+var Analyzers = map[string]Analyzer{}
+
+func register(a Analyzer) {
+	Analyzers[a.Name] = a
+}
+
+// Names returns the sorted names of the builtin analyzers.
+func Names() []string {
+	var names []string
+	for name := range Analyzers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run runs the named analyzers (all if none is specified) against the list result, and returns the findings ordered by the analyzer and the resource id.
+func Run(result *azlist.ListResult, names ...string) ([]Finding, error) {
+	if len(names) == 0 {
+		names = Names()
+	}
+	var findings []Finding
+	for _, name := range names {
+		a, ok := Analyzers[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown analyzer %q", name)
+		}
+		findings = append(findings, a.Analyze(result)...)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Analyzer != findings[j].Analyzer {
+			return findings[i].Analyzer < findings[j].Analyzer
+		}
+		return strings.ToUpper(findings[i].Id) < strings.ToUpper(findings[j].Id)
+	})
+	return findings, nil
+}
@@ -0,0 +1,95 @@
+package analyze
+
+import (
+	"testing"
+
+	"github.com/magodo/armid"
+	"github.com/magodo/azlist/azlist"
+	"github.com/stretchr/testify/require"
+)
+
+func newResource(t *testing.T, id string, props map[string]interface{}) azlist.AzureResource {
+	azureId, err := armid.ParseResourceId(id)
+	require.NoError(t, err)
+	return azlist.AzureResource{Id: azureId, Properties: props}
+}
+
+func TestZoneRedundancy(t *testing.T) {
+	resource := func(id string, props map[string]interface{}) azlist.AzureResource {
+		return newResource(t, id, props)
+	}
+	result := &azlist.ListResult{
+		Resources: []azlist.AzureResource{
+			resource("/subscriptions/0000/resourceGroups/rg1/providers/Microsoft.Compute/virtualMachines/vm1", map[string]interface{}{"location": "westeurope"}),
+			resource("/subscriptions/0000/resourceGroups/rg1/providers/Microsoft.Compute/virtualMachines/vm2", map[string]interface{}{"location": "westeurope", "zones": []interface{}{"1"}}),
+			resource("/subscriptions/0000/resourceGroups/rg1/providers/Microsoft.Compute/virtualMachines/vm3", map[string]interface{}{"location": "westeurope", "zones": []interface{}{"1", "2"}}),
+			resource("/subscriptions/0000/resourceGroups/rg1/providers/Microsoft.Compute/virtualMachines/vm4", map[string]interface{}{"location": "westcentralus"}),
+			resource("/subscriptions/0000/resourceGroups/rg1/providers/Microsoft.Storage/storageAccounts/sa1", map[string]interface{}{"location": "westeurope", "sku": map[string]interface{}{"name": "Standard_LRS"}}),
+			resource("/subscriptions/0000/resourceGroups/rg1/providers/Microsoft.Storage/storageAccounts/sa2", map[string]interface{}{"location": "westeurope", "sku": map[string]interface{}{"name": "Standard_GZRS"}}),
+		},
+	}
+	findings, err := Run(result, "zone-redundancy")
+	require.NoError(t, err)
+	require.Equal(t, []Finding{
+		{Analyzer: "zone-redundancy", Id: "/subscriptions/0000/resourceGroups/rg1/providers/Microsoft.Compute/virtualMachines/vm1", Message: "deployed non-zonally in westeurope"},
+		{Analyzer: "zone-redundancy", Id: "/subscriptions/0000/resourceGroups/rg1/providers/Microsoft.Compute/virtualMachines/vm2", Message: "deployed to a single zone (1) in westeurope"},
+		{Analyzer: "zone-redundancy", Id: "/subscriptions/0000/resourceGroups/rg1/providers/Microsoft.Storage/storageAccounts/sa1", Message: "SKU Standard_LRS is not zone redundant in westeurope"},
+	}, findings)
+}
+
+func TestPrivateDNS(t *testing.T) {
+	const (
+		rg       = "/subscriptions/0000/resourceGroups/rg1"
+		blobZone = rg + "/providers/Microsoft.Network/privateDnsZones/privatelink.blob.core.windows.net"
+		vnet1    = rg + "/providers/Microsoft.Network/virtualNetworks/vnet1"
+		vnet2    = rg + "/providers/Microsoft.Network/virtualNetworks/vnet2"
+	)
+	endpoint := func(name, subnetId, groupId string) azlist.AzureResource {
+		return newResource(t, rg+"/providers/Microsoft.Network/privateEndpoints/"+name, map[string]interface{}{
+			"properties": map[string]interface{}{
+				"subnet": map[string]interface{}{"id": subnetId},
+				"privateLinkServiceConnections": []interface{}{
+					map[string]interface{}{"properties": map[string]interface{}{"groupIds": []interface{}{groupId}}},
+				},
+			},
+		})
+	}
+	zoneGroup := func(endpoint, zoneId string) azlist.AzureResource {
+		return newResource(t, rg+"/providers/Microsoft.Network/privateEndpoints/"+endpoint+"/privateDnsZoneGroups/default", map[string]interface{}{
+			"properties": map[string]interface{}{
+				"privateDnsZoneConfigs": []interface{}{
+					map[string]interface{}{"properties": map[string]interface{}{"privateDnsZoneId": zoneId}},
+				},
+			},
+		})
+	}
+	result := &azlist.ListResult{
+		Resources: []azlist.AzureResource{
+			newResource(t, blobZone, map[string]interface{}{}),
+			newResource(t, blobZone+"/virtualNetworkLinks/link1", map[string]interface{}{
+				"properties": map[string]interface{}{"virtualNetwork": map[string]interface{}{"id": vnet2}},
+			}),
+			// The zone is not linked to the virtual network of the endpoint.
+			endpoint("pe1", vnet1+"/subnets/default", "blob"),
+			zoneGroup("pe1", blobZone),
+			// The zone id is malformed.
+			endpoint("pe2", vnet1+"/subnets/default", "vault"),
+			zoneGroup("pe2", rg),
+			// No zone group.
+			endpoint("pe3", vnet1+"/subnets/default", "blob"),
+			// The subnet id is malformed, whose zone links are not checked.
+			endpoint("pe4", rg, "blob"),
+			zoneGroup("pe4", blobZone),
+			// The zone is linked to the virtual network of the endpoint.
+			endpoint("pe5", vnet2+"/subnets/default", "blob"),
+			zoneGroup("pe5", blobZone),
+		},
+	}
+	findings, err := Run(result, "private-dns")
+	require.NoError(t, err)
+	require.Equal(t, []Finding{
+		{Analyzer: "private-dns", Id: rg + "/providers/Microsoft.Network/privateEndpoints/pe1", Message: "private DNS zone " + blobZone + " is not linked to the virtual network " + vnet1},
+		{Analyzer: "private-dns", Id: rg + "/providers/Microsoft.Network/privateEndpoints/pe2", Message: `sub-resource "vault" expects private DNS zone "privatelink.vaultcore.azure.net", which is not in the zone groups`},
+		{Analyzer: "private-dns", Id: rg + "/providers/Microsoft.Network/privateEndpoints/pe3", Message: "no private DNS zone group"},
+	}, findings)
+}
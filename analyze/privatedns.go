@@ -0,0 +1,138 @@
+package analyze
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/magodo/armid"
+	"github.com/magodo/azlist/azlist"
+)
+
+func init() {
+	register(Analyzer{
+		Name:        "private-dns",
+		Description: "Private endpoints whose private DNS zones are missing, unexpected, or not linked to the virtual network of the private endpoint",
+		Analyze:     analyzePrivateDNS,
+	})
+}
+
+// privateDNSZones maps the lower cased private link sub-resource names (i.e. group ids) to the expected private DNS zones in the public cloud.
+var privateDNSZones = map[string]string{
+	"blob":                "privatelink.blob.core.windows.net",
+	"blob_secondary":      "privatelink.blob.core.windows.net",
+	"file":                "privatelink.file.core.windows.net",
+	"queue":               "privatelink.queue.core.windows.net",
+	"table":               "privatelink.table.core.windows.net",
+	"web":                 "privatelink.web.core.windows.net",
+	"dfs":                 "privatelink.dfs.core.windows.net",
+	"vault":               "privatelink.vaultcore.azure.net",
+	"sqlserver":           "privatelink.database.windows.net",
+	"registry":            "privatelink.azurecr.io",
+	"sql":                 "privatelink.documents.azure.com",
+	"sites":               "privatelink.azurewebsites.net",
+	"namespace":           "privatelink.servicebus.windows.net",
+	"configurationstores": "privatelink.azconfig.io",
+	"rediscache":          "privatelink.redis.cache.windows.net",
+	"searchservice":       "privatelink.search.windows.net",
+}
+
+// analyzePrivateDNS cross references the private endpoints, their DNS zone groups, and the private DNS zones with their virtual network links.
+// The zone groups and virtual network links are child resources, hence only available when listed recursively.
+// The links of a zone are only checked when the zone itself is listed.
+func analyzePrivateDNS(result *azlist.ListResult) []Finding {
+	const name = "private-dns"
+
+	var (
+		endpoints  []azlist.AzureResource
+		zoneGroups = map[string][]azlist.AzureResource{}
+		zones      = map[string]bool{}
+		vnetLinks  = map[string]map[string]bool{}
+	)
+	for _, res := range result.Resources {
+		switch strings.ToUpper(azlist.ResourceType(res.Id)) {
+		case "MICROSOFT.NETWORK/PRIVATEENDPOINTS":
+			endpoints = append(endpoints, res)
+		case "MICROSOFT.NETWORK/PRIVATEENDPOINTS/PRIVATEDNSZONEGROUPS":
+			key := strings.ToUpper(res.Id.Parent().String())
+			zoneGroups[key] = append(zoneGroups[key], res)
+		case "MICROSOFT.NETWORK/PRIVATEDNSZONES":
+			zones[strings.ToUpper(res.Id.String())] = true
+		case "MICROSOFT.NETWORK/PRIVATEDNSZONES/VIRTUALNETWORKLINKS":
+			key := strings.ToUpper(res.Id.Parent().String())
+			if vnetLinks[key] == nil {
+				vnetLinks[key] = map[string]bool{}
+			}
+			vnetId := fmt.Sprint(azlist.LookupPath(res.Properties, "properties.virtualNetwork.id"))
+			vnetLinks[key][strings.ToUpper(vnetId)] = true
+		}
+	}
+
+	var findings []Finding
+	for _, pe := range endpoints {
+		report := func(format string, a ...interface{}) {
+			findings = append(findings, Finding{Analyzer: name, Id: pe.Id.String(), Message: fmt.Sprintf(format, a...)})
+		}
+
+		groups := zoneGroups[strings.ToUpper(pe.Id.String())]
+		if len(groups) == 0 {
+			report("no private DNS zone group")
+			continue
+		}
+
+		var zoneIds []string
+		zoneNames := map[string]bool{}
+		for _, group := range groups {
+			configs, _ := azlist.LookupPath(group.Properties, "properties.privateDnsZoneConfigs").([]interface{})
+			for _, config := range configs {
+				config, _ := config.(map[string]interface{})
+				zoneId, ok := azlist.LookupPath(config, "properties.privateDnsZoneId").(string)
+				if !ok {
+					continue
+				}
+				zoneIds = append(zoneIds, zoneId)
+				// The zone id might be malformed, e.g. a resource group id, which has no zone name.
+				if id, err := armid.ParseResourceId(zoneId); err == nil && strings.EqualFold(azlist.ResourceType(id), "Microsoft.Network/privateDnsZones") {
+					zoneNames[strings.ToLower(id.Names()[0])] = true
+				}
+			}
+		}
+
+		for _, groupId := range endpointGroupIds(pe) {
+			if zone, ok := privateDNSZones[strings.ToLower(groupId)]; ok && !zoneNames[zone] {
+				report("sub-resource %q expects private DNS zone %q, which is not in the zone groups", groupId, zone)
+			}
+		}
+
+		subnetId, _ := azlist.LookupPath(pe.Properties, "properties.subnet.id").(string)
+		subnet, err := armid.ParseResourceId(subnetId)
+		if err != nil || !strings.EqualFold(azlist.ResourceType(subnet), "Microsoft.Network/virtualNetworks/subnets") {
+			continue
+		}
+		vnetId := strings.ToUpper(subnet.Parent().String())
+		for _, zoneId := range zoneIds {
+			if !zones[strings.ToUpper(zoneId)] {
+				continue
+			}
+			if !vnetLinks[strings.ToUpper(zoneId)][vnetId] {
+				report("private DNS zone %s is not linked to the virtual network %s", zoneId, subnet.Parent().String())
+			}
+		}
+	}
+	return findings
+}
+
+// endpointGroupIds returns the private link sub-resource names of the private endpoint, including the manual connections.
+func endpointGroupIds(pe azlist.AzureResource) []string {
+	var out []string
+	for _, path := range []string{"properties.privateLinkServiceConnections", "properties.manualPrivateLinkServiceConnections"} {
+		conns, _ := azlist.LookupPath(pe.Properties, path).([]interface{})
+		for _, conn := range conns {
+			conn, _ := conn.(map[string]interface{})
+			groupIds, _ := azlist.LookupPath(conn, "properties.groupIds").([]interface{})
+			for _, groupId := range groupIds {
+				out = append(out, fmt.Sprint(groupId))
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,99 @@
+package analyze
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/magodo/azlist/azlist"
+)
+
+func init() {
+	register(Analyzer{
+		Name:        "zone-redundancy",
+		Description: "Resources deployed non-zonally, or to a single zone, in regions that support availability zones",
+		Analyze:     analyzeZoneRedundancy,
+	})
+}
+
+// azRegions are the regions that support availability zones.
+var azRegions = map[string]bool{
+	"australiaeast": true, "brazilsouth": true, "canadacentral": true, "centralindia": true, "centralus": true,
+	"eastasia": true, "eastus": true, "eastus2": true, "francecentral": true, "germanywestcentral": true,
+	"israelcentral": true, "italynorth": true, "japaneast": true, "koreacentral": true, "mexicocentral": true,
+	"northeurope": true, "norwayeast": true, "polandcentral": true, "qatarcentral": true, "southafricanorth": true,
+	"southcentralus": true, "southeastasia": true, "spaincentral": true, "swedencentral": true, "switzerlandnorth": true,
+	"uaenorth": true, "uksouth": true, "westeurope": true, "westus2": true, "westus3": true,
+	"chinanorth3": true, "usgovvirginia": true,
+}
+
+// zonalTypes are the upper cased resource types whose zones are specified by the "zones" field.
+var zonalTypes = map[string]bool{
+	"MICROSOFT.COMPUTE/VIRTUALMACHINES":         true,
+	"MICROSOFT.COMPUTE/VIRTUALMACHINESCALESETS": true,
+	"MICROSOFT.COMPUTE/DISKS":                   true,
+	"MICROSOFT.NETWORK/PUBLICIPADDRESSES":       true,
+	"MICROSOFT.NETWORK/APPLICATIONGATEWAYS":     true,
+	"MICROSOFT.NETWORK/AZUREFIREWALLS":          true,
+	"MICROSOFT.CACHE/REDIS":                     true,
+}
+
+// zoneRedundantPaths maps the upper cased resource types to the dotted path of their boolean zone redundancy property.
+var zoneRedundantPaths = map[string]string{
+	"MICROSOFT.SQL/SERVERS/DATABASES": "properties.zoneRedundant",
+	"MICROSOFT.WEB/SERVERFARMS":       "properties.zoneRedundant",
+	"MICROSOFT.EVENTHUB/NAMESPACES":   "properties.zoneRedundant",
+	"MICROSOFT.SERVICEBUS/NAMESPACES": "properties.zoneRedundant",
+}
+
+// analyzeZoneRedundancy checks the zone settings already present in the resource bodies, for the resources located in the regions supporting availability zones.
+func analyzeZoneRedundancy(result *azlist.ListResult) []Finding {
+	const name = "zone-redundancy"
+
+	var findings []Finding
+	for _, res := range result.Resources {
+		location, _ := res.Properties["location"].(string)
+		if !azRegions[strings.ToLower(strings.ReplaceAll(location, " ", ""))] {
+			continue
+		}
+		report := func(format string, a ...interface{}) {
+			findings = append(findings, Finding{Analyzer: name, Id: res.Id.String(), Message: fmt.Sprintf(format, a...)})
+		}
+
+		rt := strings.ToUpper(azlist.ResourceType(res.Id))
+		switch {
+		case zonalTypes[rt]:
+			if msg := zonesMessage(res.Properties["zones"]); msg != "" {
+				report("%s in %s", msg, location)
+			}
+		case zoneRedundantPaths[rt] != "":
+			if v, _ := azlist.LookupPath(res.Properties, zoneRedundantPaths[rt]).(bool); !v {
+				report("not zone redundant in %s", location)
+			}
+		case rt == "MICROSOFT.STORAGE/STORAGEACCOUNTS":
+			if sku, _ := azlist.LookupPath(res.Properties, "sku.name").(string); !strings.Contains(strings.ToUpper(sku), "ZRS") {
+				report("SKU %s is not zone redundant in %s", sku, location)
+			}
+		case rt == "MICROSOFT.CONTAINERSERVICE/MANAGEDCLUSTERS":
+			pools, _ := azlist.LookupPath(res.Properties, "properties.agentPoolProfiles").([]interface{})
+			for _, pool := range pools {
+				pool, _ := pool.(map[string]interface{})
+				if msg := zonesMessage(pool["availabilityZones"]); msg != "" {
+					report("agent pool %v %s in %s", pool["name"], msg, location)
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// zonesMessage returns the issue of the zones value, or empty string if it spans multiple zones.
+func zonesMessage(v interface{}) string {
+	zones, _ := v.([]interface{})
+	switch len(zones) {
+	case 0:
+		return "deployed non-zonally"
+	case 1:
+		return fmt.Sprintf("deployed to a single zone (%v)", zones[0])
+	}
+	return ""
+}
@@ -3,7 +3,7 @@ package armresources
 import (
 	"context"
 	"net/http"
-	"strings"
+	"net/url"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
@@ -17,6 +17,9 @@ type Client struct {
 	host           string
 	subscriptionID string
 	pl             runtime.Pipeline
+
+	// listURLTemplates maps the upper cased resource types to the URL path templates of their collections. Defaults to DefaultListURLTemplates.
+	listURLTemplates map[string]string
 }
 
 // NewClient creates a new instance of Client with the specified values.
@@ -45,7 +48,8 @@ func NewClient(subscriptionID string, credential azcore.TokenCredential, options
 
 // NewListChildPager - Get all the child resources under a given resource.
 // If the operation fails it returns an *azcore.ResponseError type.
-func (client *Client) NewListChildPager(resourceID, resourceType, apiVersion string) *runtime.Pager[ClientListResponse] {
+// options - ClientListChildOptions contains the optional parameters for the Client.NewListChildPager method.
+func (client *Client) NewListChildPager(resourceID, resourceType, apiVersion string, options *ClientListChildOptions) *runtime.Pager[ClientListResponse] {
 	return runtime.NewPager(runtime.PagingHandler[ClientListResponse]{
 		More: func(page ClientListResponse) bool {
 			return page.NextLink != nil && len(*page.NextLink) > 0
@@ -53,8 +57,10 @@ func (client *Client) NewListChildPager(resourceID, resourceType, apiVersion str
 		Fetcher: func(ctx context.Context, page *ClientListResponse) (ClientListResponse, error) {
 			var req *policy.Request
 			var err error
+			// Only the first page of a singleton template can be the singleton itself.
+			var singleton bool
 			if page == nil {
-				req, err = client.listChildCreateRequest(ctx, resourceID, resourceType, apiVersion)
+				req, singleton, err = client.listChildCreateRequest(ctx, resourceID, resourceType, apiVersion, options)
 			} else {
 				req, err = runtime.NewRequest(ctx, http.MethodGet, *page.NextLink)
 			}
@@ -68,32 +74,138 @@ func (client *Client) NewListChildPager(resourceID, resourceType, apiVersion str
 			if !runtime.HasStatusCode(resp, http.StatusOK) {
 				return ClientListResponse{}, runtime.NewResponseError(resp)
 			}
-			return client.listChildHandleResponse(resp)
+			return client.listChildHandleResponse(resp, singleton)
 		},
 	})
 }
 
-// listChildCreateRequest creates the ListChild request.
-func (client *Client) listChildCreateRequest(ctx context.Context, resourceID, resourceType, apiVersion string) (*policy.Request, error) {
-	urlPath := "/{resourceId}/{resourceType}"
-	urlPath = strings.ReplaceAll(urlPath, "{resourceId}", resourceID)
-	urlPath = strings.ReplaceAll(urlPath, "{resourceType}", resourceType)
+// listChildCreateRequest creates the ListChild request, and tells whether it requests a singleton (see listURLPath).
+func (client *Client) listChildCreateRequest(ctx context.Context, resourceID, resourceType, apiVersion string, options *ClientListChildOptions) (*policy.Request, bool, error) {
+	urlPath, singleton, err := client.listURLPath(resourceID, resourceType, options)
+	if err != nil {
+		return nil, false, err
+	}
 	req, err := runtime.NewRequest(ctx, http.MethodGet, runtime.JoinPaths(client.host, urlPath))
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	reqQP := req.Raw().URL.Query()
 	reqQP.Set("api-version", apiVersion)
+	if options != nil && options.Expand != nil {
+		reqQP.Set("$expand", *options.Expand)
+	}
+	if options != nil {
+		for k, v := range options.Query {
+			reqQP[k] = v
+		}
+	}
 	req.Raw().URL.RawQuery = reqQP.Encode()
 	req.Raw().Header["Accept"] = []string{"application/json"}
-	return req, nil
+	return req, singleton, nil
 }
 
 // listChildHandleResponse handles the ListChild response.
-func (client *Client) listChildHandleResponse(resp *http.Response) (ClientListResponse, error) {
+// The response of a singleton (see DefaultListURLTemplates) is the resource itself, which is returned as a single item list.
+func (client *Client) listChildHandleResponse(resp *http.Response, singleton bool) (ClientListResponse, error) {
 	result := ClientListResponse{}
 	if err := runtime.UnmarshalAsJSON(resp, &result.ResourceListResult); err != nil {
 		return ClientListResponse{}, err
 	}
+	if singleton && len(result.Value) == 0 && result.NextLink == nil {
+		var item GenericResourceExpanded
+		if err := runtime.UnmarshalAsJSON(resp, &item); err != nil {
+			return ClientListResponse{}, err
+		}
+		if item.ID != nil {
+			result.Value = []*GenericResourceExpanded{&item}
+		}
+	}
 	return result, nil
 }
+
+const tagsAPIVersion = "2021-04-01"
+
+// MergeTags - Merges the tags into the existing tags of a resource by the Microsoft.Resources/tags/default at its scope,
+// which works for every taggable resource, regardless of its resource provider and api-version.
+// If the operation fails it returns an *azcore.ResponseError type.
+func (client *Client) MergeTags(ctx context.Context, resourceID string, tags map[string]*string) error {
+	body := map[string]interface{}{
+		"operation": "Merge",
+		"properties": map[string]interface{}{
+			"tags": tags,
+		},
+	}
+	_, err := client.do(ctx, http.MethodPatch, resourceID+"/providers/Microsoft.Resources/tags/default", tagsAPIVersion, nil, body, http.StatusOK)
+	return err
+}
+
+const lockAPIVersion = "2016-09-01"
+
+// CreateOrUpdateLock - Creates or updates a management lock at the scope of a resource.
+// If the operation fails it returns an *azcore.ResponseError type.
+func (client *Client) CreateOrUpdateLock(ctx context.Context, resourceID, lockName, level, notes string) error {
+	body := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"level": level,
+			"notes": notes,
+		},
+	}
+	_, err := client.do(ctx, http.MethodPut, resourceID+"/providers/Microsoft.Authorization/locks/"+lockName, lockAPIVersion, nil, body, http.StatusOK, http.StatusCreated)
+	return err
+}
+
+// DeleteLock - Deletes a management lock at the scope of a resource.
+// If the operation fails it returns an *azcore.ResponseError type.
+func (client *Client) DeleteLock(ctx context.Context, resourceID, lockName string) error {
+	_, err := client.do(ctx, http.MethodDelete, resourceID+"/providers/Microsoft.Authorization/locks/"+lockName, lockAPIVersion, nil, nil, http.StatusOK, http.StatusNoContent)
+	return err
+}
+
+// Get - Gets a resource, whose JSON body is returned as a map.
+// If the operation fails it returns an *azcore.ResponseError type.
+func (client *Client) Get(ctx context.Context, resourceID, apiVersion string) (map[string]interface{}, error) {
+	return client.GetWithQuery(ctx, resourceID, apiVersion, nil)
+}
+
+// GetWithQuery reads the resource with the api-version, with additional query parameters (e.g. "$expand").
+func (client *Client) GetWithQuery(ctx context.Context, resourceID, apiVersion string, query url.Values) (map[string]interface{}, error) {
+	resp, err := client.do(ctx, http.MethodGet, resourceID, apiVersion, query, nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	var body map[string]interface{}
+	if err := runtime.UnmarshalAsJSON(resp, &body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// do sends a request to the path, with optional query parameters and JSON body, and expects one of the status codes in the response.
+func (client *Client) do(ctx context.Context, method, urlPath, apiVersion string, query url.Values, body interface{}, statusCodes ...int) (*http.Response, error) {
+	req, err := runtime.NewRequest(ctx, method, runtime.JoinPaths(client.host, urlPath))
+	if err != nil {
+		return nil, err
+	}
+	reqQP := req.Raw().URL.Query()
+	for k, vs := range query {
+		for _, v := range vs {
+			reqQP.Add(k, v)
+		}
+	}
+	reqQP.Set("api-version", apiVersion)
+	req.Raw().URL.RawQuery = reqQP.Encode()
+	req.Raw().Header["Accept"] = []string{"application/json"}
+	if body != nil {
+		if err := runtime.MarshalAsJSON(req, body); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := client.pl.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if !runtime.HasStatusCode(resp, statusCodes...) {
+		return nil, runtime.NewResponseError(resp)
+	}
+	return resp, nil
+}
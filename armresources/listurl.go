@@ -0,0 +1,131 @@
+package armresources
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// defaultListURLTemplate is the URL path template of the collection of most child (and extension) resource types.
+const defaultListURLTemplate = "/{resourceId}/{resourceType}"
+
+// DefaultListURLTemplates maps the upper cased resource types, which can't be listed at "/{resourceId}/{resourceType}", to the URL path templates of their collections.
+// Some of them are singletons without a list endpoint, whose template points to the singleton instead, which is then returned as a single item list.
+var DefaultListURLTemplates = map[string]string{
+	"MICROSOFT.STORAGE/STORAGEACCOUNTS/MANAGEMENTPOLICIES": "/{resourceId}/managementPolicies/default",
+	"MICROSOFT.WEB/SITES/NETWORKCONFIG":                    "/{resourceId}/networkConfig/virtualNetwork",
+	"MICROSOFT.WEB/SITES/SLOTS/NETWORKCONFIG":              "/{resourceId}/networkConfig/virtualNetwork",
+	"MICROSOFT.WEB/SITES/SOURCECONTROLS":                   "/{resourceId}/sourcecontrols/web",
+	"MICROSOFT.WEB/SITES/SLOTS/SOURCECONTROLS":             "/{resourceId}/sourcecontrols/web",
+}
+
+// listURLPlaceholders are the placeholders that can be used in the list URL templates:
+//
+//   - {resourceId}: The parent resource id
+//   - {resourceType}: The child resource type relative to the parent, or "providers/<extension resource type>"
+//   - {subscriptionId}: The subscription id of the parent resource
+//   - {resourceGroupName}: The resource group name of the parent resource
+//   - {name}: The parent resource name
+//   - {location}: The location of the parent resource, for the location scoped collections
+var listURLPlaceholders = map[string]bool{
+	"resourceId":        true,
+	"resourceType":      true,
+	"subscriptionId":    true,
+	"resourceGroupName": true,
+	"name":              true,
+	"location":          true,
+}
+
+var listURLPlaceholderPattern = regexp.MustCompile(`{([^{}]*)}`)
+
+// ValidateListURLTemplate checks the list URL template is an absolute URL path, that only refers to the known placeholders.
+func ValidateListURLTemplate(tmpl string) error {
+	if !strings.HasPrefix(tmpl, "/") {
+		return fmt.Errorf("list URL template %q must start with a slash", tmpl)
+	}
+	for _, m := range listURLPlaceholderPattern.FindAllStringSubmatch(tmpl, -1) {
+		if !listURLPlaceholders[m[1]] {
+			return fmt.Errorf("list URL template %q has unknown placeholder %q", tmpl, m[0])
+		}
+	}
+	return nil
+}
+
+// SetListURLTemplates sets the list URL templates of the resource types, on top of the DefaultListURLTemplates. It should be called before any listing.
+func (client *Client) SetListURLTemplates(templates map[string]string) error {
+	out := map[string]string{}
+	for rt, tmpl := range DefaultListURLTemplates {
+		out[rt] = tmpl
+	}
+	for rt, tmpl := range templates {
+		if err := ValidateListURLTemplate(tmpl); err != nil {
+			return fmt.Errorf("resource type %s: %v", rt, err)
+		}
+		out[strings.ToUpper(rt)] = tmpl
+	}
+	client.listURLTemplates = out
+	return nil
+}
+
+// listURLPath returns the URL path of the collection of the child resource type under the resource.
+// It also tells whether the URL path points to a singleton, i.e. the template in use is the one of the DefaultListURLTemplates.
+func (client *Client) listURLPath(resourceID, resourceType string, options *ClientListChildOptions) (string, bool, error) {
+	tmpl := defaultListURLTemplate
+	var (
+		location  string
+		singleton bool
+	)
+	if options != nil {
+		templates := client.listURLTemplates
+		if templates == nil {
+			templates = DefaultListURLTemplates
+		}
+		rt := strings.ToUpper(options.ResourceType)
+		if v, ok := templates[rt]; ok {
+			tmpl = v
+			singleton = DefaultListURLTemplates[rt] == v
+		}
+		location = options.Location
+	}
+
+	var subscriptionID, resourceGroupName, name string
+	segs := strings.Split(strings.Trim(resourceID, "/"), "/")
+	for i := 0; i+1 < len(segs); i += 2 {
+		switch {
+		case strings.EqualFold(segs[i], "subscriptions") && subscriptionID == "":
+			subscriptionID = segs[i+1]
+		case strings.EqualFold(segs[i], "resourceGroups") && resourceGroupName == "":
+			resourceGroupName = segs[i+1]
+		}
+	}
+	if len(segs) != 0 {
+		name = segs[len(segs)-1]
+	}
+
+	var err error
+	urlPath := listURLPlaceholderPattern.ReplaceAllStringFunc(tmpl, func(p string) string {
+		var v string
+		switch p {
+		case "{resourceId}":
+			v = strings.Trim(resourceID, "/")
+		case "{resourceType}":
+			v = resourceType
+		case "{subscriptionId}":
+			v = subscriptionID
+		case "{resourceGroupName}":
+			v = resourceGroupName
+		case "{name}":
+			v = name
+		case "{location}":
+			v = location
+		}
+		if v == "" && err == nil {
+			err = fmt.Errorf("list URL template %q: no value for %s of resource %s", tmpl, p, resourceID)
+		}
+		return v
+	})
+	if err != nil {
+		return "", false, err
+	}
+	return urlPath, singleton, nil
+}
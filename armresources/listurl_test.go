@@ -0,0 +1,107 @@
+package armresources
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateListURLTemplate(t *testing.T) {
+	require.NoError(t, ValidateListURLTemplate("/{resourceId}/{resourceType}"))
+	require.NoError(t, ValidateListURLTemplate("/subscriptions/{subscriptionId}/providers/Microsoft.Foo/locations/{location}/bars"))
+	require.Error(t, ValidateListURLTemplate("{resourceId}/bars"))
+	require.Error(t, ValidateListURLTemplate("/{resourceId}/{unknown}"))
+}
+
+func TestListURLPath(t *testing.T) {
+	client := &Client{}
+	require.NoError(t, client.SetListURLTemplates(map[string]string{
+		"Microsoft.Foo/bars/bazs":                  "/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.Foo/locations/{location}/bazs/{name}",
+		"Microsoft.Web/sites/slots/sourcecontrols": "/{resourceId}/sourcecontrols",
+	}))
+	cases := []struct {
+		name         string
+		resourceId   string
+		resourceType string
+		options      *ClientListChildOptions
+		expect       string
+		expectSingle bool
+		expectErr    bool
+	}{
+		{
+			name:         "default template",
+			resourceId:   "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1",
+			resourceType: "subnets",
+			options:      &ClientListChildOptions{ResourceType: "Microsoft.Network/virtualNetworks/subnets"},
+			expect:       "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1/subnets",
+		},
+		{
+			name:         "no options",
+			resourceId:   "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Web/sites/site1",
+			resourceType: "sourcecontrols",
+			expect:       "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Web/sites/site1/sourcecontrols",
+		},
+		{
+			name:         "singleton template",
+			resourceId:   "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Web/sites/site1",
+			resourceType: "sourcecontrols",
+			options:      &ClientListChildOptions{ResourceType: "microsoft.web/sites/sourcecontrols"},
+			expect:       "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Web/sites/site1/sourcecontrols/web",
+			expectSingle: true,
+		},
+		{
+			name:         "overridden singleton template",
+			resourceId:   "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Web/sites/site1/slots/slot1",
+			resourceType: "sourcecontrols",
+			options:      &ClientListChildOptions{ResourceType: "Microsoft.Web/sites/slots/sourcecontrols"},
+			expect:       "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Web/sites/site1/slots/slot1/sourcecontrols",
+		},
+		{
+			name:         "placeholders",
+			resourceId:   "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Foo/bars/bar1",
+			resourceType: "bazs",
+			options:      &ClientListChildOptions{ResourceType: "Microsoft.Foo/bars/bazs", Location: "westus"},
+			expect:       "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Foo/locations/westus/bazs/bar1",
+		},
+		{
+			name:         "missing placeholder value",
+			resourceId:   "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Foo/bars/bar1",
+			resourceType: "bazs",
+			options:      &ClientListChildOptions{ResourceType: "Microsoft.Foo/bars/bazs"},
+			expectErr:    true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			urlPath, singleton, err := client.listURLPath(tt.resourceId, tt.resourceType, tt.options)
+			if tt.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expect, urlPath)
+			require.Equal(t, tt.expectSingle, singleton)
+		})
+	}
+}
+
+func TestListChildHandleResponse(t *testing.T) {
+	newResp := func(body string) *http.Response {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}
+	}
+	client := &Client{}
+	body := `{"id": "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Web/sites/site1/sourcecontrols/web", "name": "web"}`
+
+	result, err := client.listChildHandleResponse(newResp(body), true)
+	require.NoError(t, err)
+	require.Len(t, result.Value, 1)
+	require.Equal(t, "web", *result.Value[0].Name)
+
+	// The same response of a collection is an empty list.
+	result, err = client.listChildHandleResponse(newResp(body), false)
+	require.NoError(t, err)
+	require.Empty(t, result.Value)
+}
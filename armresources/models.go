@@ -1,6 +1,9 @@
 package armresources
 
-import "time"
+import (
+	"net/url"
+	"time"
+)
 
 // GenericResourceExpanded - Resource information.
 type GenericResourceExpanded struct {
@@ -122,3 +125,15 @@ type SKU struct {
 	// The SKU tier.
 	Tier *string `json:"tier,omitempty"`
 }
+
+// ClientListChildOptions contains the optional parameters for the Client.NewListChildPager method.
+type ClientListChildOptions struct {
+	// The comma separated list of additional properties to include in the response, e.g. "createdTime,changedTime".
+	Expand *string
+	// Query is the additional query parameters (e.g. "$filter"), which override the ones above.
+	Query url.Values
+	// ResourceType is the full resource type of the child resources (e.g. "Microsoft.Web/sites/sourcecontrols"), which selects the URL template of the collection.
+	ResourceType string
+	// Location is the location of the parent resource, for the URL templates of the location scoped collections.
+	Location string
+}
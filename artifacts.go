@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/magodo/azlist/azlist"
+)
+
+// artifacts is the working directory of a run, where its artifacts are written into.
+type artifacts struct {
+	dir   string
+	start time.Time
+	// log is the log file of the run.
+	log *os.File
+	// audit is the audit log of the run, which records each API request sent, one JSON object per line.
+	audit   *os.File
+	auditMu sync.Mutex
+}
+
+// newArtifacts creates a timestamped directory under the base directory for this run, with the log file opened.
+func newArtifacts(baseDir string) (*artifacts, error) {
+	start := time.Now()
+	dir := filepath.Join(baseDir, start.UTC().Format("20060102T150405Z"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating artifacts directory: %v", err)
+	}
+	log, err := os.Create(filepath.Join(dir, "azlist.log"))
+	if err != nil {
+		return nil, fmt.Errorf("creating log file: %v", err)
+	}
+	audit, err := os.Create(filepath.Join(dir, "audit.jsonl"))
+	if err != nil {
+		log.Close()
+		return nil, fmt.Errorf("creating audit log file: %v", err)
+	}
+	return &artifacts{dir: dir, start: start, log: log, audit: audit}, nil
+}
+
+// auditEntry is a line of the audit log. The request headers and bodies are not recorded, as they might contain secrets.
+type auditEntry struct {
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method"`
+	URL        string    `json:"url"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	RequestId  string    `json:"requestId,omitempty"`
+	Duration   string    `json:"duration"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// auditPolicy is a per retry policy that records each API request sent in the audit log.
+type auditPolicy struct {
+	a *artifacts
+}
+
+func (p auditPolicy) Do(req *policy.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := req.Next()
+	entry := auditEntry{
+		Time:     start.UTC(),
+		Method:   req.Raw().Method,
+		URL:      req.Raw().URL.String(),
+		Duration: time.Since(start).String(),
+	}
+	if resp != nil {
+		entry.StatusCode = resp.StatusCode
+		entry.RequestId = resp.Header.Get("x-ms-request-id")
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	p.a.auditMu.Lock()
+	defer p.a.auditMu.Unlock()
+	// The audit log is best effort, which doesn't fail the request.
+	json.NewEncoder(p.a.audit).Encode(entry)
+	return resp, err
+}
+
+type runSummary struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Duration string    `json:"duration"`
+	// Error is the error that fails the run, if any.
+	Error       string              `json:"error,omitempty"`
+	Resources   int                 `json:"resources"`
+	Errors      int                 `json:"errors"`
+	ErrorClass  map[string]int      `json:"errorClass"`
+	Unparseable int                 `json:"unparseable"`
+	Types       map[string]int      `json:"types"`
+	Requests    azlist.RequestStats `json:"requests"`
+}
+
+// finish writes the result, errors, summary and effective configuration of the run, and closes the log files.
+// It is called whether or not the run succeeds. The result is nil if the run fails before the listing is done, in which case no result.json is written.
+func (a *artifacts) finish(result *azlist.ListResult, runErr error, config map[string]interface{}) error {
+	defer a.log.Close()
+	defer a.audit.Close()
+
+	files := map[string]interface{}{
+		"config.json": config,
+	}
+	if result != nil {
+		files["result.json"] = result
+	} else {
+		result = &azlist.ListResult{}
+	}
+	end := time.Now()
+	summary := runSummary{
+		Start:       a.start,
+		End:         end,
+		Duration:    end.Sub(a.start).String(),
+		Resources:   len(result.Resources),
+		Errors:      len(result.Errors),
+		ErrorClass:  map[string]int{},
+		Unparseable: len(result.Unparseable),
+		Types:       map[string]int{},
+		Requests:    result.Requests,
+	}
+	if runErr != nil {
+		summary.Error = runErr.Error()
+	}
+	for _, res := range result.Resources {
+		summary.Types[azlist.ResourceType(res.Id)]++
+	}
+	for _, e := range result.Errors {
+		summary.ErrorClass[string(azlist.ClassifyError(e))]++
+	}
+
+	errors := result.Errors
+	if errors == nil {
+		errors = []azlist.ListError{}
+	}
+	files["errors.json"] = errors
+	files["summary.json"] = summary
+	for name, v := range files {
+		if err := a.writeJSON(name, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *artifacts) writeJSON(name string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling %s: %v", name, err)
+	}
+	if err := os.WriteFile(filepath.Join(a.dir, name), append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing %s: %v", name, err)
+	}
+	return nil
+}
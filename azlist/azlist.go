@@ -4,18 +4,25 @@ import (
 	"context"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"path"
 	"runtime"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resourcegraph/armresourcegraph"
+	sdkARMResources "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
 	"github.com/magodo/armid"
+	"github.com/magodo/azlist/armresources"
 	"github.com/magodo/workerpool"
 )
 
@@ -26,6 +33,97 @@ func ptr[T any](v T) *T {
 type AzureResource struct {
 	Id         armid.ResourceId
 	Properties map[string]interface{}
+	// APIVersion is the api-version used to list this resource. For resources returned by ARG, it is the api-version azlist would use to access it.
+	APIVersion string
+	// SubscriptionId is the subscription that this resource belongs to, or empty for resources out of a subscription (e.g. management groups).
+	SubscriptionId string
+	// Scope and DiscoveredThrough are only set for the extension resources. Scope is the scope of the extension resource by its id,
+	// which might differ from the resource it is listed under (i.e. DiscoveredThrough), e.g. the role assignments inherited from the resource group.
+	Scope             armid.ResourceId
+	DiscoveredThrough armid.ResourceId
+	// ReferencedBy is only set for the extension resources listed under more than one resource (e.g. the role assignments inherited from the subscription,
+	// if not filtered), which are all these resources in order. In which case, DiscoveredThrough is the one of them at the Scope if any, otherwise the first one.
+	ReferencedBy []armid.ResourceId
+}
+
+type azureResourceJSON struct {
+	Id                string                 `json:"id"`
+	SubscriptionId    string                 `json:"subscriptionId,omitempty"`
+	APIVersion        string                 `json:"apiVersion,omitempty"`
+	Scope             string                 `json:"scope,omitempty"`
+	DiscoveredThrough string                 `json:"discoveredThrough,omitempty"`
+	ReferencedBy      []string               `json:"referencedBy,omitempty"`
+	Properties        map[string]interface{} `json:"properties"`
+}
+
+// idString returns the id literal, or empty string for nil.
+func idString(id armid.ResourceId) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
+
+// parseOptionalId parses the id literal, or returns nil for empty string.
+func parseOptionalId(id string) (armid.ResourceId, error) {
+	if id == "" {
+		return nil, nil
+	}
+	return armid.ParseResourceId(id)
+}
+
+// MarshalJSON marshals the resource with its id rendered as the id literal.
+func (res AzureResource) MarshalJSON() ([]byte, error) {
+	var referencedBy []string
+	for _, id := range res.ReferencedBy {
+		referencedBy = append(referencedBy, id.String())
+	}
+	return json.Marshal(azureResourceJSON{
+		Id:                res.Id.String(),
+		SubscriptionId:    res.SubscriptionId,
+		APIVersion:        res.APIVersion,
+		Scope:             idString(res.Scope),
+		DiscoveredThrough: idString(res.DiscoveredThrough),
+		ReferencedBy:      referencedBy,
+		Properties:        res.Properties,
+	})
+}
+
+func (res *AzureResource) UnmarshalJSON(b []byte) error {
+	var v azureResourceJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	id, err := armid.ParseResourceId(v.Id)
+	if err != nil {
+		return fmt.Errorf("parsing resource id %s: %v", v.Id, err)
+	}
+	scope, err := parseOptionalId(v.Scope)
+	if err != nil {
+		return fmt.Errorf("parsing scope %s: %v", v.Scope, err)
+	}
+	discoveredThrough, err := parseOptionalId(v.DiscoveredThrough)
+	if err != nil {
+		return fmt.Errorf("parsing discovered through %s: %v", v.DiscoveredThrough, err)
+	}
+	var referencedBy []armid.ResourceId
+	for _, v := range v.ReferencedBy {
+		id, err := armid.ParseResourceId(v)
+		if err != nil {
+			return fmt.Errorf("parsing referenced by %s: %v", v, err)
+		}
+		referencedBy = append(referencedBy, id)
+	}
+	*res = AzureResource{
+		Id:                id,
+		SubscriptionId:    v.SubscriptionId,
+		APIVersion:        v.APIVersion,
+		Scope:             scope,
+		DiscoveredThrough: discoveredThrough,
+		ReferencedBy:      referencedBy,
+		Properties:        v.Properties,
+	}
+	return nil
 }
 
 //go:embed armschema.json
@@ -39,12 +137,13 @@ type ARMSchemaEntry struct {
 }
 
 type ExtensionResource struct {
-	Type   string
+	Type string
+	// Filter filters the listed extension resources. Defaults to the built-in one of the Type, if any (see BuiltinExtensionFilter).
 	Filter ResourceFilter
 }
 
 type Option struct {
-	// Required
+	// Required, unless AllSubscriptions is set
 	SubscriptionId string
 	Cred           azcore.TokenCredential
 	ClientOpt      arm.ClientOptions
@@ -58,48 +157,311 @@ type Option struct {
 	ExtensionResourceTypes      []ExtensionResource
 	ARGTable                    string
 	ARGAuthorizationScopeFilter armresourcegraph.AuthorizationScopeFilter
+	// ARGPageSize is the number of records per ARG page, between 1 and 1000. Defaults to 1000.
+	// Lower it to work around ARG payload size errors for resources with huge bodies.
+	ARGPageSize int32
+	// ARGSkip is the number of ARG records to skip at the beginning. The query with the skip is not split when its response is too large.
+	ARGSkip int32
+	// ARGAllowPartialScopes allows the ARG query to succeed with the subscriptions that the caller has access to, instead of failing entirely.
+	ARGAllowPartialScopes bool
+	// AllSubscriptions lists across all the subscriptions accessible in the tenant, in which case the SubscriptionId is ignored.
+	AllSubscriptions bool
+	// ParseResourceId parses the resource ids returned by ARG and ARM. Defaults to armid.ParseResourceId.
+	// Items whose ids fail to parse are kept in the ListResult.Unparseable, instead of failing the listing.
+	ParseResourceId func(id string) (armid.ResourceId, error)
+	// ExpandTimes populates the "createdTime" and "changedTime" fields in the bodies of the tracked resources, by the $expand of the ARM (Microsoft.Resources) list calls,
+	// which page through all the resources of each subscription of the tracked resources. The child and extension resources are not populated.
+	ExpandTimes bool
+	// ExpandMetadataTypes are the child or extension resource types (case insensitive) whose list calls support the $expand of the "createdTime", "changedTime"
+	// and "provisioningState", which populates these fields in the bodies of the listed resources. Most resource providers don't support it, and might reject it.
+	// It is set as the "$expand" of the ListQueryParameters of the types, unless they have one already.
+	ExpandMetadataTypes []string
+	// RecordNotFound records the child and extension resource listings responded with 404 in the ListResult.NotFound, instead of ignoring them silently.
+	RecordNotFound bool
+	// ProgressInterval is the interval to log a debug summary of the listing progress. Zero disables it.
+	ProgressInterval time.Duration
+	// OnProgress is called with the listing progress every ProgressInterval.
+	OnProgress func(Progress)
+	// Top caps the number of listed resources, which stops the ARG pagination and the recursive listing once reached. Zero means no cap.
+	Top int
+	// MaxDepth bounds the levels of the child resources listed by Recursive, e.g. 1 only lists the direct children of the ARG results. Zero means no bound.
+	MaxDepth int
+	// RecurseOnlyTypes and SkipChildTypes are the case insensitive glob patterns (see path.Match) of the child resource types to list, or to skip, by Recursive.
+	// A pattern matches a resource type if it matches the type or any of its ancestor types, e.g. "Microsoft.Web/*" matches "Microsoft.Web/sites/slots",
+	// and "Microsoft.Web/sites/snapshots" skips both the snapshots and their child resources.
+	RecurseOnlyTypes []string
+	SkipChildTypes   []string
+	// RecursePredicate tells whether to list the child resources of the resource by Recursive, e.g. to skip the huge DNS zones by their properties.
+	// It is called concurrently by the listing workers, without any lock held, so it must be safe for concurrent use. It might also be called for a resource that turns out to be already listed.
+	// Nil means recursing into all the resources.
+	RecursePredicate func(parent AzureResource) bool
+	// NoDefaultSkips lists the child resource types in the DefaultSkipChildTypes as well, which are skipped by Recursive by default.
+	NoDefaultSkips bool
+	// SkipManagedResourceGroups skips listing the child resources of the resources in the resource groups whose "managedBy" is set by Recursive,
+	// e.g. the node resource groups of AKS clusters and the managed resource groups of Databricks workspaces, which are looked up by ARG.
+	SkipManagedResourceGroups bool
+	// ListQueryParameters maps the child or extension resource types (case insensitive) to the extra query parameters of their list calls,
+	// e.g. "$filter=atScope()" for "Microsoft.Authorization/roleAssignments". See also ExpandMetadataTypes.
+	ListQueryParameters map[string]url.Values
+	// ListURLTemplates maps the child or extension resource types (case insensitive) to the URL path templates of their collections, on top of the
+	// armresources.DefaultListURLTemplates, for the ones that can't be listed at "/{resourceId}/{resourceType}" (e.g. "/{resourceId}/sourcecontrols/web").
+	ListURLTemplates map[string]string
+	// IncludeAncestors includes the management groups that the subscriptions of the listed resources belong to, from the root one down to the direct parent.
+	IncludeAncestors bool
+	// IncludeTenantResources includes the resources at the tenant root scope, i.e. the management groups and the tenant scoped policy (set) definitions (see TenantResourceTypes).
+	// They are listed regardless of the ARG predicate.
+	IncludeTenantResources bool
+	// ARMSchema is the content of the ARM schema file, which maps the resource types to their api-versions, in the same format as the embedded ARMSchemaFile.
+	// Defaults to the embedded one, with the ARMSchemaCacheFile merged (see LoadARMSchemaFile).
+	ARMSchema []byte
+	// ARMSchemaCacheFile is the path of the cached ARM schema file (e.g. the ARMSchemaCachePath), whose api-versions are merged into the embedded ARM schema if exists.
+	// It is ignored if ARMSchema is set. Empty means only the embedded one is used.
+	ARMSchemaCacheFile string
+	// ARMSchemaOverlay is the content of an overlay file, which adds or overrides (or removes, if there is no api-version) individual resource types of the ARMSchema.
+	ARMSchemaOverlay []byte
+	// LiveSchema builds the ARM schema tree from the live resource provider metadata of the subscription, when the first listing starts, instead of the embedded schema.
+	// This makes the newly released resource types discoverable, while it might also include some non-listable resource types (e.g. operation results).
+	// It requires the SubscriptionId.
+	LiveSchema bool
+	// APIVersions maps the resource types (case insensitive) to the pinned api-versions, which are used instead of the latest ones in the ARM schema.
+	// They can still be overridden per call by the CallOption.
+	APIVersions map[string]string
+	// VersionStrategy decides which api-version in the ARM schema is used for the resource types that are not pinned. Defaults to VersionStrategyLatest.
+	VersionStrategy VersionStrategy
 }
 
 type ListError struct {
-	Endpoint string
-	Version  string
-	Message  string
+	Endpoint string `json:"endpoint"`
+	Version  string `json:"version"`
+	Message  string `json:"message"`
+	// Payload is the offending record, for record level failures.
+	Payload interface{} `json:"payload,omitempty"`
+	// StatusCode and ErrorCode are from the ARM response error, if any.
+	StatusCode int    `json:"statusCode,omitempty"`
+	ErrorCode  string `json:"errorCode,omitempty"`
 }
 
 func (e ListError) Error() string {
 	return fmt.Sprintf("Listing %s (api-version=%s): %s", e.Endpoint, e.Version, e.Message)
 }
 
+// UnparseableResource is a listed item whose id fails to parse.
+type UnparseableResource struct {
+	Id         string                 `json:"id"`
+	Properties map[string]interface{} `json:"properties"`
+	Message    string                 `json:"message"`
+}
+
 type ListResult struct {
-	Resources []AzureResource
-	Errors    []ListError
+	Resources   []AzureResource       `json:"resources"`
+	Errors      []ListError           `json:"errors"`
+	Unparseable []UnparseableResource `json:"unparseable,omitempty"`
+	// NotFound counts the listings responded with 404, if Option.RecordNotFound is set.
+	NotFound []NotFoundWarning `json:"notFound,omitempty"`
+	// Rows are the ARG records without a resource id (e.g. from the predicates projecting away the id, like "true | summarize count() by type"), which are kept as is.
+	Rows []map[string]interface{} `json:"rows,omitempty"`
+	// Requests counts the API requests sent during the listing.
+	Requests RequestStats `json:"requests"`
+	// Schema is the provenance of the ARM schema used by the listing.
+	Schema *ARMSchemaInfo `json:"schema,omitempty"`
+}
+
+// MergeListResults merges multiple list results (e.g. of different subscriptions) into one, with the resources and errors deduplicated while keeping their orders.
+func MergeListResults(results ...*ListResult) *ListResult {
+	out := &ListResult{}
+	// rset maps the resource ids to their indexes in the output resources.
+	rset := map[string]int{}
+	eset := map[string]bool{}
+	for _, result := range results {
+		for _, res := range result.Resources {
+			key := strings.ToUpper(res.Id.String())
+			if i, ok := rset[key]; ok {
+				// Merge the resources that the extension resource is listed under.
+				if prev := out.Resources[i]; prev.DiscoveredThrough != nil && res.DiscoveredThrough != nil {
+					refs := append(append([]armid.ResourceId{}, referencesOf(prev)...), referencesOf(res)...)
+					if merged := withReferencedBy(prev, refs); len(merged.ReferencedBy) > 1 {
+						out.Resources[i] = merged
+					}
+				}
+				continue
+			}
+			rset[key] = len(out.Resources)
+			out.Resources = append(out.Resources, res)
+		}
+		for _, le := range result.Errors {
+			key := strings.ToUpper(le.Endpoint)
+			if eset[key] {
+				continue
+			}
+			eset[key] = true
+			out.Errors = append(out.Errors, le)
+		}
+		out.Unparseable = append(out.Unparseable, result.Unparseable...)
+		out.NotFound = append(out.NotFound, result.NotFound...)
+		out.Rows = append(out.Rows, result.Rows...)
+		out.Requests.ARGRequests += result.Requests.ARGRequests
+		out.Requests.ARMRequests += result.Requests.ARMRequests
+		if out.Schema == nil {
+			out.Schema = result.Schema
+		}
+	}
+	out.NotFound = mergeNotFoundWarnings(out.NotFound)
+	return out
+}
+
+// BySubscription partitions the list result by the subscriptions of the resources, keyed by the subscription ids.
+// Resources out of any subscription (e.g. management groups) are keyed by the empty string.
+// The listing errors are partitioned by the subscriptions in their endpoints, while the request stats are not partitioned.
+func (result *ListResult) BySubscription() map[string]*ListResult {
+	out := map[string]*ListResult{}
+	get := func(key string) *ListResult {
+		for k, v := range out {
+			if strings.EqualFold(k, key) {
+				return v
+			}
+		}
+		out[key] = &ListResult{Schema: result.Schema}
+		return out[key]
+	}
+	for _, res := range result.Resources {
+		sub := res.SubscriptionId
+		if sub == "" {
+			sub = subscriptionOf(res.Id)
+		}
+		p := get(sub)
+		p.Resources = append(p.Resources, res)
+	}
+	for _, le := range result.Errors {
+		var sub string
+		if segs := strings.Split(le.Endpoint, "/"); len(segs) > 2 && strings.EqualFold(segs[1], "subscriptions") {
+			sub = segs[2]
+		}
+		p := get(sub)
+		p.Errors = append(p.Errors, le)
+	}
+	return out
+}
+
+// ByTenant partitions the list result by the tenants of the resources, keyed by the tenant ids.
+// The tenant id is read from the "tenantId" field of the resource body (as returned by ARG), or of its nearest listed ancestor (e.g. for the child resources).
+// Resources whose tenant is unknown are keyed by the empty string. The listing errors and request stats are not partitioned.
+func (result *ListResult) ByTenant() map[string]*ListResult {
+	tenants := map[string]string{}
+	for _, res := range result.Resources {
+		if tid, ok := res.Properties["tenantId"].(string); ok && tid != "" {
+			tenants[strings.ToUpper(res.Id.String())] = tid
+		}
+	}
+	out := map[string]*ListResult{}
+	for _, res := range result.Resources {
+		var tid string
+		for id := res.Id; id != nil; {
+			if v, ok := tenants[strings.ToUpper(id.String())]; ok {
+				tid = v
+				break
+			}
+			if parent := id.Parent(); parent != nil {
+				id = parent
+			} else if rg, ok := id.(*armid.ResourceGroup); ok {
+				// The resource group has no parent scope by armid.
+				id = &armid.SubscriptionId{Id: rg.SubscriptionId}
+			} else {
+				id = id.ParentScope()
+			}
+		}
+		if out[tid] == nil {
+			out[tid] = &ListResult{Schema: result.Schema}
+		}
+		out[tid].Resources = append(out[tid].Resources, res)
+	}
+	return out
 }
 
 type Lister struct {
 	*slog.Logger
 
+	// SubscriptionId is empty when listing across all subscriptions.
 	SubscriptionId              string
 	Client                      *Client
 	Parallelism                 int
 	Recursive                   bool
+	MaxDepth                    int
+	RecurseOnlyTypes            []string
+	SkipChildTypes              []string
+	NoDefaultSkips              bool
+	SkipManagedResourceGroups   bool
+	RecursePredicate            func(parent AzureResource) bool
+	ListQueryParameters         map[string]url.Values
 	IncludeManaged              bool
 	IncludeResourceGroup        bool
+	IncludeAncestors            bool
+	IncludeTenantResources      bool
 	ExtensionResourceTypes      []ExtensionResource
 	ARMSchemaTree               ARMSchemaTree
+	ARMSchemaInfo               ARMSchemaInfo
 	ARGTable                    string
 	ARGAuthorizationScopeFilter *armresourcegraph.AuthorizationScopeFilter
+	ARGAllowPartialScopes       bool
+	ARGPageSize                 int32
+	ARGSkip                     int32
+	ParseResourceId             func(id string) (armid.ResourceId, error)
+	ExpandTimes                 bool
+	RecordNotFound              bool
+	ProgressInterval            time.Duration
+	OnProgress                  func(Progress)
+	Top                         int
+	APIVersions                 map[string]string
+	VersionStrategy             VersionStrategy
+	LiveSchema                  bool
+
+	// schemaMu guards the ARMSchemaTree and ARMSchemaInfo, which are swapped by the live schema loading and refreshing.
+	schemaMu sync.RWMutex
+	// liveMu serializes the loading of the live schema.
+	liveMu       sync.Mutex
+	schemaLoaded bool
+
+	// listChild lists the child resources of a child listing, which defaults to listResource. It is only replaced by the tests.
+	listChild func(ctx context.Context, job childListing) (ListResult, error)
 }
 
 func NewLister(opt Option) (*Lister, error) {
 	if opt.Cred == nil {
 		return nil, fmt.Errorf("token credential is empty")
 	}
-	if opt.SubscriptionId == "" {
+	if opt.AllSubscriptions {
+		opt.SubscriptionId = ""
+	} else if opt.SubscriptionId == "" {
 		return nil, fmt.Errorf("subscription id is empty")
 	}
+	if opt.LiveSchema && opt.SubscriptionId == "" {
+		return nil, fmt.Errorf("live schema requires a subscription id")
+	}
 	if opt.Parallelism == 0 {
 		opt.Parallelism = runtime.NumCPU()
 	}
+	if opt.ARGPageSize == 0 {
+		opt.ARGPageSize = 1000
+	}
+	if opt.ARGPageSize < 0 || opt.ARGPageSize > 1000 {
+		return nil, fmt.Errorf("ARG page size must be between 1 and 1000, got %d", opt.ARGPageSize)
+	}
+	if opt.ARGSkip < 0 {
+		return nil, fmt.Errorf("ARG skip must not be negative, got %d", opt.ARGSkip)
+	}
+	if opt.MaxDepth < 0 {
+		return nil, fmt.Errorf("max depth must not be negative, got %d", opt.MaxDepth)
+	}
+	for _, pattern := range append(append([]string{}, opt.RecurseOnlyTypes...), opt.SkipChildTypes...) {
+		if _, err := path.Match(strings.ToLower(pattern), ""); err != nil {
+			return nil, fmt.Errorf("invalid child type pattern %q: %v", pattern, err)
+		}
+	}
+	if opt.VersionStrategy == "" {
+		opt.VersionStrategy = VersionStrategyLatest
+	}
+	if err := opt.VersionStrategy.Validate(); err != nil {
+		return nil, err
+	}
 
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
 	if opt.Logger != nil {
@@ -110,10 +472,13 @@ func NewLister(opt Option) (*Lister, error) {
 	if err != nil {
 		return nil, fmt.Errorf("new client: %v", err)
 	}
+	if err := client.resource.SetListURLTemplates(opt.ListURLTemplates); err != nil {
+		return nil, err
+	}
 
-	schemaTree, err := BuildARMSchemaTree(ARMSchemaFile)
+	schemaTree, schemaInfo, err := loadARMSchemaTree(opt.ARMSchema, opt.ARMSchemaOverlay, opt.ARMSchemaCacheFile)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("building the ARM schema tree: %v", err)
 	}
 
 	argTable := "Resources"
@@ -121,6 +486,11 @@ func NewLister(opt Option) (*Lister, error) {
 		argTable = opt.ARGTable
 	}
 
+	parseResourceId := armid.ParseResourceId
+	if opt.ParseResourceId != nil {
+		parseResourceId = opt.ParseResourceId
+	}
+
 	var argAuthorizationScopeFilter *armresourcegraph.AuthorizationScopeFilter
 	if opt.ARGAuthorizationScopeFilter != "" {
 		argAuthorizationScopeFilter = &opt.ARGAuthorizationScopeFilter
@@ -132,31 +502,111 @@ func NewLister(opt Option) (*Lister, error) {
 		Client:                      client,
 		Parallelism:                 opt.Parallelism,
 		Recursive:                   opt.Recursive,
+		MaxDepth:                    opt.MaxDepth,
+		RecurseOnlyTypes:            opt.RecurseOnlyTypes,
+		SkipChildTypes:              opt.SkipChildTypes,
+		NoDefaultSkips:              opt.NoDefaultSkips,
+		SkipManagedResourceGroups:   opt.SkipManagedResourceGroups,
+		RecursePredicate:            opt.RecursePredicate,
+		ListQueryParameters:         withMetadataExpand(opt.ListQueryParameters, opt.ExpandMetadataTypes),
 		IncludeManaged:              opt.IncludeManaged,
 		IncludeResourceGroup:        opt.IncludeResourceGroup,
-		ExtensionResourceTypes:      opt.ExtensionResourceTypes,
+		IncludeAncestors:            opt.IncludeAncestors,
+		IncludeTenantResources:      opt.IncludeTenantResources,
+		ExtensionResourceTypes:      withBuiltinExtensionFilters(opt.ExtensionResourceTypes),
 		ARGTable:                    argTable,
 		ARGAuthorizationScopeFilter: argAuthorizationScopeFilter,
+		ARGAllowPartialScopes:       opt.ARGAllowPartialScopes,
+		ARGPageSize:                 opt.ARGPageSize,
+		ARGSkip:                     opt.ARGSkip,
+		ParseResourceId:             parseResourceId,
+		ExpandTimes:                 opt.ExpandTimes,
+		RecordNotFound:              opt.RecordNotFound,
+		ProgressInterval:            opt.ProgressInterval,
+		OnProgress:                  opt.OnProgress,
+		Top:                         opt.Top,
+		APIVersions:                 opt.APIVersions,
+		VersionStrategy:             opt.VersionStrategy,
+		LiveSchema:                  opt.LiveSchema,
 		ARMSchemaTree:               schemaTree,
+		ARMSchemaInfo:               schemaInfo,
 	}, nil
 }
 
 func (l *Lister) List(ctx context.Context, predicate string) (*ListResult, error) {
-	l.Info("List begins", "subscription", l.SubscriptionId, "predicate", predicate, "parallelism", l.Parallelism, "recursive", l.Recursive, "include managed resources", l.IncludeManaged)
+	return l.list(ctx, func(ctx context.Context) (*ListResult, error) {
+		return l.ListTrackedResources(ctx, predicate)
+	}, "predicate", predicate)
+}
+
+// ListIds is similar to List, except it starts from the resources of the given ids, whose bodies are read from ARM, instead of the ones returned by an ARG query.
+// It is useful to re-process the resources of a previous run.
+func (l *Lister) ListIds(ctx context.Context, ids []string) (*ListResult, error) {
+	return l.list(ctx, func(ctx context.Context) (*ListResult, error) {
+		return l.GetResources(ctx, ids)
+	}, "ids", len(ids))
+}
+
+// list lists the tracked resources by the listTracked, then the other resources based on them.
+func (l *Lister) list(ctx context.Context, listTracked func(ctx context.Context) (*ListResult, error), logArgs ...any) (*ListResult, error) {
+	startStats := l.Client.RequestStats()
+
+	l.Info("List begins", append(append([]any{"subscription", l.SubscriptionId}, logArgs...), "parallelism", l.parallelism(ctx), "recursive", l.Recursive, "include managed resources", l.IncludeManaged)...)
+
+	if err := l.loadLiveSchema(ctx); err != nil {
+		return nil, fmt.Errorf("loading the live schema: %v", err)
+	}
+
+	if l.ProgressInterval > 0 {
+		stats := newListStats()
+		ctx = withListStats(ctx, stats)
+		progressCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			l.reportProgress(progressCtx, stats, l.ProgressInterval)
+		}()
+		// The OnProgress shall not be called after List returns.
+		defer func() {
+			cancel()
+			<-done
+		}()
+	}
+
+	var notFound *notFoundRecorder
+	if l.RecordNotFound {
+		notFound = newNotFoundRecorder()
+		ctx = withNotFoundRecorder(ctx, notFound)
+	}
 
 	l.Debug("Listing tracked resources")
-	rl, err := l.ListTrackedResources(ctx, predicate)
+	listStatsFrom(ctx).setPhase("listing tracked resources", 0)
+	tracked, err := listTracked(ctx)
 	if err != nil {
 		return nil, err
 	}
+	rl, el, ul := tracked.Resources, tracked.Errors, tracked.Unparseable
+
+	if l.ExpandTimes {
+		l.Debug("Expanding created and changed times of tracked resources")
+		if err := l.expandTimes(ctx, rl); err != nil {
+			return nil, err
+		}
+	}
 
-	var el []ListError
 	if l.Recursive {
 		l.Debug("Listing child resources")
-		rl, el, err = l.ListChildResource(ctx, rl)
+		listStatsFrom(ctx).setPhase("listing child resources", len(rl))
+		var (
+			childEl []ListError
+			childUl []UnparseableResource
+		)
+		rl, childEl, childUl, err = l.ListChildResource(ctx, rl)
 		if err != nil {
 			return nil, err
 		}
+		el = append(el, childEl...)
+		ul = append(ul, childUl...)
 	}
 
 	if !l.IncludeManaged {
@@ -172,13 +622,18 @@ func (l *Lister) List(ctx context.Context, predicate string) (*ListResult, error
 	}
 
 	if l.IncludeResourceGroup {
+		listStatsFrom(ctx).setPhase("listing resource groups", len(rl))
 		rgs := map[string]AzureResource{}
 		for _, res := range rl {
 			root := res.Id.RootScope()
 			if rg, ok := root.(*armid.ResourceGroup); ok {
 				if _, ok := rgs[strings.ToUpper(rg.String())]; !ok {
 					// Get the properties of the rg
-					resp, err := l.Client.resourceGroup.Get(ctx, rg.Name, nil)
+					client, err := l.Client.resourceGroupsClient(rg.SubscriptionId)
+					if err != nil {
+						return nil, fmt.Errorf("new resource group client: %v", err)
+					}
+					resp, err := client.Get(ctx, rg.Name, nil)
 					if err != nil {
 						return nil, fmt.Errorf("getting resource group: %w", err)
 					}
@@ -200,6 +655,7 @@ func (l *Lister) List(ctx context.Context, predicate string) (*ListResult, error
 					rgs[strings.ToUpper(rg.String())] = AzureResource{
 						Id:         id,
 						Properties: props,
+						APIVersion: l.versionOf(ctx, id),
 					}
 				}
 			}
@@ -214,36 +670,263 @@ func (l *Lister) List(ctx context.Context, predicate string) (*ListResult, error
 		rl = append(rgl, rl...)
 	}
 
-	if len(l.ExtensionResourceTypes) != 0 {
+	if l.IncludeAncestors {
+		l.Debug("Listing management group ancestors")
+		listStatsFrom(ctx).setPhase("listing management group ancestors", len(rl))
+		mgs, mel, err := l.ListAncestors(ctx, rl)
+		if err != nil {
+			return nil, err
+		}
+		rl = append(mgs, rl...)
+		el = append(el, mel...)
+	}
+
+	if l.IncludeTenantResources {
+		l.Debug("Listing tenant resources")
+		listStatsFrom(ctx).setPhase("listing tenant resources", len(rl))
+		trl, tel, tul, err := l.ListTenantResources(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// The management groups might have been listed as ancestors.
+		listed := map[string]bool{}
+		for _, res := range rl {
+			listed[strings.ToUpper(res.Id.String())] = true
+		}
+		for _, res := range trl {
+			if !listed[strings.ToUpper(res.Id.String())] {
+				rl = append(rl, res)
+			}
+		}
+		el = append(el, tel...)
+		ul = append(ul, tul...)
+	}
+
+	if len(l.ExtensionResourceTypes) != 0 && !l.reachTop(len(rl)) {
 		l.Debug("Listing extension resources")
-		var extEl []ListError
-		rl, extEl, err = l.ListExtensionResource(ctx, rl)
+		listStatsFrom(ctx).setPhase("listing extension resources", len(rl))
+		var (
+			extEl []ListError
+			extUl []UnparseableResource
+		)
+		rl, extEl, extUl, err = l.ListExtensionResource(ctx, rl)
 		if err != nil {
 			return nil, err
 		}
 		el = append(el, extEl...)
+		ul = append(ul, extUl...)
 	}
 
-	l.Info("List ends", "list count", len(rl))
+	if l.reachTop(len(rl)) {
+		rl = rl[:l.Top]
+	}
+
+	for i := range rl {
+		rl[i].SubscriptionId = subscriptionOf(rl[i].Id)
+	}
+
+	endStats := l.Client.RequestStats()
+	requests := RequestStats{
+		ARGRequests: endStats.ARGRequests - startStats.ARGRequests,
+		ARMRequests: endStats.ARMRequests - startStats.ARMRequests,
+	}
+
+	l.Info("List ends", "list count", len(rl), "ARG requests", requests.ARGRequests, "ARM requests", requests.ARMRequests)
+
+	schemaInfo := l.schemaInfo()
 
 	return &ListResult{
-		Resources: rl,
-		Errors:    el,
+		Resources:   rl,
+		Errors:      el,
+		Unparseable: ul,
+		NotFound:    notFound.list(),
+		Rows:        tracked.Rows,
+		Requests:    requests,
+		Schema:      &schemaInfo,
 	}, nil
 }
 
-func (l *Lister) ListTrackedResources(ctx context.Context, predicate string) ([]AzureResource, error) {
-	const top int32 = 1000
+// GetResources reads the resources of the ids from ARM, by the api-versions of their resource types in the ARM schema.
+// The ids failing to parse are returned as the Unparseable, while the ones failing to read (e.g. deleted since) are returned as the Errors.
+func (l *Lister) GetResources(ctx context.Context, ids []string) (*ListResult, error) {
+	var (
+		result ListResult
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+	)
+	sem := make(chan struct{}, l.parallelism(ctx))
+	seen := map[string]bool{}
+	for _, rawId := range ids {
+		if seen[strings.ToUpper(rawId)] {
+			continue
+		}
+		seen[strings.ToUpper(rawId)] = true
+		id, err := l.ParseResourceId(rawId)
+		if err != nil {
+			result.Unparseable = append(result.Unparseable, UnparseableResource{Id: rawId, Message: err.Error()})
+			continue
+		}
+		version := l.versionOf(ctx, id)
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			props, err := l.Client.resource.Get(ctx, id.String(), version)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				result.Errors = append(result.Errors, newListError(id.String(), version, err))
+				return
+			}
+			result.Resources = append(result.Resources, AzureResource{
+				Id:         id,
+				Properties: props,
+				APIVersion: version,
+			})
+		}()
+	}
+	wg.Wait()
+	sort.Slice(result.Resources, func(i, j int) bool {
+		return result.Resources[i].Id.String() < result.Resources[j].Id.String()
+	})
+	sort.Slice(result.Errors, func(i, j int) bool {
+		return result.Errors[i].Endpoint < result.Errors[j].Endpoint
+	})
+	return &result, nil
+}
+
+// ListChildrenOf recursively lists the child resources of the resource id, based on the ARM schema tree, without any ARG query.
+// The resource itself is not included in the result. The recursion is bounded by the MaxDepth, RecurseOnlyTypes and SkipChildTypes, same as the recursive listing in List.
+func (l *Lister) ListChildrenOf(ctx context.Context, resourceId string) (*ListResult, error) {
+	startStats := l.Client.RequestStats()
+
+	id, err := l.ParseResourceId(resourceId)
+	if err != nil {
+		return nil, fmt.Errorf("parsing resource id %q: %v", resourceId, err)
+	}
+
+	if err := l.loadLiveSchema(ctx); err != nil {
+		return nil, fmt.Errorf("loading the live schema: %v", err)
+	}
+	rt := ResourceType(id)
+	if _, ok := l.schemaEntry(rt); !ok {
+		return nil, fmt.Errorf("resource type %s is not known by the ARM schema", rt)
+	}
+
+	rl, el, ul, err := l.ListChildResource(ctx, []AzureResource{{Id: id}})
+	if err != nil {
+		return nil, err
+	}
+	var children []AzureResource
+	for _, res := range rl {
+		if strings.EqualFold(res.Id.String(), id.String()) {
+			continue
+		}
+		res.SubscriptionId = subscriptionOf(res.Id)
+		children = append(children, res)
+	}
+
+	endStats := l.Client.RequestStats()
+	schemaInfo := l.schemaInfo()
+	return &ListResult{
+		Resources:   children,
+		Errors:      el,
+		Unparseable: ul,
+		Requests: RequestStats{
+			ARGRequests: endStats.ARGRequests - startStats.ARGRequests,
+			ARMRequests: endStats.ARMRequests - startStats.ARMRequests,
+		},
+		Schema: &schemaInfo,
+	}, nil
+}
+
+// maxARGShards is the maximum number of shards that an oversized ARG query is split into.
+const maxARGShards = 64
+
+func (l *Lister) ListTrackedResources(ctx context.Context, predicate string) (*ListResult, error) {
+	return l.listTrackedResourcesSharded(ctx, predicate, 1, 0)
+}
+
+// listTrackedResourcesSharded lists the shard of the ARG query. If ARG rejects the query as its response is too large, the shard is split into two by the hash of the resource id, until the maxARGShards is reached.
+// The query is not split if it skips records (see ARGSkip), or produces the generic rows (e.g. "true | summarize count() by type"), whose aggregations can't be merged across the shards.
+// In which case, the rejection error is returned.
+func (l *Lister) listTrackedResourcesSharded(ctx context.Context, predicate string, shards, shard int) (*ListResult, error) {
+	result, err := l.listTrackedResources(ctx, predicate, shards, shard)
+	if err == nil || !isPayloadTooLarge(err) || shards*2 > maxARGShards {
+		return result, err
+	}
+	if l.ARGSkip > 0 {
+		l.Warn("ARG response is too large, while the query can't be split with the ARG skip", "predicate", predicate, "skip", l.ARGSkip)
+		return nil, err
+	}
+
+	l.Info("ARG response is too large, splitting the query", "predicate", predicate, "shards", shards*2)
+	var results []*ListResult
+	for _, i := range []int{shard, shard + shards} {
+		result, serr := l.listTrackedResourcesSharded(ctx, predicate, shards*2, i)
+		if serr != nil {
+			if isPayloadTooLarge(serr) {
+				return nil, err
+			}
+			return nil, serr
+		}
+		if len(result.Rows) != 0 {
+			l.Warn("ARG response is too large, while the query can't be split as it produces generic rows", "predicate", predicate)
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	result = MergeListResults(results...)
+	if l.reachTop(len(result.Resources)) {
+		result.Resources = result.Resources[:l.Top]
+	}
+	// The resources are ordered by their ids byte-wise, so that the order is stable regardless of the locale.
+	sort.Slice(result.Resources, func(i, j int) bool {
+		return result.Resources[i].Id.String() < result.Resources[j].Id.String()
+	})
+	return result, nil
+}
+
+func isPayloadTooLarge(err error) bool {
+	var respErr *azcore.ResponseError
+	return errors.As(err, &respErr) && respErr.ErrorCode == "ResponsePayloadTooLarge"
+}
+
+// argQuery returns the ARG query of the predicate on the table, for the shard (out of the shards) if shards is greater than 1.
+// The records are sharded and ordered by the id before the predicate, as the predicate might project away the id (e.g. "true | summarize count() by type"),
+// whose records are kept as the ListResult.Rows.
+func argQuery(table, predicate string, shards, shard int) string {
+	query := table
+	if shards > 1 {
+		query += fmt.Sprintf(" | where hash(id, %d) == %d", shards, shard)
+	}
+	return query + fmt.Sprintf(" | order by id desc | where %s", predicate)
+}
+
+// listTrackedResources lists the resources of the shard (out of the shards) of the ARG query, which is not sharded if shards is 1.
+func (l *Lister) listTrackedResources(ctx context.Context, predicate string, shards, shard int) (*ListResult, error) {
+	top := l.ARGPageSize
 
-	query := fmt.Sprintf("%s | where %s | order by id desc", l.ARGTable, predicate)
+	query := argQuery(l.ARGTable, predicate, shards, shard)
 	queryReq := armresourcegraph.QueryRequest{
 		Query: &query,
 		Options: &armresourcegraph.QueryRequestOptions{
 			ResultFormat:             ptr(armresourcegraph.ResultFormatObjectArray),
 			Top:                      ptr(top),
 			AuthorizationScopeFilter: l.ARGAuthorizationScopeFilter,
+			AllowPartialScopes:       &l.ARGAllowPartialScopes,
 		},
-		Subscriptions: []*string{&l.SubscriptionId},
+	}
+	// The query with the skip is never sharded (see listTrackedResourcesSharded).
+	skip := l.ARGSkip
+	if skip > 0 {
+		queryReq.Options.Skip = ptr(skip)
+	}
+	if l.SubscriptionId != "" {
+		queryReq.Subscriptions = []*string{&l.SubscriptionId}
 	}
 
 	resp, err := l.Client.resourceGraph.Resources(ctx, queryReq, nil)
@@ -251,19 +934,58 @@ func (l *Lister) ListTrackedResources(ctx context.Context, predicate string) ([]
 		return nil, fmt.Errorf("executing ARG query %q: %w", query, err)
 	}
 
-	var rl []AzureResource
+	var (
+		rl     []AzureResource
+		el     []ListError
+		ul     []UnparseableResource
+		rows   []map[string]interface{}
+		record int
+	)
 
+	// collectResource collects the records of a page. Record level failures are recorded as list errors, without failing the rest.
 	collectResource := func(resp armresourcegraph.QueryResponse) error {
-		for _, resource := range resp.Data.([]interface{}) {
-			resource := resource.(map[string]interface{})
-			id := resource["id"].(string)
-			azureId, err := armid.ParseResourceId(id)
+		records, ok := resp.Data.([]interface{})
+		if !ok {
+			return fmt.Errorf("unexpected ARG response data of type %T", resp.Data)
+		}
+		before := len(rl)
+		defer func() {
+			listStatsFrom(ctx).discover(len(rl) - before)
+		}()
+		for _, v := range records {
+			record++
+			addListError := func(format string, a ...interface{}) {
+				el = append(el, ListError{
+					Endpoint: fmt.Sprintf("%s (record %d)", query, record),
+					Message:  fmt.Sprintf(format, a...),
+					Payload:  v,
+				})
+			}
+			resource, ok := v.(map[string]interface{})
+			if !ok {
+				addListError("record is not an object")
+				continue
+			}
+			idraw, ok := resource["id"]
+			if !ok {
+				rows = append(rows, resource)
+				continue
+			}
+			id, ok := idraw.(string)
+			if !ok {
+				addListError("record id is not a string")
+				continue
+			}
+			azureId, err := l.ParseResourceId(id)
 			if err != nil {
-				return fmt.Errorf("parsing resource id %s: %v", id, err)
+				l.Warn("Failed to parse resource id", "id", id, "error", err)
+				ul = append(ul, UnparseableResource{Id: id, Properties: resource, Message: err.Error()})
+				continue
 			}
 			rl = append(rl, AzureResource{
 				Id:         azureId,
 				Properties: resource,
+				APIVersion: l.versionOf(ctx, azureId),
 			})
 		}
 		return nil
@@ -278,12 +1000,12 @@ func (l *Lister) ListTrackedResources(ctx context.Context, predicate string) ([]
 		total = *resp.TotalRecords
 	}
 
-	var count int64
+	count := int64(skip)
 	if resp.Count != nil {
-		count = *resp.Count
+		count += *resp.Count
 	}
 
-	var skip int32 = top
+	skip += top
 
 	var skipToken string
 	if resp.SkipToken != nil {
@@ -291,7 +1013,7 @@ func (l *Lister) ListTrackedResources(ctx context.Context, predicate string) ([]
 	}
 
 	// Should we check for the existance of skipToken instead? But can't find any document states that the last response won't return the skipToken.
-	for count < total {
+	for count < total && !l.reachTop(len(rl)) {
 		queryReq.Options.Skip = &skip
 		queryReq.Options.SkipToken = &skipToken
 
@@ -316,16 +1038,206 @@ func (l *Lister) ListTrackedResources(ctx context.Context, predicate string) ([]
 		}
 	}
 
+	if l.reachTop(len(rl)) {
+		rl = rl[:l.Top]
+	}
+
 	sort.Slice(rl, func(i, j int) bool {
 		return rl[i].Id.String() < rl[j].Id.String()
 	})
 
-	return rl, nil
+	return &ListResult{
+		Resources:   rl,
+		Errors:      el,
+		Unparseable: ul,
+		Rows:        rows,
+	}, nil
+}
+
+// ListAncestors lists the management groups that the subscriptions of the given resources belong to, ordered from the root management group down to the direct parents.
+// The management group chains are queried from ARG, while the management groups are got from Microsoft.Management.
+// A management group that fails to get (e.g. no permission on the parent management groups) is recorded as a ListError.
+func (l *Lister) ListAncestors(ctx context.Context, rl []AzureResource) ([]AzureResource, []ListError, error) {
+	subs := map[string]bool{}
+	var subscriptions []*string
+	for _, res := range rl {
+		sub := subscriptionOf(res.Id)
+		if sub == "" || subs[strings.ToLower(sub)] {
+			continue
+		}
+		subs[strings.ToLower(sub)] = true
+		subscriptions = append(subscriptions, ptr(sub))
+	}
+	if len(subscriptions) == 0 {
+		return nil, nil, nil
+	}
+
+	query := "ResourceContainers | where type =~ 'microsoft.resources/subscriptions' | project chain = properties.managementGroupAncestorsChain"
+
+	// The depth of each management group, where the root one has the largest depth as the chains are from the direct parent to the root.
+	depths := map[string]int{}
+	var skipToken *string
+	for {
+		resp, err := l.Client.resourceGraph.Resources(ctx, armresourcegraph.QueryRequest{
+			Query: &query,
+			Options: &armresourcegraph.QueryRequestOptions{
+				ResultFormat: ptr(armresourcegraph.ResultFormatObjectArray),
+				Top:          ptr(int32(1000)),
+				SkipToken:    skipToken,
+			},
+			Subscriptions: subscriptions,
+		}, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("executing ARG query %q: %w", query, err)
+		}
+		rows, _ := resp.Data.([]interface{})
+		for _, row := range rows {
+			m, ok := row.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			chain, _ := m["chain"].([]interface{})
+			for i, mg := range chain {
+				mgm, ok := mg.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				name, _ := mgm["name"].(string)
+				if name == "" {
+					continue
+				}
+				if depth := len(chain) - i; depth > depths[name] {
+					depths[name] = depth
+				}
+			}
+		}
+		if resp.SkipToken == nil || *resp.SkipToken == "" {
+			break
+		}
+		skipToken = resp.SkipToken
+	}
+
+	version := l.versionOf(ctx, &armid.ManagementGroup{})
+	var (
+		mgs []AzureResource
+		el  []ListError
+	)
+	for name := range depths {
+		id := &armid.ManagementGroup{Name: name}
+		props, err := l.Client.resource.Get(ctx, id.String(), version)
+		if err != nil {
+			el = append(el, newListError(strings.ToUpper(id.String()), version, fmt.Errorf("getting management group %s: %w", name, err)))
+			continue
+		}
+		mgs = append(mgs, AzureResource{
+			Id:         id,
+			Properties: props,
+			APIVersion: version,
+		})
+	}
+	sort.Slice(mgs, func(i, j int) bool {
+		ni, nj := mgs[i].Id.(*armid.ManagementGroup).Name, mgs[j].Id.(*armid.ManagementGroup).Name
+		if depths[ni] != depths[nj] {
+			return depths[ni] > depths[nj]
+		}
+		return ni < nj
+	})
+	sort.Slice(el, func(i, j int) bool {
+		return el[i].Endpoint < el[j].Endpoint
+	})
+	return mgs, el, nil
+}
+
+// timesExpand is the $expand of the ARM (Microsoft.Resources) list calls for ExpandTimes, which is not supported by the list calls of the other resource providers.
+const timesExpand = "createdTime,changedTime"
+
+// metadataExpand is the $expand of the list calls of the ExpandMetadataTypes.
+const metadataExpand = "createdTime,changedTime,provisioningState"
+
+// withMetadataExpand returns the list query parameters with the metadataExpand set as the "$expand" of the types, unless they have one already.
+func withMetadataExpand(params map[string]url.Values, types []string) map[string]url.Values {
+	if len(types) == 0 {
+		return params
+	}
+	out := map[string]url.Values{}
+	for k, v := range params {
+		out[k] = v
+	}
+	for _, rt := range types {
+		key := rt
+		for k := range out {
+			if strings.EqualFold(k, rt) {
+				key = k
+			}
+		}
+		if out[key].Get("$expand") != "" {
+			continue
+		}
+		query := url.Values{}
+		for k, v := range out[key] {
+			query[k] = v
+		}
+		query.Set("$expand", metadataExpand)
+		out[key] = query
+	}
+	return out
+}
+
+// expandTimes populates the created and changed times into the bodies of the resources, which are returned by paging through the ARM list calls of each of their subscriptions.
+func (l *Lister) expandTimes(ctx context.Context, rl []AzureResource) error {
+	bySub := map[string]map[string]AzureResource{}
+	for _, res := range rl {
+		sub := strings.ToLower(subscriptionOf(res.Id))
+		if sub == "" {
+			continue
+		}
+		if bySub[sub] == nil {
+			bySub[sub] = map[string]AzureResource{}
+		}
+		bySub[sub][strings.ToUpper(res.Id.String())] = res
+	}
+
+	for sub, resources := range bySub {
+		client, err := l.Client.resourcesClient(sub)
+		if err != nil {
+			return fmt.Errorf("new resources client: %v", err)
+		}
+		pager := client.NewListPager(&sdkARMResources.ClientListOptions{Expand: ptr(timesExpand)})
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return fmt.Errorf("listing resources of subscription %s: %w", sub, err)
+			}
+			for _, v := range page.Value {
+				if v.ID == nil {
+					continue
+				}
+				res, ok := resources[strings.ToUpper(*v.ID)]
+				if !ok || res.Properties == nil {
+					continue
+				}
+				if v.CreatedTime != nil {
+					res.Properties["createdTime"] = v.CreatedTime.Format(time.RFC3339)
+				}
+				if v.ChangedTime != nil {
+					res.Properties["changedTime"] = v.ChangedTime.Format(time.RFC3339)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// reachTop tells whether the count of the listed resources reaches the cap, if any.
+func (l *Lister) reachTop(n int) bool {
+	return l.Top > 0 && n >= l.Top
 }
 
 // ListChildResource will recursively list the direct child resources of each given resource, and returns the passed resource list with their child resources appended.
-// Some resource type might fail to list, which will be returned in the ListError slice.
-func (l *Lister) ListChildResource(ctx context.Context, rl []AzureResource) (outRl []AzureResource, outEl []ListError, err error) {
+// The listings run as a pipeline, where the child resources of a listed resource are queued as soon as it is discovered, instead of waiting for the other listings of the same level.
+// The recursion stops at the MaxDepth level of children, if set.
+// Some resource type might fail to list, which will be returned in the ListError slice. The listed items whose ids fail to parse are returned in the UnparseableResource slice.
+func (l *Lister) ListChildResource(ctx context.Context, rl []AzureResource) (outRl []AzureResource, outEl []ListError, outUl []UnparseableResource, err error) {
 	rset := map[string]AzureResource{}
 	for _, res := range rl {
 		rset[strings.ToUpper(res.Id.String())] = res
@@ -333,48 +1245,133 @@ func (l *Lister) ListChildResource(ctx context.Context, rl []AzureResource) (out
 
 	eset := map[string]ListError{}
 
-	for len(rl) != 0 {
-		wp := workerpool.NewWorkPool(l.Parallelism)
-
-		var (
-			nrl []AzureResource
-			nel []ListError
-		)
-		wp.Run(func(i interface{}) error {
-			l := i.(ListResult)
-			nrl = append(nrl, l.Resources...)
-			nel = append(nel, l.Errors...)
-			return nil
-		})
+	var managedRGs map[string]string
+	if l.SkipManagedResourceGroups {
+		managedRGs, err = l.managedResourceGroups(ctx, rl)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("listing managed resource groups: %v", err)
+		}
+	}
 
-		for _, res := range rl {
-			l.Debug("Listing direct child resource", "parent", res.Id.String())
-			l.listDirectChildResource(ctx, wp, res)
+	var (
+		mu   sync.Mutex
+		cond = sync.NewCond(&mu)
+		// queue is the child resource listings to run, while pending counts both the queued and the running ones.
+		queue   []childListing
+		pending int
+		runErr  error
+	)
+	// recursable tells for each resource whether the RecursePredicate allows recursing into it, which is called without the mu held.
+	recursable := func(rl []AzureResource) []bool {
+		out := make([]bool, len(rl))
+		for i, res := range rl {
+			out[i] = l.RecursePredicate == nil || l.RecursePredicate(res)
 		}
+		return out
+	}
+	// enqueue queues the child resource listings of the resource of the depth, with the mu held.
+	enqueue := func(res AzureResource, depth int, recurse bool) {
+		if l.MaxDepth > 0 && depth >= l.MaxDepth {
+			return
+		}
+		if managedBy, ok := managedResourceGroupOf(managedRGs, res.Id); ok {
+			l.Debug("Skipping the child resources in the managed resource group", "parent", res.Id.String(), "managed by", managedBy)
+			return
+		}
+		if !recurse {
+			l.Debug("Skipping the child resources by the recurse predicate", "parent", res.Id.String())
+			return
+		}
+		l.Debug("Listing direct child resource", "parent", res.Id.String())
+		listings := l.childListings(ctx, res, depth+1)
+		queue = append(queue, listings...)
+		pending += len(listings)
+	}
 
-		if err := wp.Done(); err != nil {
-			return nil, nil, err
+	rootRecurse := recursable(rl)
+	mu.Lock()
+	if !l.reachTop(len(rset)) {
+		for i, res := range rl {
+			enqueue(res, 0, rootRecurse[i])
 		}
+	}
+	mu.Unlock()
+
+	worker := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for {
+			for len(queue) == 0 && pending > 0 && runErr == nil {
+				cond.Wait()
+			}
+			if len(queue) == 0 || runErr != nil {
+				return
+			}
+			job := queue[0]
+			queue = queue[1:]
+
+			mu.Unlock()
+			var (
+				result ListResult
+				err    error
+			)
+			if l.listChild != nil {
+				result, err = l.listChild(ctx, job)
+			} else {
+				result, err = l.listResource(ctx, job.parent, job.crt, job.version, nil)
+			}
+			var recurse []bool
+			if err == nil {
+				recurse = recursable(result.Resources)
+			}
+			mu.Lock()
 
-		// Add new child resources to the resource set, also put them into the working list for new iteration.
-		rl = []AzureResource{}
-		for _, res := range nrl {
-			key := strings.ToUpper(res.Id.String())
-			if _, ok := rset[key]; ok {
-				continue
+			pending--
+			if err != nil {
+				runErr = err
+				// The queued listings are dropped, which are no more counted as queued by the stats.
+				listStatsFrom(ctx).drop(len(queue))
+				pending -= len(queue)
+				queue = nil
+				cond.Broadcast()
+				return
 			}
-			rl = append(rl, res)
-			rset[key] = res
-		}
-		for _, le := range nel {
-			key := strings.ToUpper(le.Endpoint)
-			if _, ok := eset[key]; ok {
-				continue
+			// Add new child resources to the resource set, and queue their child resource listings right away.
+			for i, res := range result.Resources {
+				key := strings.ToUpper(res.Id.String())
+				if _, ok := rset[key]; ok {
+					continue
+				}
+				rset[key] = res
+				if !l.reachTop(len(rset)) {
+					enqueue(res, job.depth, recurse[i])
+				}
 			}
-			eset[key] = le
+			for _, le := range result.Errors {
+				key := strings.ToUpper(le.Endpoint)
+				if _, ok := eset[key]; ok {
+					continue
+				}
+				eset[key] = le
+			}
+			outUl = append(outUl, result.Unparseable...)
+			cond.Broadcast()
 		}
 	}
 
+	var wg sync.WaitGroup
+	for i := 0; i < l.parallelism(ctx); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			worker()
+		}()
+	}
+	wg.Wait()
+	if runErr != nil {
+		return nil, nil, nil, runErr
+	}
+
 	// Sort rset and eset and return
 	for _, res := range rset {
 		outRl = append(outRl, res)
@@ -388,14 +1385,14 @@ func (l *Lister) ListChildResource(ctx context.Context, rl []AzureResource) (out
 	sort.Slice(outEl, func(i, j int) bool {
 		return outEl[i].Endpoint < outEl[j].Endpoint
 	})
-	return outRl, outEl, nil
+	return outRl, outEl, outUl, nil
 }
 
 // ListExtensionResource will list for a list of extension resource types of each given resource, and returns the passed resource list with their child resources appended.
-// Some resource type might fail to list, which will be returned in the ListError slice.
-func (l *Lister) ListExtensionResource(ctx context.Context, rl []AzureResource) (outRl []AzureResource, outEl []ListError, err error) {
+// Some resource type might fail to list, which will be returned in the ListError slice. The listed items whose ids fail to parse are returned in the UnparseableResource slice.
+func (l *Lister) ListExtensionResource(ctx context.Context, rl []AzureResource) (outRl []AzureResource, outEl []ListError, outUl []UnparseableResource, err error) {
 	if len(l.ExtensionResourceTypes) == 0 {
-		return rl, nil, nil
+		return rl, nil, nil, nil
 	}
 
 	rset := map[string]AzureResource{}
@@ -405,16 +1402,18 @@ func (l *Lister) ListExtensionResource(ctx context.Context, rl []AzureResource)
 
 	eset := map[string]ListError{}
 
-	wp := workerpool.NewWorkPool(l.Parallelism)
+	wp := workerpool.NewWorkPool(l.parallelism(ctx))
 
 	var (
 		nrl []AzureResource
 		nel []ListError
+		nul []UnparseableResource
 	)
 	wp.Run(func(i interface{}) error {
 		l := i.(ListResult)
 		nrl = append(nrl, l.Resources...)
 		nel = append(nel, l.Errors...)
+		nul = append(nul, l.Unparseable...)
 		return nil
 	})
 
@@ -424,16 +1423,27 @@ func (l *Lister) ListExtensionResource(ctx context.Context, rl []AzureResource)
 	}
 
 	if err := wp.Done(); err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
-	// Add new child resources to the resource set
+	// Add new extension resources to the resource set, where the ones listed under multiple resources are merged with all these resources referenced.
+	referencedBy := map[string][]armid.ResourceId{}
 	for _, res := range nrl {
 		key := strings.ToUpper(res.Id.String())
+		if refs, ok := referencedBy[key]; ok {
+			referencedBy[key] = append(refs, res.DiscoveredThrough)
+			continue
+		}
 		if _, ok := rset[key]; ok {
 			continue
 		}
 		rset[key] = res
+		referencedBy[key] = []armid.ResourceId{res.DiscoveredThrough}
+	}
+	for key, refs := range referencedBy {
+		if len(refs) > 1 {
+			rset[key] = withReferencedBy(rset[key], refs)
+		}
 	}
 	for _, le := range nel {
 		key := strings.ToUpper(le.Endpoint)
@@ -442,6 +1452,7 @@ func (l *Lister) ListExtensionResource(ctx context.Context, rl []AzureResource)
 		}
 		eset[key] = le
 	}
+	outUl = nul
 
 	// Sort rset and eset and return
 	for _, res := range rset {
@@ -456,41 +1467,213 @@ func (l *Lister) ListExtensionResource(ctx context.Context, rl []AzureResource)
 	sort.Slice(outEl, func(i, j int) bool {
 		return outEl[i].Endpoint < outEl[j].Endpoint
 	})
-	return outRl, outEl, nil
+	return outRl, outEl, outUl, nil
 }
 
-// listDirectChildResource list one resource's direct child resources based on the ARM schema resource type hierarchy.
-func (l *Lister) listDirectChildResource(ctx context.Context, wp workerpool.WorkPool, res AzureResource) {
+// childListing is a listing of the child resources of a resource type (e.g. "subnets") under the parent resource, whose listed resources are of the depth.
+type childListing struct {
+	parent  AzureResource
+	crt     string
+	version string
+	depth   int
+}
+
+// childListings returns the listings of one resource's direct child resources based on the ARM schema resource type hierarchy.
+func (l *Lister) childListings(ctx context.Context, res AzureResource, depth int) []childListing {
 	rt := strings.ToUpper(strings.TrimLeft(res.Id.RouteScopeString(), "/"))
-	schemaEntry := l.ARMSchemaTree[rt]
+	schemaEntry, _ := l.schemaEntry(rt)
 	if schemaEntry == nil {
-		return
+		return nil
 	}
 
+	var listings []childListing
 	for crt, entry := range schemaEntry.Children {
-		crt, entry := crt, entry
-		wp.AddTask(func() (interface{}, error) {
-			return l.listResource(ctx, res, crt, entry.Versions[len(entry.Versions)-1], nil)
+		if !l.recurseInto(rt + "/" + crt) {
+			continue
+		}
+		listStatsFrom(ctx).enqueue()
+		listings = append(listings, childListing{
+			parent:  res,
+			crt:     crt,
+			version: l.apiVersion(ctx, rt+"/"+crt, entry.Versions),
+			depth:   depth,
 		})
 	}
-	return
+	return listings
+}
+
+// DefaultSkipChildTypes are the child resource types whose list endpoints are known to fail regardless of the parent resource, which are skipped by Recursive unless NoDefaultSkips is set.
+// They either have no list endpoint (e.g. the singletons), require extra query parameters, or respond with something other than a resource list (e.g. a plain JSON array).
+var DefaultSkipChildTypes = []string{
+	"Microsoft.Insights/components/Annotations",
+	"Microsoft.Insights/components/ProactiveDetectionConfigs",
+	"Microsoft.Insights/components/analyticsItems",
+	"Microsoft.Insights/components/currentbillingfeatures",
+	"Microsoft.Insights/components/exportconfiguration",
+	"Microsoft.Insights/components/favorites",
+	"Microsoft.Insights/components/linkedStorageAccounts",
+	"Microsoft.Insights/components/myanalyticsItems",
+	"Microsoft.Insights/components/pricingPlans",
+	"Microsoft.KeyVault/vaults/accessPolicies",
+}
+
+// recurseInto tells whether the child resource type is listed by the recursion, according to the RecurseOnlyTypes, SkipChildTypes and DefaultSkipChildTypes.
+func (l *Lister) recurseInto(rt string) bool {
+	if len(l.RecurseOnlyTypes) != 0 && !matchTypePatterns(l.RecurseOnlyTypes, rt) {
+		return false
+	}
+	if !l.NoDefaultSkips && matchTypePatterns(DefaultSkipChildTypes, rt) {
+		return false
+	}
+	return !matchTypePatterns(l.SkipChildTypes, rt)
+}
+
+// matchTypePatterns tells whether any of the case insensitive glob patterns matches the resource type, or any of its ancestor types.
+func matchTypePatterns(patterns []string, rt string) bool {
+	segs := strings.Split(strings.ToLower(rt), "/")
+	for _, pattern := range patterns {
+		for i := 2; i <= len(segs); i++ {
+			if ok, _ := path.Match(strings.ToLower(pattern), strings.Join(segs[:i], "/")); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withReferencedBy returns the extension resource with the ReferencedBy set to the resources it is listed under, in order, and the DiscoveredThrough set accordingly.
+func withReferencedBy(res AzureResource, refs []armid.ResourceId) AzureResource {
+	refs = append([]armid.ResourceId{}, refs...)
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].String() < refs[j].String()
+	})
+	// The same resource might be passed in multiple times.
+	var dedup []armid.ResourceId
+	for _, ref := range refs {
+		if len(dedup) == 0 || !dedup[len(dedup)-1].Equal(ref) {
+			dedup = append(dedup, ref)
+		}
+	}
+	refs = dedup
+	res.ReferencedBy = refs
+	res.DiscoveredThrough = refs[0]
+	for _, ref := range refs {
+		if res.Scope != nil && ref.Equal(res.Scope) {
+			res.DiscoveredThrough = ref
+			break
+		}
+	}
+	return res
+}
+
+// referencesOf returns the resources that the extension resource is listed under.
+func referencesOf(res AzureResource) []armid.ResourceId {
+	if len(res.ReferencedBy) != 0 {
+		return res.ReferencedBy
+	}
+	return []armid.ResourceId{res.DiscoveredThrough}
 }
 
 // listExtensionResource list one resource's extension resources specified.
+// Only the listings to run are counted as queued by the stats, i.e. not the ones skipped as not applicable, or failed to resolve the api-version.
 func (l *Lister) listExtensionResource(ctx context.Context, wp workerpool.WorkPool, res AzureResource) {
 	for _, rt := range l.ExtensionResourceTypes {
 		rt := rt
+		version, err := l.extensionVersion(ctx, rt.Type, res)
+		if err != nil {
+			wp.AddTask(func() (interface{}, error) {
+				return nil, err
+			})
+			continue
+		}
+		if version == "" {
+			l.Debug("Skip listing the extension resource type not applicable to the resource", "id", res.Id.String(), "extension resource type", rt.Type)
+			continue
+		}
+		listStatsFrom(ctx).enqueue()
 		wp.AddTask(func() (interface{}, error) {
-			entry, ok := l.ARMSchemaTree[strings.ToUpper(rt.Type)]
-			if !ok {
-				return nil, fmt.Errorf("no schema entry found for resource type %s", rt.Type)
-			}
-			return l.listResource(ctx, res, "providers/"+rt.Type, entry.Versions[len(entry.Versions)-1], rt.Filter)
+			return l.listResource(ctx, res, "providers/"+rt.Type, version, rt.Filter)
 		})
 	}
 	return
 }
 
+// extensionVersion returns the api-version to list the extension resource type under the resource, or empty string if the built-in extension resource type is not applicable to the resource.
+func (l *Lister) extensionVersion(ctx context.Context, rt string, res AzureResource) (string, error) {
+	if version, ok := l.pinnedAPIVersion(ctx, rt); ok {
+		return version, nil
+	}
+	if f, builtin := builtinExtensionVersions[strings.ToUpper(rt)]; builtin {
+		return f(res.Id), nil
+	}
+	entry, ok := l.schemaEntry(rt)
+	if !ok {
+		return "", fmt.Errorf("no schema entry found for resource type %s", rt)
+	}
+	return l.VersionStrategy.Pick(entry.Versions), nil
+}
+
+// versionOf returns the api-version of the resource type of the given id (see apiVersion), or empty string if the type is unknown.
+func (l *Lister) versionOf(ctx context.Context, id armid.ResourceId) string {
+	rt := ResourceType(id)
+	var versions []string
+	if entry, ok := l.schemaEntry(rt); ok {
+		versions = entry.Versions
+	}
+	return l.apiVersion(ctx, rt, versions)
+}
+
+// loadLiveSchema replaces the ARM schema tree with the one built from the live resource provider metadata, once, if LiveSchema is set.
+func (l *Lister) loadLiveSchema(ctx context.Context) error {
+	if !l.LiveSchema {
+		return nil
+	}
+	l.liveMu.Lock()
+	defer l.liveMu.Unlock()
+	if l.schemaLoaded {
+		return nil
+	}
+	if err := l.RefreshSchema(ctx); err != nil {
+		return err
+	}
+	l.schemaLoaded = true
+	return nil
+}
+
+// schemaEntry returns the ARM schema entry of the resource type (case insensitive).
+func (l *Lister) schemaEntry(rt string) (*ARMSchemaEntry, bool) {
+	l.schemaMu.RLock()
+	defer l.schemaMu.RUnlock()
+	entry, ok := l.ARMSchemaTree[strings.ToUpper(rt)]
+	return entry, ok
+}
+
+// schemaInfo returns the provenance of the ARM schema in use.
+func (l *Lister) schemaInfo() ARMSchemaInfo {
+	l.schemaMu.RLock()
+	defer l.schemaMu.RUnlock()
+	return l.ARMSchemaInfo
+}
+
+// ResourceType returns the resource type of the given id, e.g. "Microsoft.Network/virtualNetworks/subnets".
+func ResourceType(id armid.ResourceId) string {
+	if _, ok := id.(*armid.ResourceGroup); ok {
+		return "Microsoft.Resources/resourceGroups"
+	}
+	return id.Provider() + "/" + strings.Join(id.Types(), "/")
+}
+
+// subscriptionOf returns the subscription id that the resource belongs to, or empty string for resources out of a subscription (e.g. management groups).
+func subscriptionOf(id armid.ResourceId) string {
+	switch root := id.RootScope().(type) {
+	case *armid.ResourceGroup:
+		return root.SubscriptionId
+	case *armid.SubscriptionId:
+		return root.Id
+	}
+	return ""
+}
+
 type ResourceFilter func(res, extensionRes map[string]interface{}) bool
 
 func (l *Lister) listResource(ctx context.Context, res AzureResource, crt, version string, filter ResourceFilter) (ListResult, error) {
@@ -501,82 +1684,216 @@ func (l *Lister) listResource(ctx context.Context, res AzureResource, crt, versi
 
 	pid := res.Id.String()
 
+	provider := res.Id.Provider()
+	if strings.HasPrefix(crt, "providers/") {
+		provider, _, _ = strings.Cut(strings.TrimPrefix(crt, "providers/"), "/")
+	}
+	stats := listStatsFrom(ctx)
+	stats.start(provider)
+	defer func() {
+		stats.done(provider, len(result.Resources), len(result.Errors) != 0)
+	}()
+
 	addListError := func(pid, crt, apiVersion string, err error) {
-		result.Errors = append(result.Errors, ListError{
-			Endpoint: strings.ToUpper(pid + "/" + crt),
-			Version:  apiVersion,
-			Message:  err.Error(),
-		})
+		e := newListError(strings.ToUpper(pid+"/"+crt), apiVersion, err)
+		l.Debug("Listing failed", "endpoint", e.Endpoint, "class", ClassifyError(e), "error", err)
+		result.Errors = append(result.Errors, e)
 	}
 	l.Debug("Listing child resources by resource type", "parent", pid, "child resource type", crt, "api version", version)
-	pager := l.Client.resource.NewListChildPager(pid, crt, version)
+	options := &armresources.ClientListChildOptions{
+		ResourceType: childResourceType(res, crt),
+		Query:        l.listQuery(childResourceType(res, crt)),
+	}
+	if location, ok := res.Properties["location"].(string); ok {
+		options.Location = location
+	}
+	items, err := l.listChildItems(ctx, pid, crt, version, options)
+	// Fall back to the older api-versions, if the api-version is not supported by the endpoint, unless it is pinned.
+	var fallbacks []string
+	if _, pinned := l.pinnedAPIVersion(ctx, childResourceType(res, crt)); !pinned {
+		fallbacks = l.olderVersions(res, crt, version)
+	}
+	for err != nil && len(items) == 0 && len(fallbacks) != 0 && ClassifyError(newListError("", version, err)) == ErrorClassBadApiVersion {
+		l.Debug("Falling back to an older api version", "parent", pid, "child resource type", crt, "api version", version, "fallback api version", fallbacks[0], "error", err)
+		version, fallbacks = fallbacks[0], fallbacks[1:]
+		items, err = l.listChildItems(ctx, pid, crt, version, options)
+	}
+	if err != nil {
+		addListError(pid, crt, version, err)
+	}
+	for _, w := range items {
+		props, b, err := decodeItem(w)
+		if err != nil {
+			addListError(pid, crt, version, err)
+			continue
+		}
+
+		// Resources not meet filter are skipped
+		if filter != nil && !filter(res.Properties, props) {
+			continue
+		}
+
+		id, err := itemId(props, b)
+		if err != nil {
+			addListError(pid, crt, version, err)
+			continue
+		}
+		azureId, err := l.ParseResourceId(id)
+		if err != nil {
+			l.Warn("Failed to parse resource id", "id", id, "error", err)
+			result.Unparseable = append(result.Unparseable, UnparseableResource{Id: id, Properties: props, Message: err.Error()})
+			continue
+		}
+		azureRes := AzureResource{
+			Id:         azureId,
+			Properties: props,
+			APIVersion: version,
+		}
+		if strings.HasPrefix(crt, "providers/") {
+			azureRes.Scope = azureId.ParentScope()
+			azureRes.DiscoveredThrough = res.Id
+		}
+		result.Resources = append(result.Resources, azureRes)
+	}
+	return result, nil
+}
+
+// listChildItems lists the items of the child resource type of the parent resource, with the api-version.
+// The items listed before a failure are returned together with the error. A 404 is regarded as no item, which is recorded if Option.RecordNotFound is set.
+// So are the errors of the built-in extension resource types that mean the type is not applicable to the parent resource.
+func (l *Lister) listChildItems(ctx context.Context, pid, crt, version string, options *armresources.ClientListChildOptions) ([]*armresources.GenericResourceExpanded, error) {
+	var items []*armresources.GenericResourceExpanded
+	pager := l.Client.resource.NewListChildPager(pid, crt, version, options)
 	for pager.More() {
 		page, err := pager.NextPage(ctx)
 		if err != nil {
-			if azerr, ok := err.(*azcore.ResponseError); ok && azerr.StatusCode == http.StatusNotFound {
-				// Intentionally ignore 404 on list.
-				break
+			if azerr, ok := err.(*azcore.ResponseError); ok {
+				rt := crt
+				switch {
+				case options != nil && options.ResourceType != "":
+					rt = options.ResourceType
+				case strings.HasPrefix(pid, "providers/"):
+					rt = strings.TrimPrefix(pid, "providers/") + "/" + crt
+				}
+				// Intentionally ignore 404 on list (or the errors meaning the extension resource type is not applicable), which is only recorded if asked.
+				if azerr.StatusCode == http.StatusNotFound || builtinExtensionNotApplicable(rt, azerr.ErrorCode) {
+					notFoundRecorderFrom(ctx).record(rt, strings.ToUpper(pid+"/"+crt), azerr.ErrorCode)
+					break
+				}
 			}
-			// For other errors, record into the list result
-			addListError(pid, crt, version, err)
-			break
+			return items, err
 		}
-		for _, w := range page.Value {
-			b, err := json.Marshal(w)
-			if err != nil {
-				addListError(pid, crt, version, fmt.Errorf("marshalling %v: %v", w, err))
-				continue
-			}
-			var props map[string]interface{}
-			if err := json.Unmarshal(b, &props); err != nil {
-				addListError(pid, crt, version, fmt.Errorf("unmarshalling %v: %v", string(b), err))
-				continue
-			}
+		items = append(items, page.Value...)
+	}
+	return items, nil
+}
 
-			// Resources not meet filter are skipped
-			if filter != nil && !filter(res.Properties, props) {
-				continue
-			}
+// maxVersionFallbacks is the max number of older api-versions to fall back to, when an api-version is not supported.
+const maxVersionFallbacks = 3
 
-			idraw, ok := props["id"]
-			if !ok {
-				addListError(pid, crt, version, fmt.Errorf("no resource id found in response: %s", string(b)))
-				continue
-			}
-			id, ok := idraw.(string)
-			if !ok {
-				addListError(pid, crt, version, fmt.Errorf("resource id is not a string: %s", string(b)))
-				continue
-			}
-			azureId, err := armid.ParseResourceId(id)
-			if err != nil {
-				addListError(pid, crt, version, fmt.Errorf("parsing resource id %v: %v", id, err))
-				continue
-			}
-			result.Resources = append(result.Resources, AzureResource{
-				Id:         azureId,
-				Properties: props,
-			})
+// childResourceType returns the resource type of the child resource type (or the extension resource type, if prefixed by "providers/") under the resource.
+func childResourceType(res AzureResource, crt string) string {
+	if strings.HasPrefix(crt, "providers/") {
+		return strings.TrimPrefix(crt, "providers/")
+	}
+	return ResourceType(res.Id) + "/" + crt
+}
+
+// listQuery returns the extra query parameters of the list call on the resource type, if any.
+func (l *Lister) listQuery(rt string) url.Values {
+	for k, v := range l.ListQueryParameters {
+		if strings.EqualFold(k, rt) {
+			return v
 		}
 	}
-	return result, nil
+	return nil
+}
+
+// olderVersions returns the api-versions of the child resource type (or the extension resource type, if prefixed by "providers/") in the ARM schema,
+// that are older than the version, in descending order.
+func (l *Lister) olderVersions(res AzureResource, crt, version string) []string {
+	entry, ok := l.schemaEntry(childResourceType(res, crt))
+	if !ok {
+		return nil
+	}
+	var versions []string
+	for i := len(entry.Versions) - 1; i >= 0 && len(versions) < maxVersionFallbacks; i-- {
+		if entry.Versions[i] < version {
+			versions = append(versions, entry.Versions[i])
+		}
+	}
+	return versions
+}
+
+// decodeItem decodes an item of the list response into the resource body, together with its JSON encoding.
+func decodeItem(w interface{}) (map[string]interface{}, []byte, error) {
+	b, err := json.Marshal(w)
+	if err != nil {
+		return nil, nil, fmt.Errorf("marshalling %v: %v", w, err)
+	}
+	var props map[string]interface{}
+	if err := json.Unmarshal(b, &props); err != nil {
+		return nil, nil, fmt.Errorf("unmarshalling %v: %v", string(b), err)
+	}
+	return props, b, nil
+}
+
+// itemId returns the resource id of the resource body, whose JSON encoding is b.
+func itemId(props map[string]interface{}, b []byte) (string, error) {
+	idraw, ok := props["id"]
+	if !ok {
+		return "", fmt.Errorf("no resource id found in response: %s", string(b))
+	}
+	id, ok := idraw.(string)
+	if !ok {
+		return "", fmt.Errorf("resource id is not a string: %s", string(b))
+	}
+	return id, nil
+}
+
+// BuildARMSchemaTree builds the ARM schema tree from the ARM schema file, with the overlay files merged in order.
+// An overlay file is in the same format as the ARM schema file, where each resource type (case insensitive) adds or overrides the one in the ARM schema file.
+// A resource type with no api-version in the overlay file removes it.
+func BuildARMSchemaTree(armSchemaFile []byte, overlays ...[]byte) (ARMSchemaTree, error) {
+	armSchemas, err := parseARMSchema(armSchemaFile, overlays...)
+	if err != nil {
+		return nil, err
+	}
+	return BuildARMSchemaTreeFromTypes(armSchemas)
 }
 
-func BuildARMSchemaTree(armSchemaFile []byte) (ARMSchemaTree, error) {
+// parseARMSchema parses the ARM schema file into the resource types and their api-versions, with the overlay files merged in order.
+func parseARMSchema(armSchemaFile []byte, overlays ...[]byte) (map[string][]string, error) {
 	var armSchemas map[string][]string
 	if err := json.Unmarshal(armSchemaFile, &armSchemas); err != nil {
 		return nil, err
 	}
-
-	tree := ARMSchemaTree{}
-	level := 2
-
-	// Ensure every resoruce type starts with a provider and followed by one or more types, separated by slash(es).
-	for rt := range armSchemas {
-		if len(strings.Split(rt, "/")) == 1 {
-			return nil, fmt.Errorf("malformed resource type: %s", rt)
+	for i, overlay := range overlays {
+		var overlaySchemas map[string][]string
+		if err := json.Unmarshal(overlay, &overlaySchemas); err != nil {
+			return nil, fmt.Errorf("overlay %d: %v", i, err)
+		}
+		for rt, versions := range overlaySchemas {
+			key := strings.ToUpper(strings.TrimSuffix(rt, "/"))
+			for existing := range armSchemas {
+				if strings.ToUpper(strings.TrimSuffix(existing, "/")) == key {
+					delete(armSchemas, existing)
+				}
+			}
+			if len(versions) != 0 {
+				versions = append([]string{}, versions...)
+				sort.Strings(versions)
+				armSchemas[rt] = versions
+			}
 		}
 	}
+	return armSchemas, nil
+}
+
+// BuildARMSchemaTreeFromTypes builds the ARM schema tree from the resource types (e.g. "Microsoft.Network/virtualNetworks/subnets") and their api-versions in ascending order.
+// The map is modified during the building.
+func BuildARMSchemaTreeFromTypes(armSchemas map[string][]string) (ARMSchemaTree, error) {
+	tree := ARMSchemaTree{}
 
 	var renameRTs []string
 	// Rename resource types that has trailing slash, e.g. "Microsoft.Network/publicIPAddresses/"
@@ -597,6 +1914,21 @@ func BuildARMSchemaTree(armSchemaFile []byte) (ARMSchemaTree, error) {
 		delete(armSchemas, rt)
 	}
 
+	// Ensure every resoruce type starts with a provider and followed by one or more types, separated by slash(es).
+	// This is checked after the renaming above, as otherwise a type like "Microsoft.Foo/" would never be consumed by the levels below.
+	for rt := range armSchemas {
+		segs := strings.Split(rt, "/")
+		if len(segs) == 1 {
+			return nil, fmt.Errorf("malformed resource type: %s", rt)
+		}
+		for _, seg := range segs {
+			if seg == "" {
+				return nil, fmt.Errorf("malformed resource type: %s", rt)
+			}
+		}
+	}
+
+	level := 2
 	remains := len(armSchemas)
 
 	for remains > 0 {
@@ -1,11 +1,54 @@
 package azlist
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/magodo/armid"
 	"github.com/stretchr/testify/require"
 )
 
+// fakeTransport serves the requests by the handler, instead of sending them.
+type fakeTransport http.HandlerFunc
+
+func (f fakeTransport) Do(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	f(rec, req)
+	resp := rec.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+type fakeCredential struct{}
+
+func (fakeCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "fake", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+// newFakeLister returns a Lister of the option, whose requests are served by the handler.
+func newFakeLister(t *testing.T, opt Option, handler http.HandlerFunc) *Lister {
+	opt.SubscriptionId = "xxx"
+	opt.Cred = fakeCredential{}
+	opt.ClientOpt.Transport = fakeTransport(handler)
+	opt.ClientOpt.Retry.MaxRetries = -1
+	l, err := NewLister(opt)
+	require.NoError(t, err)
+	return l
+}
+
 func TestBuildARMSchemaTree(t *testing.T) {
 	cases := []struct {
 		name   string
@@ -79,3 +122,1068 @@ func TestBuildARMSchemaTree(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildARMSchemaTreeOverlay(t *testing.T) {
+	schema := []byte(`{
+	"Microsoft.Network/virtualNetworks": ["v1", "v2"],
+	"Microsoft.Network/virtualNetworks/subnets": ["v1", "v2"],
+	"Microsoft.Network/publicIPAddresses/": ["v1"]
+}`)
+	overlay := []byte(`{
+	"microsoft.network/virtualnetworks": ["v3-preview", "v1"],
+	"Microsoft.Network/virtualNetworks/subnets": [],
+	"Microsoft.Network/publicIPAddresses": ["v2"],
+	"Private.Foo/bars": ["v1"]
+}`)
+	tree, err := BuildARMSchemaTree(schema, overlay)
+	require.NoError(t, err)
+	require.Equal(t, ARMSchemaTree{
+		"MICROSOFT.NETWORK/VIRTUALNETWORKS": &ARMSchemaEntry{
+			Versions: []string{"v1", "v3-preview"},
+			Children: ARMSchemaTree{},
+		},
+		"MICROSOFT.NETWORK/PUBLICIPADDRESSES": &ARMSchemaEntry{
+			Versions: []string{"v2"},
+			Children: ARMSchemaTree{},
+		},
+		"PRIVATE.FOO/BARS": &ARMSchemaEntry{
+			Versions: []string{"v1"},
+			Children: ARMSchemaTree{},
+		},
+	}, tree)
+}
+
+func TestLoadARMSchemaFileCache(t *testing.T) {
+	b, err := LoadARMSchemaFile("")
+	require.NoError(t, err)
+	require.Equal(t, ARMSchemaFile, b)
+
+	cacheFile := filepath.Join(t.TempDir(), "armschema.json")
+	b, err = LoadARMSchemaFile(cacheFile)
+	require.NoError(t, err)
+	require.Equal(t, ARMSchemaFile, b)
+
+	_, n, err := UpdateARMSchemaCache(cacheFile, map[string][]string{"Microsoft.Network/virtualNetworks": {"2099-01-01"}})
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	b, err = LoadARMSchemaFile(cacheFile)
+	require.NoError(t, err)
+	var embedded, merged map[string][]string
+	require.NoError(t, json.Unmarshal(ARMSchemaFile, &embedded))
+	require.NoError(t, json.Unmarshal(b, &merged))
+	// The cache only adds to the embedded schema.
+	require.Len(t, merged, len(embedded))
+	require.Equal(t, append(append([]string{}, embedded["Microsoft.Network/virtualnetworks"]...), "2099-01-01"), merged["Microsoft.Network/virtualnetworks"])
+
+	info, err := SchemaInfo(cacheFile)
+	require.NoError(t, err)
+	require.Equal(t, ARMSchemaSourceCache, info.Source)
+	require.Equal(t, cacheFile, info.Path)
+	require.Equal(t, "2099-01-01", info.LatestAPIVersion)
+}
+
+func BenchmarkBuildARMSchemaTree(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := BuildARMSchemaTree(ARMSchemaFile); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestAzureResourceJSON(t *testing.T) {
+	id, err := armid.ParseResourceId("/subscriptions/0000/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1")
+	require.NoError(t, err)
+	res := AzureResource{
+		Id:         id,
+		APIVersion: "v1",
+		Properties: map[string]interface{}{"name": "vnet1"},
+	}
+	b, err := json.Marshal(res)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id": "/subscriptions/0000/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1", "apiVersion": "v1", "properties": {"name": "vnet1"}}`, string(b))
+
+	var out AzureResource
+	require.NoError(t, json.Unmarshal(b, &out))
+	require.Equal(t, res, out)
+
+	extId, err := armid.ParseResourceId("/subscriptions/0000/resourceGroups/rg1/providers/Microsoft.Authorization/locks/lock1")
+	require.NoError(t, err)
+	ext := AzureResource{
+		Id:                extId,
+		Scope:             extId.ParentScope(),
+		DiscoveredThrough: id,
+		Properties:        map[string]interface{}{"name": "lock1"},
+	}
+	b, err = json.Marshal(ext)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id": "/subscriptions/0000/resourceGroups/rg1/providers/Microsoft.Authorization/locks/lock1", "scope": "/subscriptions/0000/resourceGroups/rg1", "discoveredThrough": "/subscriptions/0000/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1", "properties": {"name": "lock1"}}`, string(b))
+
+	out = AzureResource{}
+	require.NoError(t, json.Unmarshal(b, &out))
+	require.Equal(t, ext, out)
+}
+
+func TestResourceExpiries(t *testing.T) {
+	cases := []struct {
+		name   string
+		id     string
+		props  map[string]interface{}
+		expect []Expiry
+	}{
+		{
+			name:   "policy exemption",
+			id:     "/subscriptions/0000/providers/Microsoft.Authorization/policyExemptions/ex1",
+			props:  map[string]interface{}{"properties": map[string]interface{}{"expiresOn": "2023-01-02T03:04:05Z"}},
+			expect: []Expiry{{Time: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)}},
+		},
+		{
+			name:   "key vault secret",
+			id:     "/subscriptions/0000/resourceGroups/rg1/providers/Microsoft.KeyVault/vaults/kv1/secrets/s1",
+			props:  map[string]interface{}{"properties": map[string]interface{}{"attributes": map[string]interface{}{"exp": float64(1672628645)}}},
+			expect: []Expiry{{Time: time.Unix(1672628645, 0)}},
+		},
+		{
+			name:  "no expiry set",
+			id:    "/subscriptions/0000/resourceGroups/rg1/providers/Microsoft.KeyVault/vaults/kv1/secrets/s1",
+			props: map[string]interface{}{"properties": map[string]interface{}{}},
+		},
+		{
+			name:  "unknown type",
+			id:    "/subscriptions/0000/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1",
+			props: map[string]interface{}{},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := armid.ParseResourceId(tt.id)
+			require.NoError(t, err)
+			require.Equal(t, tt.expect, ResourceExpiries(AzureResource{Id: id, Properties: tt.props}))
+		})
+	}
+}
+
+func TestVersionStrategyPick(t *testing.T) {
+	versions := []string{"2020-01-01", "2021-01-01", "2021-06-01-preview", "2022-01-01-preview"}
+	cases := []struct {
+		strategy VersionStrategy
+		versions []string
+		expect   string
+	}{
+		{VersionStrategyLatest, versions, "2022-01-01-preview"},
+		{VersionStrategyLatestStable, versions, "2021-01-01"},
+		{VersionStrategyOldestStable, versions, "2020-01-01"},
+		{VersionStrategyLatestStable, []string{"2021-06-01-preview", "2022-01-01-preview"}, "2022-01-01-preview"},
+		{VersionStrategyOldestStable, []string{"2021-06-01-preview", "2022-01-01-preview"}, "2021-06-01-preview"},
+		{VersionStrategyLatestStable, nil, ""},
+	}
+	for _, c := range cases {
+		if got := c.strategy.Pick(c.versions); got != c.expect {
+			t.Errorf("%s.Pick(%v): expect %q, got %q", c.strategy, c.versions, c.expect, got)
+		}
+	}
+}
+
+func FuzzBuildARMSchemaTree(f *testing.F) {
+	f.Add("Microsoft.Network/virtualNetworks\nMicrosoft.Network/virtualnetworks/subnets")
+	f.Add("Microsoft.Network/publicIPAddresses/\nMicrosoft.Network/publicIPAddresses")
+	f.Add("Microsoft.Foo/")
+	f.Add("Microsoft.Foo//bars")
+	f.Add("MICROSOFT.FOO/BARS/\nmicrosoft.foo/bars/bazs")
+	f.Fuzz(func(t *testing.T, types string) {
+		schemas := map[string][]string{}
+		for _, rt := range strings.Split(types, "\n") {
+			schemas[rt] = []string{"v1"}
+		}
+		tree, err := BuildARMSchemaTreeFromTypes(schemas)
+		if err != nil {
+			return
+		}
+		for rt, entry := range tree {
+			if rt != strings.ToUpper(rt) || !strings.Contains(rt, "/") {
+				t.Fatalf("malformed key %q", rt)
+			}
+			if entry == nil || entry.Children == nil {
+				t.Fatalf("malformed entry of %q", rt)
+			}
+		}
+	})
+}
+
+func FuzzListItem(f *testing.F) {
+	f.Add(`{"id": "/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1/subnets/subnet1"}`)
+	f.Add(`{"id": 1}`)
+	f.Add(`{"name": "foo"}`)
+	f.Add(`{"id": "/providers/Microsoft.Management/managementGroups/mg1"}`)
+	f.Add(`{"id": "/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Storage/storageAccounts/sa1/providers/Microsoft.Authorization/roleAssignments/ra1"}`)
+	f.Fuzz(func(t *testing.T, item string) {
+		var w interface{}
+		if err := json.Unmarshal([]byte(item), &w); err != nil {
+			return
+		}
+		props, b, err := decodeItem(w)
+		if err != nil {
+			return
+		}
+		id, err := itemId(props, b)
+		if err != nil {
+			return
+		}
+		azureId, err := armid.ParseResourceId(id)
+		if err != nil {
+			return
+		}
+		ResourceType(azureId)
+		subscriptionOf(azureId)
+	})
+}
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		err    ListError
+		expect ErrorClass
+	}{
+		{ListError{StatusCode: 403, ErrorCode: "AuthorizationFailed"}, ErrorClassForbidden},
+		{ListError{StatusCode: 429}, ErrorClassThrottled},
+		{ListError{StatusCode: 409, ErrorCode: "MissingSubscriptionRegistration"}, ErrorClassNotRegistered},
+		{ListError{StatusCode: 400, ErrorCode: "NoRegisteredProviderFound"}, ErrorClassBadApiVersion},
+		{ListError{StatusCode: 503}, ErrorClassTransient},
+		{ListError{StatusCode: 400, ErrorCode: "BadRequest"}, ErrorClassUnknown},
+		{ListError{Message: "RESPONSE 400: 400 Bad Request\nERROR CODE: InvalidApiVersionParameter"}, ErrorClassBadApiVersion},
+		{ListError{Message: "no schema entry found for resource type Microsoft.Foo/bars"}, ErrorClassUnknown},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.expect, ClassifyError(c.err), "%+v", c.err)
+	}
+}
+
+func TestSubscriptionFilterMatch(t *testing.T) {
+	sandbox := Subscription{Id: "00000000-0000-0000-0000-000000000001", DisplayName: "sandbox-alice", Tags: map[string]string{"Env": "dev"}, QuotaId: "MSDN_2014-09-01"}
+	prod := Subscription{Id: "00000000-0000-0000-0000-000000000002", DisplayName: "prod", Tags: map[string]string{"env": "prod"}, QuotaId: "EnterpriseAgreement_2014-09-01"}
+	cases := []struct {
+		filter  SubscriptionFilter
+		sandbox bool
+		prod    bool
+	}{
+		{SubscriptionFilter{}, true, true},
+		{SubscriptionFilter{Name: regexp.MustCompile("^sandbox-")}, true, false},
+		{SubscriptionFilter{Tags: map[string]string{"env": "prod"}}, false, true},
+		{SubscriptionFilter{OfferTypes: []string{"msdn", "Sponsored"}}, true, false},
+		{SubscriptionFilter{Exclude: []string{"PROD"}}, true, false},
+		{SubscriptionFilter{Exclude: []string{sandbox.Id}}, false, true},
+		{SubscriptionFilter{Name: regexp.MustCompile("^sandbox-"), Tags: map[string]string{"env": "prod"}}, false, false},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.sandbox, c.filter.Match(sandbox), "%+v", c.filter)
+		require.Equal(t, c.prod, c.filter.Match(prod), "%+v", c.filter)
+	}
+}
+
+func TestRecurseInto(t *testing.T) {
+	l := &Lister{
+		RecurseOnlyTypes: []string{"Microsoft.Web/*", "microsoft.sql/servers"},
+		SkipChildTypes:   []string{"Microsoft.Web/sites/snapshots"},
+	}
+	require.True(t, l.recurseInto("MICROSOFT.WEB/SITES/SLOTS"))
+	require.True(t, l.recurseInto("Microsoft.Sql/servers/databases"))
+	require.False(t, l.recurseInto("Microsoft.Web/sites/snapshots"))
+	require.False(t, l.recurseInto("Microsoft.Web/sites/snapshots/foos"))
+	require.False(t, l.recurseInto("Microsoft.Network/virtualNetworks/subnets"))
+	require.True(t, (&Lister{}).recurseInto("Microsoft.Network/virtualNetworks/subnets"))
+	require.False(t, (&Lister{}).recurseInto("Microsoft.KeyVault/vaults/accessPolicies"))
+	require.True(t, (&Lister{NoDefaultSkips: true}).recurseInto("Microsoft.KeyVault/vaults/accessPolicies"))
+}
+
+func TestDiffBody(t *testing.T) {
+	var old, new map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"tags": {"env": "dev", "owner": "alice"}, "properties": {"a/b": 1, "list": [1, 2], "other": [1]}}`), &old))
+	require.NoError(t, json.Unmarshal([]byte(`{"tags": {"env": "prod", "team": "x"}, "properties": {"a/b": 1, "list": [1, 3], "other": [1, 2]}}`), &new))
+	require.Equal(t, []PatchOp{
+		{Op: "replace", Path: "/properties/list/1", Value: float64(3), OldValue: float64(2)},
+		{Op: "replace", Path: "/properties/other", Value: []interface{}{float64(1), float64(2)}, OldValue: []interface{}{float64(1)}},
+		{Op: "replace", Path: "/tags/env", Value: "prod", OldValue: "dev"},
+		{Op: "remove", Path: "/tags/owner", OldValue: "alice"},
+		{Op: "add", Path: "/tags/team", Value: "x"},
+	}, DiffBody(old, new))
+	require.Empty(t, DiffBody(old, old))
+
+	// The null values are kept in the JSON.
+	b, err := json.Marshal([]PatchOp{
+		{Op: "add", Path: "/a", Value: nil},
+		{Op: "replace", Path: "/b", Value: "x", OldValue: nil},
+		{Op: "remove", Path: "/c", OldValue: nil},
+	})
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+{"op": "add", "path": "/a", "value": null},
+{"op": "replace", "path": "/b", "value": "x", "oldValue": null},
+{"op": "remove", "path": "/c", "oldValue": null}
+]`, string(b))
+}
+
+func TestResourceIdPath(t *testing.T) {
+	long := strings.Repeat("a", 198) + "%b" + strings.Repeat("c", 300)
+	cases := []struct {
+		id     string
+		path   string
+		expect string
+	}{
+		{
+			id:   "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1",
+			path: "subscriptions/xxx/resourcegroups/rg1/providers/microsoft.network/virtualnetworks/vnet1",
+		},
+		{
+			id:   "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Web/sites/a:b?c*/config/con",
+			path: "subscriptions/xxx/resourcegroups/rg1/providers/microsoft.web/sites/a%3Ab%3Fc%2A/config/%63on",
+		},
+		{
+			id:   "/subscriptions/xxx/resourceGroups/rg.. /providers/Microsoft.Foo/bars/nul.txt",
+			path: "subscriptions/xxx/resourcegroups/rg%2E%2E%20/providers/microsoft.foo/bars/%6Eul.txt",
+		},
+		{
+			id:   "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Foo/bars/" + long,
+			path: "subscriptions/xxx/resourcegroups/rg1/providers/microsoft.foo/bars/" + strings.Repeat("a", 198) + "%/%25b" + strings.Repeat("c", 195) + "%/" + strings.Repeat("c", 105),
+		},
+	}
+	for _, c := range cases {
+		p := ResourceIdPath(c.id)
+		require.Equal(t, filepath.FromSlash(c.path), p, c.id)
+		for _, elem := range strings.Split(c.path, "/") {
+			require.LessOrEqual(t, len(elem), maxPathElementLength, elem)
+		}
+		id, err := ResourceIdFromPath(p)
+		require.NoError(t, err)
+		require.Equal(t, strings.ToLower(c.id), id)
+	}
+
+	_, err := ResourceIdFromPath("subscriptions/xxx%")
+	require.Error(t, err)
+	_, err = ResourceIdFromPath("subscriptions/xxx%2")
+	require.Error(t, err)
+}
+
+func TestMergeListResultsNotFound(t *testing.T) {
+	merged := MergeListResults(
+		&ListResult{NotFound: []NotFoundWarning{
+			{ResourceType: "Microsoft.Web/sites/slots", ErrorCode: "ResourceNotFound", Count: 2, Endpoint: "/A"},
+			{ResourceType: "Microsoft.Foo/bars/bazs", ErrorCode: "InvalidResourceType", Count: 1, Endpoint: "/B"},
+		}},
+		&ListResult{NotFound: []NotFoundWarning{
+			{ResourceType: "MICROSOFT.FOO/BARS/BAZS", ErrorCode: "InvalidResourceType", Count: 3, Endpoint: "/C"},
+		}},
+	)
+	require.Equal(t, []NotFoundWarning{
+		{ResourceType: "Microsoft.Foo/bars/bazs", ErrorCode: "InvalidResourceType", Count: 4, Endpoint: "/B"},
+		{ResourceType: "Microsoft.Web/sites/slots", ErrorCode: "ResourceNotFound", Count: 2, Endpoint: "/A"},
+	}, merged.NotFound)
+}
+
+func TestBuiltinExtensionFilter(t *testing.T) {
+	res := map[string]interface{}{"id": "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1"}
+
+	roleAssignment := BuiltinExtensionFilter("microsoft.authorization/roleassignments")
+	require.NotNil(t, roleAssignment)
+	require.True(t, roleAssignment(res, map[string]interface{}{"properties": map[string]interface{}{"scope": "/subscriptions/xxx/resourcegroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1"}}))
+	require.False(t, roleAssignment(res, map[string]interface{}{"properties": map[string]interface{}{"scope": "/subscriptions/xxx/resourceGroups/rg1"}}))
+
+	policyAssignment := BuiltinExtensionFilter("Microsoft.Authorization/policyAssignments")
+	require.NotNil(t, policyAssignment)
+	require.True(t, policyAssignment(res, map[string]interface{}{"properties": map[string]interface{}{"scope": "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1"}}))
+	require.False(t, policyAssignment(res, map[string]interface{}{"properties": map[string]interface{}{"scope": "/subscriptions/xxx"}}))
+
+	lock := BuiltinExtensionFilter("Microsoft.Authorization/locks")
+	require.NotNil(t, lock)
+	require.True(t, lock(res, map[string]interface{}{"id": "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1/providers/Microsoft.Authorization/locks/lock1"}))
+	require.False(t, lock(res, map[string]interface{}{"id": "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Authorization/locks/lock1"}))
+
+	diag := BuiltinExtensionFilter("microsoft.insights/diagnosticSettings")
+	require.NotNil(t, diag)
+	require.True(t, diag(res, map[string]interface{}{"id": "/subscriptions/xxx/resourcegroups/rg1/providers/microsoft.network/virtualnetworks/vnet1/providers/microsoft.insights/diagnosticSettings/diag1"}))
+
+	assessment := BuiltinExtensionFilter("Microsoft.Security/assessments")
+	require.NotNil(t, assessment)
+	require.True(t, assessment(res, map[string]interface{}{"id": "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1/providers/Microsoft.Security/assessments/a1"}))
+	require.False(t, assessment(res, map[string]interface{}{"id": "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet2/providers/Microsoft.Security/assessments/a1"}))
+
+	subAssessment := BuiltinExtensionFilter("Microsoft.Security/subAssessments")
+	require.NotNil(t, subAssessment)
+	require.True(t, subAssessment(res, map[string]interface{}{"id": "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1/providers/Microsoft.Security/assessments/a1/subAssessments/s1"}))
+
+	require.Nil(t, BuiltinExtensionFilter("Microsoft.Resources/tags"))
+}
+
+func TestBuiltinExtensionVersions(t *testing.T) {
+	require.Equal(t, "2020-01-01-preview", diagnosticSettingsVersion(&armid.ManagementGroup{Name: "mg1"}))
+	require.Equal(t, "2017-05-01-preview", diagnosticSettingsVersion(&armid.SubscriptionId{Id: "xxx"}))
+	require.Equal(t, "", diagnosticSettingsVersion(&armid.ResourceGroup{SubscriptionId: "xxx", Name: "rg1"}))
+	id, err := armid.ParseResourceId("/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1")
+	require.NoError(t, err)
+	require.Equal(t, "2021-05-01-preview", diagnosticSettingsVersion(id))
+	require.True(t, IsBuiltinExtensionType("microsoft.security/assessments"))
+	require.Equal(t, "", builtinExtensionVersions["MICROSOFT.SECURITY/ASSESSMENTS"](&armid.TenantId{}))
+	require.Equal(t, "2020-01-01", builtinExtensionVersions["MICROSOFT.SECURITY/ASSESSMENTS"](&armid.SubscriptionId{Id: "xxx"}))
+	require.True(t, builtinExtensionNotApplicable("Microsoft.Insights/diagnosticSettings", "resourcetypenotsupported"))
+	require.False(t, builtinExtensionNotApplicable("Microsoft.Authorization/locks", "ResourceTypeNotSupported"))
+}
+
+func TestManagedResourceGroupOf(t *testing.T) {
+	managed := map[string]string{
+		"/SUBSCRIPTIONS/XXX/RESOURCEGROUPS/MC_RG1_AKS1_WESTUS": "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.ContainerService/managedClusters/aks1",
+	}
+	id, err := armid.ParseResourceId("/subscriptions/xxx/resourceGroups/mc_rg1_aks1_westus/providers/Microsoft.Compute/virtualMachineScaleSets/vmss1")
+	require.NoError(t, err)
+	managedBy, ok := managedResourceGroupOf(managed, id)
+	require.True(t, ok)
+	require.Equal(t, "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.ContainerService/managedClusters/aks1", managedBy)
+
+	id, err = armid.ParseResourceId("/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.ContainerService/managedClusters/aks1")
+	require.NoError(t, err)
+	_, ok = managedResourceGroupOf(managed, id)
+	require.False(t, ok)
+
+	_, ok = managedResourceGroupOf(nil, id)
+	require.False(t, ok)
+}
+
+func TestWithReferencedBy(t *testing.T) {
+	parse := func(id string) armid.ResourceId {
+		v, err := armid.ParseResourceId(id)
+		require.NoError(t, err)
+		return v
+	}
+	sub := parse("/subscriptions/xxx")
+	rg := parse("/subscriptions/xxx/resourceGroups/rg1")
+	vnet := parse("/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1")
+	id := parse("/subscriptions/xxx/providers/Microsoft.Authorization/roleAssignments/ra1")
+	res := AzureResource{Id: id, Scope: id.ParentScope(), DiscoveredThrough: vnet}
+
+	out := withReferencedBy(res, []armid.ResourceId{vnet, rg, sub, vnet})
+	require.Equal(t, []armid.ResourceId{sub, rg, vnet}, out.ReferencedBy)
+	require.Equal(t, sub, out.DiscoveredThrough)
+
+	out = withReferencedBy(res, []armid.ResourceId{vnet, rg})
+	require.Equal(t, []armid.ResourceId{rg, vnet}, out.ReferencedBy)
+	require.Equal(t, rg, out.DiscoveredThrough)
+
+	merged := MergeListResults(
+		&ListResult{Resources: []AzureResource{{Id: id, Scope: id.ParentScope(), DiscoveredThrough: vnet}}},
+		&ListResult{Resources: []AzureResource{{Id: id, Scope: id.ParentScope(), DiscoveredThrough: sub}}},
+	)
+	require.Len(t, merged.Resources, 1)
+	require.Equal(t, []armid.ResourceId{sub, vnet}, merged.Resources[0].ReferencedBy)
+	require.Equal(t, sub, merged.Resources[0].DiscoveredThrough)
+
+	b, err := json.Marshal(out)
+	require.NoError(t, err)
+	var v AzureResource
+	require.NoError(t, json.Unmarshal(b, &v))
+	require.Equal(t, out, v)
+}
+
+func TestExpandMetadataTypes(t *testing.T) {
+	var expands []string
+	l := newFakeLister(t, Option{
+		ExpandMetadataTypes: []string{"microsoft.network/virtualNetworks/subnets"},
+		ListQueryParameters: map[string]url.Values{"Microsoft.Network/virtualNetworks/virtualNetworkPeerings": {"$top": {"10"}}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		expand := r.URL.Query().Get("$expand")
+		expands = append(expands, expand)
+		item := map[string]interface{}{"id": r.URL.Path + "/child1", "name": "child1"}
+		if expand != "" {
+			item["createdTime"] = "2023-01-01T00:00:00Z"
+			item["changedTime"] = "2023-01-02T00:00:00Z"
+			item["provisioningState"] = "Succeeded"
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"value": []interface{}{item}})
+	})
+	id, err := armid.ParseResourceId("/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1")
+	require.NoError(t, err)
+	parent := AzureResource{Id: id, Properties: map[string]interface{}{}}
+
+	result, err := l.listResource(context.Background(), parent, "subnets", "2022-01-01", nil)
+	require.NoError(t, err)
+	require.Empty(t, result.Errors)
+	require.Len(t, result.Resources, 1)
+	props := result.Resources[0].Properties
+	require.Equal(t, "2023-01-01T00:00:00Z", props["createdTime"])
+	require.Equal(t, "2023-01-02T00:00:00Z", props["changedTime"])
+	require.Equal(t, "Succeeded", props["provisioningState"])
+
+	// The types not in the ExpandMetadataTypes are listed without the $expand.
+	result, err = l.listResource(context.Background(), parent, "virtualNetworkPeerings", "2022-01-01", nil)
+	require.NoError(t, err)
+	require.Len(t, result.Resources, 1)
+	require.NotContains(t, result.Resources[0].Properties, "createdTime")
+	require.Equal(t, []string{metadataExpand, ""}, expands)
+
+	// The $expand set explicitly takes precedence.
+	params := withMetadataExpand(map[string]url.Values{"Microsoft.Web/sites/slots": {"$expand": {"foo"}}}, []string{"microsoft.web/sites/slots"})
+	require.Equal(t, map[string]url.Values{"Microsoft.Web/sites/slots": {"$expand": {"foo"}}}, params)
+}
+
+func TestListTrackedResourcesRows(t *testing.T) {
+	var queries []string
+	l := newFakeLister(t, Option{}, func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query string `json:"query"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		queries = append(queries, req.Query)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"totalRecords":    2,
+			"count":           2,
+			"resultTruncated": "false",
+			"data": []interface{}{
+				map[string]interface{}{"id": "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1", "name": "vnet1"},
+				map[string]interface{}{"type": "microsoft.network/virtualnetworks", "count_": 1},
+			},
+		})
+	})
+	stats := newListStats()
+	result, err := l.ListTrackedResources(withListStats(context.Background(), stats), "true | summarize count() by type")
+	require.NoError(t, err)
+	require.Equal(t, []string{"Resources | order by id desc | where true | summarize count() by type"}, queries)
+	require.Len(t, result.Resources, 1)
+	require.Equal(t, []map[string]interface{}{{"type": "microsoft.network/virtualnetworks", "count_": float64(1)}}, result.Rows)
+	// The resources of the ARG pages are counted as discovered, while the rows are not.
+	require.Equal(t, 1, stats.snapshot().Discovered)
+
+	require.Equal(t, "Resources | where hash(id, 2) == 1 | order by id desc | where type =~ 'x'", argQuery("Resources", "type =~ 'x'", 2, 1))
+}
+
+func TestListTenantResourcesManagementGroupGetError(t *testing.T) {
+	l := newFakeLister(t, Option{}, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/providers/Microsoft.Management/managementGroups":
+			json.NewEncoder(w).Encode(map[string]interface{}{"value": []interface{}{
+				map[string]interface{}{"id": "/providers/Microsoft.Management/managementGroups/mg1", "name": "mg1"},
+				map[string]interface{}{"id": "/providers/Microsoft.Management/managementGroups/mg2", "name": "mg2"},
+			}})
+		case "/providers/Microsoft.Management/managementGroups/mg1":
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"code": "AuthorizationFailed"}})
+		case "/providers/Microsoft.Management/managementGroups/mg2":
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "/providers/Microsoft.Management/managementGroups/mg2", "name": "mg2", "properties": map[string]interface{}{}})
+		default:
+			json.NewEncoder(w).Encode(map[string]interface{}{"value": []interface{}{}})
+		}
+	})
+	rl, el, _, err := l.ListTenantResources(context.Background())
+	require.NoError(t, err)
+	require.Len(t, rl, 2)
+	require.Len(t, el, 1)
+	require.Equal(t, http.StatusForbidden, el[0].StatusCode)
+	require.Equal(t, "mg1", rl[0].Properties["name"])
+	require.Contains(t, rl[1].Properties, "properties")
+}
+
+func TestListChildResource(t *testing.T) {
+	schema := []byte(`{
+	"Microsoft.Foo/as": ["v1"],
+	"Microsoft.Foo/as/bs": ["v1"],
+	"Microsoft.Foo/as/bs/cs": ["v1"],
+	"Microsoft.Foo/as/ds": ["v1"]
+}`)
+	rootId, err := armid.ParseResourceId("/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Foo/as/a1")
+	require.NoError(t, err)
+	root := AzureResource{Id: rootId, Properties: map[string]interface{}{}}
+
+	// fakeListChild lists the child resources named in children by the child resource type, with the bookkeeping of the stats as listResource does.
+	// The "ds" listing also returns the "bs/b1", which is deduplicated.
+	fakeListChild := func(mu *sync.Mutex, listed *[]string, failType string) func(ctx context.Context, job childListing) (ListResult, error) {
+		children := map[string][]string{
+			"bs": {"bs/b1", "bs/b2"},
+			"cs": {"cs/c1"},
+			"ds": {"ds/d1", "bs/b1"},
+		}
+		return func(ctx context.Context, job childListing) (ListResult, error) {
+			stats := listStatsFrom(ctx)
+			stats.start("Microsoft.Foo")
+			defer stats.done("Microsoft.Foo", 0, false)
+
+			mu.Lock()
+			*listed = append(*listed, job.parent.Id.String()+"/"+job.crt)
+			mu.Unlock()
+			crt := strings.ToLower(job.crt)
+			if crt == failType {
+				return ListResult{}, fmt.Errorf("failed to list %s", crt)
+			}
+			var result ListResult
+			for _, name := range children[crt] {
+				id, err := armid.ParseResourceId(path.Join(job.parent.Id.String(), name))
+				if err != nil {
+					return ListResult{}, err
+				}
+				result.Resources = append(result.Resources, AzureResource{Id: id, Properties: map[string]interface{}{}})
+			}
+			return result, nil
+		}
+	}
+
+	ids := func(rl []AzureResource) []string {
+		var out []string
+		for _, res := range rl {
+			out = append(out, strings.TrimPrefix(res.Id.String(), rootId.String()))
+		}
+		return out
+	}
+
+	cases := []struct {
+		name        string
+		opt         Option
+		failType    string
+		expectIds   []string
+		expectCalls int
+		expectErr   bool
+	}{
+		{
+			name:        "all levels",
+			expectIds:   []string{"", "/bs/b1", "/bs/b1/cs/c1", "/bs/b2", "/bs/b2/cs/c1", "/ds/d1"},
+			expectCalls: 4,
+		},
+		{
+			name:        "max depth",
+			opt:         Option{MaxDepth: 1},
+			expectIds:   []string{"", "/bs/b1", "/bs/b2", "/ds/d1"},
+			expectCalls: 2,
+		},
+		{
+			name: "recurse predicate vetoes a parent",
+			opt: Option{RecursePredicate: func(parent AzureResource) bool {
+				return !strings.HasSuffix(parent.Id.String(), "/bs/b1")
+			}},
+			expectIds:   []string{"", "/bs/b1", "/bs/b2", "/bs/b2/cs/c1", "/ds/d1"},
+			expectCalls: 3,
+		},
+		{
+			// The root and any listed resource reach the top, so that no grandchild is listed.
+			name:        "top",
+			opt:         Option{Top: 2},
+			expectCalls: 2,
+		},
+		{
+			name:      "error aborts",
+			opt:       Option{Parallelism: 1},
+			failType:  "bs",
+			expectErr: true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opt.ARMSchema = schema
+			l := newFakeLister(t, tt.opt, func(w http.ResponseWriter, r *http.Request) {
+				t.Errorf("unexpected request: %s", r.URL)
+			})
+			var (
+				mu     sync.Mutex
+				listed []string
+			)
+			l.listChild = fakeListChild(&mu, &listed, tt.failType)
+			stats := newListStats()
+			rl, _, _, err := l.ListChildResource(withListStats(context.Background(), stats), []AzureResource{root})
+			require.Zero(t, stats.snapshot().Queued)
+			require.Empty(t, stats.snapshot().InFlight)
+			if tt.expectErr {
+				require.Error(t, err)
+				// The child listings of the grandchildren, which are queued after the failure, are dropped.
+				for _, l := range listed {
+					require.NotContains(t, l, "/CS")
+				}
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, listed, tt.expectCalls)
+			if tt.expectIds != nil {
+				require.Equal(t, tt.expectIds, ids(rl))
+			}
+		})
+	}
+}
+
+func TestListExtensionResourceStats(t *testing.T) {
+	l := newFakeLister(t, Option{
+		ExtensionResourceTypes: []ExtensionResource{{Type: "Microsoft.Insights/diagnosticSettings"}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"value": []interface{}{}})
+	})
+	stats := newListStats()
+	ctx := withListStats(context.Background(), stats)
+	rg := AzureResource{Id: &armid.ResourceGroup{SubscriptionId: "xxx", Name: "rg1"}, Properties: map[string]interface{}{}}
+	vnetId, err := armid.ParseResourceId("/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1")
+	require.NoError(t, err)
+	vnet := AzureResource{Id: vnetId, Properties: map[string]interface{}{}}
+
+	// The diagnostic settings are not applicable to the resource group, which is neither queued nor listed.
+	_, _, _, err = l.ListExtensionResource(ctx, []AzureResource{rg, vnet})
+	require.NoError(t, err)
+	p := stats.snapshot()
+	require.Zero(t, p.Queued)
+	require.Equal(t, 1, p.Completed)
+
+	// The extension resource type without a schema entry fails the listing, which is not queued either.
+	l.ExtensionResourceTypes = []ExtensionResource{{Type: "Microsoft.Foo/bars"}}
+	_, _, _, err = l.ListExtensionResource(ctx, []AzureResource{vnet})
+	require.Error(t, err)
+	require.Zero(t, stats.snapshot().Queued)
+}
+
+func TestEmbeddedSchemaMeta(t *testing.T) {
+	info, err := SchemaInfo("")
+	require.NoError(t, err)
+	require.Equal(t, ARMSchemaSourceEmbedded, info.Source)
+	require.NotEmpty(t, info.Commit)
+	_, err = time.Parse(time.RFC3339, info.GeneratedAt)
+	require.NoError(t, err)
+}
+
+func TestListOnProgress(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		returned bool
+		late     int
+	)
+	l := newFakeLister(t, Option{
+		ProgressInterval: time.Millisecond,
+		OnProgress: func(Progress) {
+			mu.Lock()
+			defer mu.Unlock()
+			if returned {
+				late++
+			}
+		},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"totalRecords":    1,
+			"count":           1,
+			"resultTruncated": "false",
+			"data": []interface{}{
+				map[string]interface{}{"id": "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1", "name": "vnet1"},
+			},
+		})
+	})
+	_, err := l.List(context.Background(), "true")
+	require.NoError(t, err)
+	mu.Lock()
+	returned = true
+	mu.Unlock()
+
+	// The OnProgress is not called once List returns.
+	time.Sleep(10 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	require.Zero(t, late)
+}
+
+func TestListSubscriptionId(t *testing.T) {
+	l := newFakeLister(t, Option{IncludeAncestors: true}, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/providers/Microsoft.ResourceGraph/resources":
+			var req struct {
+				Query string `json:"query"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			row := map[string]interface{}{"id": "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1", "name": "vnet1"}
+			if strings.HasPrefix(req.Query, "ResourceContainers") {
+				row = map[string]interface{}{"chain": []interface{}{map[string]interface{}{"name": "mg1"}}}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"totalRecords":    1,
+				"count":           1,
+				"resultTruncated": "false",
+				"data":            []interface{}{row},
+			})
+		case "/providers/Microsoft.Management/managementGroups/mg1":
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "/providers/Microsoft.Management/managementGroups/mg1", "name": "mg1"})
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	})
+	result, err := l.List(context.Background(), "true")
+	require.NoError(t, err)
+	require.Len(t, result.Resources, 2)
+	// The management group is out of any subscription.
+	require.Equal(t, "/providers/Microsoft.Management/managementGroups/mg1", result.Resources[0].Id.String())
+	require.Empty(t, result.Resources[0].SubscriptionId)
+	require.Equal(t, "xxx", result.Resources[1].SubscriptionId)
+
+	bySub := result.BySubscription()
+	require.Len(t, bySub, 2)
+	require.Len(t, bySub[""].Resources, 1)
+	require.Len(t, bySub["xxx"].Resources, 1)
+}
+
+func TestListAncestors(t *testing.T) {
+	var pages int
+	l := newFakeLister(t, Option{}, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/providers/Microsoft.ResourceGraph/resources":
+			var req struct {
+				Options struct {
+					SkipToken string `json:"$skipToken"`
+				} `json:"options"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			pages++
+			resp := map[string]interface{}{
+				"totalRecords":    2,
+				"count":           1,
+				"resultTruncated": "false",
+			}
+			if req.Options.SkipToken == "" {
+				resp["$skipToken"] = "page2"
+				resp["data"] = []interface{}{
+					"unexpected row",
+					map[string]interface{}{"chain": []interface{}{map[string]interface{}{"name": "mg1"}, map[string]interface{}{"name": "root"}}},
+				}
+			} else {
+				resp["data"] = []interface{}{
+					map[string]interface{}{"chain": []interface{}{"unexpected management group", map[string]interface{}{"name": "mg2"}, map[string]interface{}{"name": "root"}}},
+				}
+			}
+			json.NewEncoder(w).Encode(resp)
+		case "/providers/Microsoft.Management/managementGroups/root":
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"code": "AuthorizationFailed"}})
+		case "/providers/Microsoft.Management/managementGroups/mg1", "/providers/Microsoft.Management/managementGroups/mg2":
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": r.URL.Path, "name": path.Base(r.URL.Path)})
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	})
+	ids := func(ids ...string) []AzureResource {
+		var rl []AzureResource
+		for _, id := range ids {
+			azureId, err := armid.ParseResourceId(id)
+			require.NoError(t, err)
+			rl = append(rl, AzureResource{Id: azureId})
+		}
+		return rl
+	}
+	mgs, el, err := l.ListAncestors(context.Background(), ids("/subscriptions/sub1/resourceGroups/rg1", "/subscriptions/sub2"))
+	require.NoError(t, err)
+	require.Equal(t, 2, pages)
+	require.Len(t, mgs, 2)
+	// Both are the direct children of the root, which are ordered by the name.
+	require.Equal(t, "/providers/Microsoft.Management/managementGroups/mg1", mgs[0].Id.String())
+	require.Equal(t, "/providers/Microsoft.Management/managementGroups/mg2", mgs[1].Id.String())
+	require.Len(t, el, 1)
+	require.Equal(t, http.StatusForbidden, el[0].StatusCode)
+	require.Equal(t, "/PROVIDERS/MICROSOFT.MANAGEMENT/MANAGEMENTGROUPS/ROOT", el[0].Endpoint)
+}
+
+func TestListResourceVersionFallback(t *testing.T) {
+	id, err := armid.ParseResourceId("/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1")
+	require.NoError(t, err)
+	parent := AzureResource{Id: id, Properties: map[string]interface{}{}}
+
+	newLister := func(opt Option, versions *[]string) *Lister {
+		return newFakeLister(t, opt, func(w http.ResponseWriter, r *http.Request) {
+			version := r.URL.Query().Get("api-version")
+			*versions = append(*versions, version)
+			if version == "2022-01-01" {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"code": "InvalidApiVersionParameter"}})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"value": []interface{}{
+				map[string]interface{}{"id": r.URL.Path + "/subnet1", "name": "subnet1"},
+			}})
+		})
+	}
+
+	var versions []string
+	l := newLister(Option{}, &versions)
+	fallbacks := l.olderVersions(parent, "subnets", "2022-01-01")
+	require.NotEmpty(t, fallbacks)
+	result, err := l.listResource(context.Background(), parent, "subnets", "2022-01-01", nil)
+	require.NoError(t, err)
+	require.Empty(t, result.Errors)
+	require.Len(t, result.Resources, 1)
+	require.Equal(t, fallbacks[0], result.Resources[0].APIVersion)
+	require.Equal(t, []string{"2022-01-01", fallbacks[0]}, versions)
+
+	// The pinned api-version is not fallen back.
+	versions = nil
+	l = newLister(Option{APIVersions: map[string]string{"microsoft.network/virtualNetworks/subnets": "2022-01-01"}}, &versions)
+	result, err = l.listResource(context.Background(), parent, "subnets", "2022-01-01", nil)
+	require.NoError(t, err)
+	require.Empty(t, result.Resources)
+	require.Len(t, result.Errors, 1)
+	require.Equal(t, "2022-01-01", result.Errors[0].Version)
+	require.Equal(t, []string{"2022-01-01"}, versions)
+}
+
+func TestListResultPartition(t *testing.T) {
+	res := func(id string, props map[string]interface{}) AzureResource {
+		azureId, err := armid.ParseResourceId(id)
+		require.NoError(t, err)
+		return AzureResource{Id: azureId, SubscriptionId: subscriptionOf(azureId), Properties: props}
+	}
+	result := &ListResult{
+		Resources: []AzureResource{
+			res("/providers/Microsoft.Management/managementGroups/mg1", map[string]interface{}{"tenantId": "t1"}),
+			res("/subscriptions/x", map[string]interface{}{"tenantId": "t1"}),
+			res("/subscriptions/x/resourceGroups/rg1", map[string]interface{}{}),
+			res("/subscriptions/x/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1", map[string]interface{}{"tenantId": "t2"}),
+			res("/subscriptions/x/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1/subnets/subnet1", map[string]interface{}{}),
+			res("/subscriptions/y/resourceGroups/rg2", map[string]interface{}{}),
+		},
+		Errors: []ListError{
+			{Endpoint: "/SUBSCRIPTIONS/X/RESOURCEGROUPS/RG1/PROVIDERS/MICROSOFT.NETWORK/VIRTUALNETWORKS/VNET1/SUBNETS"},
+			{Endpoint: "/subscriptions/z/resourceGroups/rg3/providers/Microsoft.Network/virtualNetworks"},
+			{Endpoint: "/PROVIDERS/MICROSOFT.MANAGEMENT/MANAGEMENTGROUPS/MG2"},
+		},
+	}
+	ids := func(result *ListResult) []string {
+		var out []string
+		for _, res := range result.Resources {
+			out = append(out, res.Id.String())
+		}
+		for _, e := range result.Errors {
+			out = append(out, "error: "+e.Endpoint)
+		}
+		return out
+	}
+
+	cases := []struct {
+		name   string
+		f      func(*ListResult) map[string]*ListResult
+		expect map[string][]string
+	}{
+		{
+			name: "by subscription",
+			f:    (*ListResult).BySubscription,
+			expect: map[string][]string{
+				"": {
+					"/providers/Microsoft.Management/managementGroups/mg1",
+					"error: /PROVIDERS/MICROSOFT.MANAGEMENT/MANAGEMENTGROUPS/MG2",
+				},
+				"x": {
+					"/subscriptions/x",
+					"/subscriptions/x/resourceGroups/rg1",
+					"/subscriptions/x/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1",
+					"/subscriptions/x/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1/subnets/subnet1",
+					"error: /SUBSCRIPTIONS/X/RESOURCEGROUPS/RG1/PROVIDERS/MICROSOFT.NETWORK/VIRTUALNETWORKS/VNET1/SUBNETS",
+				},
+				"y": {
+					"/subscriptions/y/resourceGroups/rg2",
+				},
+				"z": {
+					"error: /subscriptions/z/resourceGroups/rg3/providers/Microsoft.Network/virtualNetworks",
+				},
+			},
+		},
+		{
+			name: "by tenant",
+			f:    (*ListResult).ByTenant,
+			expect: map[string][]string{
+				"": {
+					"/subscriptions/y/resourceGroups/rg2",
+				},
+				"t1": {
+					"/providers/Microsoft.Management/managementGroups/mg1",
+					"/subscriptions/x",
+					"/subscriptions/x/resourceGroups/rg1",
+				},
+				"t2": {
+					"/subscriptions/x/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1",
+					"/subscriptions/x/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1/subnets/subnet1",
+				},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			partitions := c.f(result)
+			actual := map[string][]string{}
+			for k, v := range partitions {
+				actual[k] = ids(v)
+			}
+			require.Equal(t, c.expect, actual)
+		})
+	}
+}
+
+func TestListTrackedResourcesSharded(t *testing.T) {
+	tooLarge := func(w http.ResponseWriter) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]interface{}{"code": "ResponsePayloadTooLarge"}})
+	}
+	cases := []struct {
+		name      string
+		opt       Option
+		predicate string
+		expectIds []string
+		expectErr bool
+		// expectQueries are the shard filters of the queries sent, where "" is the unsharded query.
+		expectQueries []string
+	}{
+		{
+			name:      "split",
+			predicate: "true",
+			expectIds: []string{
+				"/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet0",
+				"/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1",
+			},
+			expectQueries: []string{"", "hash(id, 2) == 0", "hash(id, 2) == 1"},
+		},
+		{
+			name:          "generic rows",
+			predicate:     "true | summarize count() by type",
+			expectErr:     true,
+			expectQueries: []string{"", "hash(id, 2) == 0"},
+		},
+		{
+			name:          "skip",
+			opt:           Option{ARGSkip: 10},
+			predicate:     "true",
+			expectErr:     true,
+			expectQueries: []string{""},
+		},
+	}
+	shardRe := regexp.MustCompile(`hash\(id, \d+\) == \d+`)
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var queries []string
+			l := newFakeLister(t, c.opt, func(w http.ResponseWriter, r *http.Request) {
+				var req struct {
+					Query string `json:"query"`
+				}
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+				filter := shardRe.FindString(req.Query)
+				queries = append(queries, filter)
+				if filter == "" {
+					tooLarge(w)
+					return
+				}
+				record := map[string]interface{}{
+					"id":   "/subscriptions/xxx/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet" + filter[len(filter)-1:],
+					"name": "vnet",
+				}
+				if strings.Contains(req.Query, "summarize") {
+					record = map[string]interface{}{"type": "microsoft.network/virtualnetworks", "count_": 1}
+				}
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"totalRecords":    1,
+					"count":           1,
+					"resultTruncated": "false",
+					"data":            []interface{}{record},
+				})
+			})
+			result, err := l.ListTrackedResources(context.Background(), c.predicate)
+			require.Equal(t, c.expectQueries, queries)
+			if c.expectErr {
+				require.Error(t, err)
+				require.True(t, isPayloadTooLarge(err))
+				return
+			}
+			require.NoError(t, err)
+			var ids []string
+			for _, res := range result.Resources {
+				ids = append(ids, res.Id.String())
+			}
+			require.Equal(t, c.expectIds, ids)
+		})
+	}
+}
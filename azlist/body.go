@@ -0,0 +1,125 @@
+package azlist
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+// BodyDigest returns a stable digest of the resource body, in the form of "sha256:<hex>".
+// The digest is stable as the JSON encoding of maps is ordered by the keys.
+func BodyDigest(props map[string]interface{}) (string, error) {
+	b, err := json.Marshal(props)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// VolatileFields maps the upper cased resource types to the dotted paths (e.g. "properties.lastModified") of the volatile fields in their bodies.
+// The paths under the key "*" apply to all resource types.
+type VolatileFields map[string][]string
+
+// DefaultVolatileFields are the fields that commonly change without any real change to the resource.
+var DefaultVolatileFields = VolatileFields{
+	"*": {
+		"etag",
+		"changedTime",
+		"properties.lastModified",
+		"properties.lastModifiedTime",
+		"properties.lastModifiedAt",
+		"properties.lastModifiedTimeUtc",
+		"systemData.lastModifiedAt",
+		"systemData.lastModifiedBy",
+		"systemData.lastModifiedByType",
+	},
+}
+
+// NoiseFields are the volatile fields, as well as the other fields (e.g. the provisioning state, the system data) that are noisy when comparing the bodies across runs.
+var NoiseFields = VolatileFields{
+	"*": {
+		"etag",
+		"changedTime",
+		"createdTime",
+		"systemData",
+		"provisioningState",
+		"properties.provisioningState",
+		"properties.lastModified",
+		"properties.lastModifiedTime",
+		"properties.lastModifiedAt",
+		"properties.lastModifiedTimeUtc",
+		"properties.createdTime",
+		"properties.creationTime",
+		"properties.timeCreated",
+	},
+}
+
+// Add adds a volatile field path for the resource type, or for all resource types if the type is "*".
+func (f VolatileFields) Add(rt, path string) {
+	if rt != "*" {
+		rt = strings.ToUpper(rt)
+	}
+	f[rt] = append(f[rt], path)
+}
+
+// Paths returns the volatile field paths of the resource type.
+func (f VolatileFields) Paths(rt string) []string {
+	return append(append([]string{}, f["*"]...), f[strings.ToUpper(rt)]...)
+}
+
+// CanonicalizeBody returns a copy of the resource body with the volatile fields of its resource type removed.
+// The JSON encoding of the returned body is deterministic, as maps are encoded with sorted keys.
+func CanonicalizeBody(rt string, props map[string]interface{}, fields VolatileFields) map[string]interface{} {
+	out, _ := deepCopyJSON(props).(map[string]interface{})
+	if out == nil {
+		return nil
+	}
+	for _, path := range fields.Paths(rt) {
+		removePath(out, strings.Split(path, "."))
+	}
+	return out
+}
+
+func deepCopyJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, vv := range v {
+			out[k] = deepCopyJSON(vv)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, vv := range v {
+			out[i] = deepCopyJSON(vv)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func removePath(m map[string]interface{}, segs []string) {
+	if len(segs) == 1 {
+		delete(m, segs[0])
+		return
+	}
+	if child, ok := m[segs[0]].(map[string]interface{}); ok {
+		removePath(child, segs[1:])
+	}
+}
+
+// LookupPath returns the value of the dotted path (e.g. "properties.provisioningState") in the resource body, or nil if not found.
+func LookupPath(props map[string]interface{}, path string) interface{} {
+	var v interface{} = props
+	for _, seg := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[seg]
+	}
+	return v
+}
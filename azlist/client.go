@@ -1,24 +1,59 @@
 package azlist
 
 import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	sdkARMResources "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/magodo/armid"
 	"github.com/magodo/azlist/arg"
 	"github.com/magodo/azlist/armresources"
 )
 
 type Client struct {
-	resourceGroup *sdkARMResources.ResourceGroupsClient
 	resource      *armresources.Client
 	resourceGraph *arg.Client
+	counter       *requestCounter
+
+	// The clients below are subscription scoped, which are created on demand for each subscription.
+	cred           azcore.TokenCredential
+	clientOpt      arm.ClientOptions
+	mu             sync.Mutex
+	resources      map[string]*sdkARMResources.Client
+	resourceGroups map[string]*sdkARMResources.ResourceGroupsClient
 }
 
-func NewClient(subscriptionId string, cred azcore.TokenCredential, clientOpt arm.ClientOptions) (*Client, error) {
-	rgClient, err := sdkARMResources.NewResourceGroupsClient(subscriptionId, cred, &clientOpt)
-	if err != nil {
-		return nil, err
+// RequestStats counts the API requests (excluding retries) sent by a client.
+type RequestStats struct {
+	ARGRequests int64 `json:"argRequests"`
+	ARMRequests int64 `json:"armRequests"`
+}
+
+// requestCounter is a per call policy that counts the requests sent to ARG and the other ARM endpoints.
+type requestCounter struct {
+	arg atomic.Int64
+	arm atomic.Int64
+}
+
+func (c *requestCounter) Do(req *policy.Request) (*http.Response, error) {
+	if strings.Contains(strings.ToLower(req.Raw().URL.Path), "/providers/microsoft.resourcegraph/") {
+		c.arg.Add(1)
+	} else {
+		c.arm.Add(1)
 	}
+	return req.Next()
+}
+
+func NewClient(subscriptionId string, cred azcore.TokenCredential, clientOpt arm.ClientOptions) (*Client, error) {
+	counter := &requestCounter{}
+	clientOpt.PerCallPolicies = append(append([]policy.Policy{}, clientOpt.PerCallPolicies...), counter, callHeaderPolicy{})
 
 	resClient, err := armresources.NewClient(subscriptionId, cred, &clientOpt)
 	if err != nil {
@@ -31,8 +66,127 @@ func NewClient(subscriptionId string, cred azcore.TokenCredential, clientOpt arm
 	}
 
 	return &Client{
-		resourceGroup: rgClient,
-		resource:      resClient,
-		resourceGraph: argClient,
+		resource:       resClient,
+		resourceGraph:  argClient,
+		counter:        counter,
+		cred:           cred,
+		clientOpt:      clientOpt,
+		resources:      map[string]*sdkARMResources.Client{},
+		resourceGroups: map[string]*sdkARMResources.ResourceGroupsClient{},
 	}, nil
 }
+
+func (c *Client) resourcesClient(subscriptionId string) (*sdkARMResources.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := strings.ToLower(subscriptionId)
+	if client, ok := c.resources[key]; ok {
+		return client, nil
+	}
+	client, err := sdkARMResources.NewClient(subscriptionId, c.cred, &c.clientOpt)
+	if err != nil {
+		return nil, err
+	}
+	c.resources[key] = client
+	return client, nil
+}
+
+func (c *Client) resourceGroupsClient(subscriptionId string) (*sdkARMResources.ResourceGroupsClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := strings.ToLower(subscriptionId)
+	if client, ok := c.resourceGroups[key]; ok {
+		return client, nil
+	}
+	client, err := sdkARMResources.NewResourceGroupsClient(subscriptionId, c.cred, &c.clientOpt)
+	if err != nil {
+		return nil, err
+	}
+	c.resourceGroups[key] = client
+	return client, nil
+}
+
+// ProviderResourceTypes lists the resource types of all the resource providers in the subscription, in form of "<namespace>/<type>",
+// together with their api-versions in ascending order.
+func (c *Client) ProviderResourceTypes(ctx context.Context, subscriptionId string) (map[string][]string, error) {
+	client, err := sdkARMResources.NewProvidersClient(subscriptionId, c.cred, &c.clientOpt)
+	if err != nil {
+		return nil, err
+	}
+	types := map[string][]string{}
+	pager := client.NewListPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, provider := range page.Value {
+			if provider == nil || provider.Namespace == nil {
+				continue
+			}
+			for _, rt := range provider.ResourceTypes {
+				if rt == nil || rt.ResourceType == nil {
+					continue
+				}
+				var versions []string
+				for _, v := range rt.APIVersions {
+					if v != nil {
+						versions = append(versions, *v)
+					}
+				}
+				sort.Strings(versions)
+				types[*provider.Namespace+"/"+*rt.ResourceType] = versions
+			}
+		}
+	}
+	return types, nil
+}
+
+// RequestStats returns the number of requests sent by this client so far.
+func (c *Client) RequestStats() RequestStats {
+	return RequestStats{
+		ARGRequests: c.counter.arg.Load(),
+		ARMRequests: c.counter.arm.Load(),
+	}
+}
+
+// MergeTags adds or updates the tags of the resource, while keeping its other tags.
+func (c *Client) MergeTags(ctx context.Context, id armid.ResourceId, tags map[string]string) error {
+	ptags := map[string]*string{}
+	for k, v := range tags {
+		ptags[k] = ptr(v)
+	}
+	return c.resource.MergeTags(ctx, id.String(), ptags)
+}
+
+// ValidateMoveResources validates whether the resources, which belong to the same source resource group, can be moved to the target resource group.
+// A nil error is returned if they can be moved.
+func (c *Client) ValidateMoveResources(ctx context.Context, sourceResourceGroup *armid.ResourceGroup, ids []armid.ResourceId, targetResourceGroup *armid.ResourceGroup) error {
+	var resources []*string
+	for _, id := range ids {
+		resources = append(resources, ptr(id.String()))
+	}
+	client, err := c.resourcesClient(sourceResourceGroup.SubscriptionId)
+	if err != nil {
+		return err
+	}
+	poller, err := client.BeginValidateMoveResources(ctx, sourceResourceGroup.Name, sdkARMResources.MoveInfo{
+		Resources:           resources,
+		TargetResourceGroup: ptr(targetResourceGroup.String()),
+	}, nil)
+	if err != nil {
+		return err
+	}
+	_, err = poller.PollUntilDone(ctx, nil)
+	return err
+}
+
+// CreateOrUpdateLock creates or updates a management lock of the level (i.e. "CanNotDelete" or "ReadOnly") on the resource.
+func (c *Client) CreateOrUpdateLock(ctx context.Context, id armid.ResourceId, lockName, level, notes string) error {
+	return c.resource.CreateOrUpdateLock(ctx, id.String(), lockName, level, notes)
+}
+
+// DeleteLock deletes a management lock on the resource.
+func (c *Client) DeleteLock(ctx context.Context, id armid.ResourceId, lockName string) error {
+	return c.resource.DeleteLock(ctx, id.String(), lockName)
+}
@@ -0,0 +1,72 @@
+package azlist
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// CallOption overrides the Lister settings for a single List call, which is passed in via the context by WithCallOption.
+// This allows a shared Lister to vary its behavior per call, without rebuilding the clients.
+type CallOption struct {
+	// Parallelism overrides the Lister's parallelism, if positive.
+	Parallelism int
+	// APIVersions maps the resource types (case insensitive) to the preferred api-versions, which are used instead of the latest ones in the ARM schema.
+	APIVersions map[string]string
+	// Headers are injected to every request sent during the call.
+	Headers http.Header
+}
+
+type callOptionKey struct{}
+
+// WithCallOption returns a copy of the context carrying the call option.
+func WithCallOption(ctx context.Context, opt CallOption) context.Context {
+	return context.WithValue(ctx, callOptionKey{}, opt)
+}
+
+func callOptionFrom(ctx context.Context) CallOption {
+	opt, _ := ctx.Value(callOptionKey{}).(CallOption)
+	return opt
+}
+
+func (l *Lister) parallelism(ctx context.Context) int {
+	if n := callOptionFrom(ctx).Parallelism; n > 0 {
+		return n
+	}
+	return l.Parallelism
+}
+
+// apiVersion returns the preferred api-version of the resource type from the call option if any, then the one pinned by the Lister,
+// otherwise the one of the versions picked by the version strategy.
+func (l *Lister) apiVersion(ctx context.Context, rt string, versions []string) string {
+	if v, ok := l.pinnedAPIVersion(ctx, rt); ok {
+		return v
+	}
+	return l.VersionStrategy.Pick(versions)
+}
+
+// pinnedAPIVersion returns the api-version of the resource type pinned by the call option if any, then the one pinned by the Lister.
+func (l *Lister) pinnedAPIVersion(ctx context.Context, rt string) (string, bool) {
+	for _, m := range []map[string]string{callOptionFrom(ctx).APIVersions, l.APIVersions} {
+		for k, v := range m {
+			if strings.EqualFold(k, rt) {
+				return v, true
+			}
+		}
+	}
+	return "", false
+}
+
+// callHeaderPolicy is a per call policy that injects the headers of the call option in the request context.
+type callHeaderPolicy struct{}
+
+func (callHeaderPolicy) Do(req *policy.Request) (*http.Response, error) {
+	for k, vs := range callOptionFrom(req.Raw().Context()).Headers {
+		for _, v := range vs {
+			req.Raw().Header.Add(k, v)
+		}
+	}
+	return req.Next()
+}
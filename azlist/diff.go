@@ -0,0 +1,210 @@
+package azlist
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DiffStatus tells how a resource differs from the baseline.
+type DiffStatus string
+
+const (
+	DiffStatusAdded   DiffStatus = "added"
+	DiffStatusRemoved DiffStatus = "removed"
+	DiffStatusChanged DiffStatus = "changed"
+)
+
+// PatchOp is a JSON patch (RFC 6902) style operation, which transforms the baseline body to the current one.
+type PatchOp struct {
+	// Op is one of "add", "remove" and "replace".
+	Op string `json:"op"`
+	// Path is the JSON pointer (RFC 6901) to the changed value.
+	Path string `json:"path"`
+	// Value is the current value, for the "add" and "replace" operations.
+	Value interface{} `json:"value"`
+	// OldValue is the baseline value, for the "remove" and "replace" operations.
+	OldValue interface{} `json:"oldValue"`
+}
+
+// MarshalJSON only marshals the values that the operation has, where a null value is kept.
+func (op PatchOp) MarshalJSON() ([]byte, error) {
+	out := struct {
+		Op       string       `json:"op"`
+		Path     string       `json:"path"`
+		Value    *interface{} `json:"value,omitempty"`
+		OldValue *interface{} `json:"oldValue,omitempty"`
+	}{Op: op.Op, Path: op.Path}
+	if op.Op != "remove" {
+		out.Value = &op.Value
+	}
+	if op.Op != "add" {
+		out.OldValue = &op.OldValue
+	}
+	return json.Marshal(out)
+}
+
+// ResourceDiff is the difference of a resource between the baseline and the current listing.
+type ResourceDiff struct {
+	Id     string     `json:"id"`
+	Status DiffStatus `json:"status"`
+	// Patch is the difference of the bodies, for the changed resources.
+	Patch []PatchOp `json:"patch,omitempty"`
+	// MergePatch is the JSON merge patch (RFC 7386) of the bodies, for the changed resources.
+	MergePatch map[string]interface{} `json:"-"`
+}
+
+// DiffResources compares the current resources with the baseline ones by their ids (case insensitive), ordered by the ids.
+// If withBody is set, the resources present in both are compared by their bodies as well, with the volatile fields removed, and reported as changed if they differ.
+func DiffResources(baseline, current []AzureResource, withBody bool, fields VolatileFields) []ResourceDiff {
+	base := map[string]AzureResource{}
+	for _, res := range baseline {
+		base[strings.ToUpper(res.Id.String())] = res
+	}
+	var diffs []ResourceDiff
+	seen := map[string]bool{}
+	for _, res := range current {
+		key := strings.ToUpper(res.Id.String())
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		old, ok := base[key]
+		if !ok {
+			diffs = append(diffs, ResourceDiff{Id: res.Id.String(), Status: DiffStatusAdded})
+			continue
+		}
+		if !withBody {
+			continue
+		}
+		rt := ResourceType(res.Id)
+		oldBody, newBody := CanonicalizeBody(rt, old.Properties, fields), CanonicalizeBody(rt, res.Properties, fields)
+		if patch := DiffBody(oldBody, newBody); len(patch) != 0 {
+			mergePatch, _ := MergePatch(oldBody, newBody).(map[string]interface{})
+			diffs = append(diffs, ResourceDiff{Id: res.Id.String(), Status: DiffStatusChanged, Patch: patch, MergePatch: mergePatch})
+		}
+	}
+	for key, res := range base {
+		if !seen[key] {
+			diffs = append(diffs, ResourceDiff{Id: res.Id.String(), Status: DiffStatusRemoved})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Id < diffs[j].Id
+	})
+	return diffs
+}
+
+// DiffBody returns the JSON patch operations that transform the old JSON value to the new one, ordered by the paths.
+// The arrays of different lengths are replaced as a whole.
+func DiffBody(old, new interface{}) []PatchOp {
+	var ops []PatchOp
+	diffValue("", old, new, &ops)
+	return ops
+}
+
+func diffValue(path string, old, new interface{}, ops *[]PatchOp) {
+	switch o := old.(type) {
+	case map[string]interface{}:
+		n, ok := new.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := map[string]bool{}
+		for k := range o {
+			keys[k] = true
+		}
+		for k := range n {
+			keys[k] = true
+		}
+		var sorted []string
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			p := path + "/" + escapePointer(k)
+			ov, inOld := o[k]
+			nv, inNew := n[k]
+			switch {
+			case !inOld:
+				*ops = append(*ops, PatchOp{Op: "add", Path: p, Value: nv})
+			case !inNew:
+				*ops = append(*ops, PatchOp{Op: "remove", Path: p, OldValue: ov})
+			default:
+				diffValue(p, ov, nv, ops)
+			}
+		}
+		return
+	case []interface{}:
+		n, ok := new.([]interface{})
+		if !ok || len(n) != len(o) {
+			break
+		}
+		for i := range o {
+			diffValue(path+"/"+strconv.Itoa(i), o[i], n[i], ops)
+		}
+		return
+	}
+	if !reflect.DeepEqual(old, new) {
+		*ops = append(*ops, PatchOp{Op: "replace", Path: path, Value: new, OldValue: old})
+	}
+}
+
+// JSONPatch returns the RFC 6902 JSON patch document of the operations, where each "replace" and "remove" is preceded by a "test" of the old value,
+// so that applying it fails if the target has drifted from the baseline.
+func JSONPatch(ops []PatchOp) []map[string]interface{} {
+	doc := []map[string]interface{}{}
+	for _, op := range ops {
+		if op.Op != "add" {
+			doc = append(doc, map[string]interface{}{"op": "test", "path": op.Path, "value": op.OldValue})
+		}
+		entry := map[string]interface{}{"op": op.Op, "path": op.Path}
+		if op.Op != "remove" {
+			entry["value"] = op.Value
+		}
+		doc = append(doc, entry)
+	}
+	return doc
+}
+
+// MergePatch returns the JSON merge patch (RFC 7386) that transforms the old JSON value to the new one, which is an empty object if both are equal objects.
+// As the merge patch can't express a null value, the fields changed to null are treated as removed.
+func MergePatch(old, new interface{}) interface{} {
+	o, ok1 := old.(map[string]interface{})
+	n, ok2 := new.(map[string]interface{})
+	if !ok1 || !ok2 {
+		return new
+	}
+	patch := map[string]interface{}{}
+	for k, ov := range o {
+		nv, ok := n[k]
+		if !ok {
+			patch[k] = nil
+			continue
+		}
+		if reflect.DeepEqual(ov, nv) {
+			continue
+		}
+		if _, isMap := nv.(map[string]interface{}); isMap {
+			if _, wasMap := ov.(map[string]interface{}); wasMap {
+				patch[k] = MergePatch(ov, nv)
+				continue
+			}
+		}
+		patch[k] = nv
+	}
+	for k, nv := range n {
+		if _, ok := o[k]; !ok {
+			patch[k] = nv
+		}
+	}
+	return patch
+}
+
+// escapePointer escapes a JSON pointer reference token.
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,92 @@
+package azlist
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+// ErrorClass is the class of a ListError, which tells how to triage it.
+type ErrorClass string
+
+const (
+	// ErrorClassForbidden means the caller has no permission to list.
+	ErrorClassForbidden ErrorClass = "Forbidden"
+	// ErrorClassThrottled means the request is throttled, which can be retried later.
+	ErrorClassThrottled ErrorClass = "Throttled"
+	// ErrorClassNotRegistered means the resource provider is not registered in the subscription.
+	ErrorClassNotRegistered ErrorClass = "NotRegistered"
+	// ErrorClassBadApiVersion means the api-version is not supported by the endpoint.
+	ErrorClassBadApiVersion ErrorClass = "BadApiVersion"
+	// ErrorClassTransient means a server side or network failure, which can be retried.
+	ErrorClassTransient ErrorClass = "Transient"
+	// ErrorClassUnknown is for the others.
+	ErrorClassUnknown ErrorClass = "Unknown"
+)
+
+// newListError builds a ListError from the error, with the status code and error code populated if it is an ARM response error.
+func newListError(endpoint, version string, err error) ListError {
+	e := ListError{
+		Endpoint: endpoint,
+		Version:  version,
+		Message:  err.Error(),
+	}
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		e.StatusCode = respErr.StatusCode
+		e.ErrorCode = respErr.ErrorCode
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		e.ErrorCode = "DeadlineExceeded"
+	}
+	return e
+}
+
+// ClassifyError classifies the ListError by its status code and error code, or by its message if neither is known.
+func ClassifyError(e ListError) ErrorClass {
+	switch strings.ToLower(e.ErrorCode) {
+	case "authorizationfailed", "linkedauthorizationfailed", "invalidauthenticationtoken", "forbidden":
+		return ErrorClassForbidden
+	case "toomanyrequests", "subscriptionrequeststhrottled", "resourcerequeststhrottled":
+		return ErrorClassThrottled
+	case "missingsubscriptionregistration", "subscriptionnotregistered":
+		return ErrorClassNotRegistered
+	case "invalidapiversionparameter", "noregisteredproviderfound":
+		return ErrorClassBadApiVersion
+	case "deadlineexceeded", "internalservererror", "serviceunavailable", "gatewaytimeout", "badgateway":
+		return ErrorClassTransient
+	}
+
+	switch {
+	case e.StatusCode == http.StatusForbidden || e.StatusCode == http.StatusUnauthorized:
+		return ErrorClassForbidden
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrorClassThrottled
+	case e.StatusCode >= 500:
+		return ErrorClassTransient
+	case e.StatusCode != 0:
+		return ErrorClassUnknown
+	}
+
+	msg := strings.ToLower(e.Message)
+	for _, rule := range []struct {
+		class    ErrorClass
+		keywords []string
+	}{
+		{ErrorClassForbidden, []string{"authorizationfailed", "response 403", "response 401"}},
+		{ErrorClassThrottled, []string{"toomanyrequests", "response 429", "throttled"}},
+		{ErrorClassNotRegistered, []string{"missingsubscriptionregistration", "subscriptionnotregistered"}},
+		{ErrorClassBadApiVersion, []string{"invalidapiversionparameter", "noregisteredproviderfound"}},
+		{ErrorClassTransient, []string{"response 500", "response 502", "response 503", "response 504", "connection reset", "deadline exceeded", "timeout"}},
+	} {
+		for _, keyword := range rule.keywords {
+			if strings.Contains(msg, keyword) {
+				return rule.class
+			}
+		}
+	}
+	return ErrorClassUnknown
+}
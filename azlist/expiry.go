@@ -0,0 +1,122 @@
+package azlist
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"strings"
+	"time"
+)
+
+// Expiry is the expiry time of a resource, or of an item (e.g. a certificate) embedded in the resource body.
+type Expiry struct {
+	// Item is the name of the embedded item, or empty for the resource itself.
+	Item string
+	Time time.Time
+}
+
+type expiryFunc func(props map[string]interface{}) []Expiry
+
+// expiryFuncs maps the upper cased resource types to the function extracting the expiries from the resource body.
+var expiryFuncs = map[string]expiryFunc{
+	"MICROSOFT.AUTHORIZATION/POLICYEXEMPTIONS":            expiryAt("properties.expiresOn"),
+	"MICROSOFT.KEYVAULT/VAULTS/SECRETS":                   expiryAt("properties.attributes.exp"),
+	"MICROSOFT.KEYVAULT/VAULTS/KEYS":                      expiryAt("properties.attributes.exp"),
+	"MICROSOFT.WEB/CERTIFICATES":                          expiryAt("properties.expirationDate"),
+	"MICROSOFT.APP/MANAGEDENVIRONMENTS/CERTIFICATES":      expiryAt("properties.expirationDate"),
+	"MICROSOFT.APIMANAGEMENT/SERVICE/CERTIFICATES":        expiryAt("properties.expirationDate"),
+	"MICROSOFT.CERTIFICATEREGISTRATION/CERTIFICATEORDERS": expiryAt("properties.expirationTime"),
+	"MICROSOFT.NETWORK/APPLICATIONGATEWAYS":               applicationGatewayExpiries,
+}
+
+// ResourceExpiries returns the expiries of the resource, if its resource type has any and they are set in the body.
+func ResourceExpiries(res AzureResource) []Expiry {
+	f := expiryFuncs[strings.ToUpper(ResourceType(res.Id))]
+	if f == nil {
+		return nil
+	}
+	return f(res.Properties)
+}
+
+// expiryAt extracts the expiry of the resource itself at the dotted path, which is either a RFC3339 time or an unix timestamp in seconds.
+func expiryAt(path string) expiryFunc {
+	return func(props map[string]interface{}) []Expiry {
+		t, ok := parseExpiry(LookupPath(props, path))
+		if !ok {
+			return nil
+		}
+		return []Expiry{{Time: t}}
+	}
+}
+
+// applicationGatewayExpiries extracts the expiries of the SSL certificates uploaded to the application gateway.
+// The ones referencing a Key Vault secret are not covered, as their public data is not exposed in the body.
+func applicationGatewayExpiries(props map[string]interface{}) []Expiry {
+	certs, _ := LookupPath(props, "properties.sslCertificates").([]interface{})
+	var out []Expiry
+	for _, cert := range certs {
+		cert, ok := cert.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		data, ok := LookupPath(cert, "properties.publicCertData").(string)
+		if !ok {
+			continue
+		}
+		name, _ := cert["name"].(string)
+		if t, ok := pkcs7Expiry(data); ok {
+			out = append(out, Expiry{Item: name, Time: t})
+		}
+	}
+	return out
+}
+
+// pkcs7Expiry returns the earliest expiry of the certificates in the base64 encoded PKCS#7 signed data.
+func pkcs7Expiry(data string) (time.Time, bool) {
+	b, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return time.Time{}, false
+	}
+	var ci struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue `asn1:"explicit,tag:0"`
+	}
+	if _, err := asn1.Unmarshal(b, &ci); err != nil {
+		return time.Time{}, false
+	}
+	var sd struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		ContentInfo      asn1.RawValue
+		Certificates     asn1.RawValue   `asn1:"optional,tag:0"`
+		Rest             []asn1.RawValue `asn1:"optional"`
+	}
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return time.Time{}, false
+	}
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil || len(certs) == 0 {
+		return time.Time{}, false
+	}
+	expiry := certs[0].NotAfter
+	for _, cert := range certs[1:] {
+		if cert.NotAfter.Before(expiry) {
+			expiry = cert.NotAfter
+		}
+	}
+	return expiry, true
+}
+
+func parseExpiry(v interface{}) (time.Time, bool) {
+	switch v := v.(type) {
+	case string:
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t, true
+	case float64:
+		return time.Unix(int64(v), 0), true
+	}
+	return time.Time{}, false
+}
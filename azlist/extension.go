@@ -0,0 +1,129 @@
+package azlist
+
+import (
+	"strings"
+
+	"github.com/magodo/armid"
+)
+
+// builtinExtensionFilters are the filters of the extension resource types (keyed by the upper cased types), which are applied to the ExtensionResource without a Filter.
+// The list calls of these types at a resource scope also return the ones inherited from the parent scopes (e.g. the resource group), which are filtered out
+// so that only the ones at the scope of the current resource are kept.
+var builtinExtensionFilters = map[string]ResourceFilter{
+	"MICROSOFT.AUTHORIZATION/ROLEASSIGNMENTS":   scopePropertyFilter,
+	"MICROSOFT.AUTHORIZATION/POLICYASSIGNMENTS": scopePropertyFilter,
+	"MICROSOFT.AUTHORIZATION/LOCKS":             sameScopeFilter,
+	"MICROSOFT.INSIGHTS/DIAGNOSTICSETTINGS":     sameScopeFilter,
+	"MICROSOFT.SECURITY/ASSESSMENTS":            sameScopeFilter,
+	"MICROSOFT.SECURITY/SUBASSESSMENTS":         sameScopeFilter,
+}
+
+// builtinExtensionVersions returns the api-versions of the extension resource types (keyed by the upper cased types) at the scope, as the api-versions
+// in the ARM schema differ in the scopes they support, which the version strategy is not aware of. These are used unless the api-version is pinned.
+// An empty api-version means the type is not applicable to the scope, which is not listed at all.
+// These types are supported without the ARM schema entries, e.g. the Microsoft.Security ones that are absent in the schema.
+var builtinExtensionVersions = map[string]func(scope armid.ResourceId) string{
+	"MICROSOFT.INSIGHTS/DIAGNOSTICSETTINGS": diagnosticSettingsVersion,
+	// The Defender for Cloud assessments (and their sub-assessments) of all the resources under the scope are listed, which are filtered by the built-in filters.
+	"MICROSOFT.SECURITY/ASSESSMENTS":    nonTenantVersion("2020-01-01"),
+	"MICROSOFT.SECURITY/SUBASSESSMENTS": nonTenantVersion("2019-01-01-preview"),
+}
+
+// IsBuiltinExtensionType tells whether the extension resource type has the built-in api-versions, which doesn't require an ARM schema entry.
+func IsBuiltinExtensionType(rt string) bool {
+	_, ok := builtinExtensionVersions[strings.ToUpper(rt)]
+	return ok
+}
+
+// builtinExtensionNotApplicableCodes are the error codes of the list calls of the extension resource types (keyed by the upper cased types),
+// which mean the type is not applicable to the resource. These are regarded as 404.
+var builtinExtensionNotApplicableCodes = map[string][]string{
+	"MICROSOFT.INSIGHTS/DIAGNOSTICSETTINGS": {"ResourceTypeNotSupported"},
+}
+
+// builtinExtensionNotApplicable tells whether the error code of the list call of the extension resource type means it is not applicable to the resource.
+func builtinExtensionNotApplicable(rt, errorCode string) bool {
+	for _, code := range builtinExtensionNotApplicableCodes[strings.ToUpper(rt)] {
+		if strings.EqualFold(code, errorCode) {
+			return true
+		}
+	}
+	return false
+}
+
+// diagnosticSettingsVersion returns the api-version of the diagnostic settings at the scope, where the management group and subscription ones
+// are only supported by their own api-versions, and the resource groups have no diagnostic settings.
+func diagnosticSettingsVersion(scope armid.ResourceId) string {
+	switch scope.(type) {
+	case *armid.ManagementGroup:
+		return "2020-01-01-preview"
+	case *armid.SubscriptionId:
+		return "2017-05-01-preview"
+	case *armid.ResourceGroup, *armid.TenantId:
+		return ""
+	default:
+		return "2021-05-01-preview"
+	}
+}
+
+// BuiltinExtensionFilter returns the built-in filter of the extension resource type, if any.
+// To list the extension resources of these types without filtering, specify a Filter that always returns true.
+func BuiltinExtensionFilter(rt string) ResourceFilter {
+	return builtinExtensionFilters[strings.ToUpper(rt)]
+}
+
+// withBuiltinExtensionFilters returns the extension resources with the built-in filters set to the ones without a Filter.
+func withBuiltinExtensionFilters(extensions []ExtensionResource) []ExtensionResource {
+	var out []ExtensionResource
+	for _, ext := range extensions {
+		if ext.Filter == nil {
+			ext.Filter = BuiltinExtensionFilter(ext.Type)
+		}
+		out = append(out, ext)
+	}
+	return out
+}
+
+// scopePropertyFilter keeps the extension resources whose "properties.scope" is the id of the resource, e.g. the role assignments and the policy assignments.
+func scopePropertyFilter(res, extensionRes map[string]interface{}) bool {
+	id, ok := res["id"].(string)
+	if !ok {
+		return false
+	}
+	props, ok := extensionRes["properties"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	scope, ok := props["scope"].(string)
+	if !ok {
+		return false
+	}
+	return strings.EqualFold(id, scope)
+}
+
+// sameScopeFilter keeps the extension resources whose ids are scoped to the id of the resource, e.g. the management locks.
+func sameScopeFilter(res, extensionRes map[string]interface{}) bool {
+	id, ok := res["id"].(string)
+	if !ok {
+		return false
+	}
+	extId, ok := extensionRes["id"].(string)
+	if !ok {
+		return false
+	}
+	idx := strings.LastIndex(strings.ToUpper(extId), "/PROVIDERS/")
+	if idx == -1 {
+		return false
+	}
+	return strings.EqualFold(strings.TrimSuffix(id, "/"), extId[:idx])
+}
+
+// nonTenantVersion returns the api-version at any scope but the tenant.
+func nonTenantVersion(version string) func(scope armid.ResourceId) string {
+	return func(scope armid.ResourceId) string {
+		if _, ok := scope.(*armid.TenantId); ok {
+			return ""
+		}
+		return version
+	}
+}
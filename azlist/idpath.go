@@ -0,0 +1,119 @@
+package azlist
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxPathElementLength is the max length in bytes of a path element mapped from a resource id segment,
+// which leaves room for a file extension within the 255 bytes limit of most file systems.
+const maxPathElementLength = 200
+
+// windowsReservedNames are the device names that can't be used as a file name on Windows, with or without an extension.
+var windowsReservedNames = map[string]bool{
+	"con": true, "prn": true, "aux": true, "nul": true,
+	"com1": true, "com2": true, "com3": true, "com4": true, "com5": true, "com6": true, "com7": true, "com8": true, "com9": true,
+	"lpt1": true, "lpt2": true, "lpt3": true, "lpt4": true, "lpt5": true, "lpt6": true, "lpt7": true, "lpt8": true, "lpt9": true,
+}
+
+// ResourceIdPath maps the resource id to a relative file system path, with one path element per id segment,
+// e.g. "subscriptions/xxx/resourcegroups/rg1/providers/microsoft.network/virtualnetworks/vnet1". The mapping is reversed by ResourceIdFromPath.
+//
+// The path is safe to use on Linux, macOS and Windows:
+//
+//   - It is lower cased, as the resource ids are case insensitive, while the file systems may or may not be. Hence the id reversed from it is lower cased.
+//   - The characters that are invalid in Windows file names (i.e. `<>:"\|?*` and the control characters), the "%", and the trailing dots and spaces of each segment
+//     are escaped as "%XX". So is the first character of the segments named after the Windows reserved device names (e.g. "con", "nul.txt").
+//   - The segments longer than 200 bytes (after escaping) are split into multiple path elements, where each but the last ends with a single "%".
+//
+// As the path of a resource is also the directory of its child resources, a file extension (e.g. ".json") shall be appended for the file of the resource.
+// The total length of the path is not bounded, which might exceed the 260 characters limit of Windows, unless its long paths support is enabled.
+func ResourceIdPath(id string) string {
+	var elems []string
+	for _, seg := range strings.Split(strings.Trim(strings.ToLower(id), "/"), "/") {
+		elems = append(elems, splitPathElement(escapePathSegment(seg))...)
+	}
+	return filepath.Join(elems...)
+}
+
+// ResourceIdFromPath reverses the ResourceIdPath, which returns the lower cased resource id of the path.
+func ResourceIdFromPath(p string) (string, error) {
+	var (
+		segs []string
+		cont string
+	)
+	for _, elem := range strings.Split(filepath.ToSlash(filepath.Clean(p)), "/") {
+		seg, more, err := unescapePathElement(elem)
+		if err != nil {
+			return "", fmt.Errorf("invalid path element %q: %v", elem, err)
+		}
+		cont += seg
+		if more {
+			continue
+		}
+		segs = append(segs, cont)
+		cont = ""
+	}
+	if cont != "" {
+		return "", fmt.Errorf("path %q ends with a split segment", p)
+	}
+	return "/" + strings.Join(segs, "/"), nil
+}
+
+func escapePathSegment(seg string) string {
+	var sb strings.Builder
+	// The trailing dots and spaces are stripped by Windows.
+	trailing := len(strings.TrimRight(seg, ". "))
+	base, _, _ := strings.Cut(seg, ".")
+	reserved := windowsReservedNames[base]
+	for i := 0; i < len(seg); i++ {
+		c := seg[i]
+		if c < 0x20 || c == 0x7f || strings.IndexByte(`<>:"\|?*%`, c) != -1 || i >= trailing || (i == 0 && reserved) {
+			fmt.Fprintf(&sb, "%%%02X", c)
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+// splitPathElement splits the escaped segment into the elements of at most maxPathElementLength bytes, without breaking the escapes.
+func splitPathElement(s string) []string {
+	var elems []string
+	for len(s) > maxPathElementLength {
+		n := maxPathElementLength - 1
+		// Don't split within an escape, i.e. "%XX".
+		if i := strings.LastIndexByte(s[n-2:n], '%'); i != -1 {
+			n = n - 2 + i
+		}
+		elems = append(elems, s[:n]+"%")
+		s = s[n:]
+	}
+	return append(elems, s)
+}
+
+// unescapePathElement unescapes the path element, and tells whether it is followed by the rest of a split segment.
+func unescapePathElement(elem string) (seg string, more bool, err error) {
+	var sb strings.Builder
+	for i := 0; i < len(elem); i++ {
+		if elem[i] != '%' {
+			sb.WriteByte(elem[i])
+			continue
+		}
+		if i == len(elem)-1 {
+			return sb.String(), true, nil
+		}
+		if i+2 >= len(elem) {
+			return "", false, fmt.Errorf("malformed escape at %d", i)
+		}
+		c, err := strconv.ParseUint(elem[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", false, fmt.Errorf("malformed escape at %d: %v", i, err)
+		}
+		sb.WriteByte(byte(c))
+		i += 2
+	}
+	return sb.String(), false, nil
+}
@@ -0,0 +1,73 @@
+package azlist
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resourcegraph/armresourcegraph"
+	"github.com/magodo/armid"
+)
+
+// managedResourceGroups returns the resource groups (keyed by the upper cased ids) managed by other resources (e.g. the node resource groups of AKS clusters),
+// in the subscriptions of the resources, together with the ids of the managing resources.
+func (l *Lister) managedResourceGroups(ctx context.Context, rl []AzureResource) (map[string]string, error) {
+	subs := map[string]bool{}
+	var subscriptions []*string
+	for _, res := range rl {
+		sub := subscriptionOf(res.Id)
+		if sub == "" || subs[strings.ToLower(sub)] {
+			continue
+		}
+		subs[strings.ToLower(sub)] = true
+		subscriptions = append(subscriptions, ptr(sub))
+	}
+	out := map[string]string{}
+	if len(subscriptions) == 0 {
+		return out, nil
+	}
+
+	query := "ResourceContainers | where type =~ 'microsoft.resources/subscriptions/resourcegroups' and isnotempty(managedBy) | project id, managedBy"
+	var skipToken *string
+	for {
+		resp, err := l.Client.resourceGraph.Resources(ctx, armresourcegraph.QueryRequest{
+			Query: &query,
+			Options: &armresourcegraph.QueryRequestOptions{
+				ResultFormat: ptr(armresourcegraph.ResultFormatObjectArray),
+				Top:          ptr(int32(1000)),
+				SkipToken:    skipToken,
+			},
+			Subscriptions: subscriptions,
+		}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("executing ARG query %q: %w", query, err)
+		}
+		rows, _ := resp.Data.([]interface{})
+		for _, row := range rows {
+			m, ok := row.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id, _ := m["id"].(string)
+			managedBy, _ := m["managedBy"].(string)
+			if id != "" {
+				out[strings.ToUpper(id)] = managedBy
+			}
+		}
+		if resp.SkipToken == nil || *resp.SkipToken == "" {
+			break
+		}
+		skipToken = resp.SkipToken
+	}
+	return out, nil
+}
+
+// managedResourceGroupOf returns the managing resource of the resource group of the id, if it is one of the managed resource groups.
+func managedResourceGroupOf(managed map[string]string, id armid.ResourceId) (string, bool) {
+	rg, ok := id.RootScope().(*armid.ResourceGroup)
+	if !ok {
+		return "", false
+	}
+	managedBy, ok := managed[strings.ToUpper(rg.String())]
+	return managedBy, ok
+}
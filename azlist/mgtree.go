@@ -0,0 +1,111 @@
+package azlist
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/magodo/armid"
+)
+
+// ManagementGroupNode is a node of the management group hierarchy, which is either a management group or a subscription.
+type ManagementGroupNode struct {
+	// Id is the resource id, e.g. "/providers/Microsoft.Management/managementGroups/mg1" or "/subscriptions/xxx".
+	Id          string                 `json:"id"`
+	Name        string                 `json:"name"`
+	DisplayName string                 `json:"displayName,omitempty"`
+	Children    []*ManagementGroupNode `json:"children,omitempty"`
+}
+
+// IsSubscription tells whether the node is a subscription.
+func (n *ManagementGroupNode) IsSubscription() bool {
+	return strings.HasPrefix(strings.ToLower(n.Id), "/subscriptions/")
+}
+
+// Subscriptions returns the ids of the subscriptions under the node, recursively.
+func (n *ManagementGroupNode) Subscriptions() []string {
+	var out []string
+	if n.IsSubscription() {
+		out = append(out, n.Name)
+	}
+	for _, child := range n.Children {
+		out = append(out, child.Subscriptions()...)
+	}
+	return out
+}
+
+// Find returns the node of the management group (or subscription) name (case insensitive) under the node, or nil if not found.
+func (n *ManagementGroupNode) Find(name string) *ManagementGroupNode {
+	if strings.EqualFold(n.Name, name) {
+		return n
+	}
+	for _, child := range n.Children {
+		if found := child.Find(name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// ManagementGroupTree reads the management group hierarchy under the management group by the Management Groups API.
+// If the name is empty, the tenant root management group is used.
+func (l *Lister) ManagementGroupTree(ctx context.Context, name string) (*ManagementGroupNode, error) {
+	version := l.versionOf(ctx, &armid.ManagementGroup{})
+	if name == "" {
+		items, err := l.listChildItems(ctx, "providers/Microsoft.Management", "managementGroups", version, nil)
+		if err != nil {
+			return nil, fmt.Errorf("listing management groups: %v", err)
+		}
+		for _, w := range items {
+			props, _, err := decodeItem(w)
+			if err != nil {
+				return nil, err
+			}
+			// The tenant root management group is named after the tenant id.
+			if mgName, _ := props["name"].(string); mgName != "" && strings.EqualFold(mgName, fmt.Sprint(LookupPath(props, "properties.tenantId"))) {
+				name = mgName
+				break
+			}
+		}
+		if name == "" {
+			return nil, fmt.Errorf("no tenant root management group is accessible")
+		}
+	}
+
+	id := &armid.ManagementGroup{Name: name}
+	props, err := l.Client.resource.GetWithQuery(ctx, id.String(), version, url.Values{
+		"$expand":  []string{"children"},
+		"$recurse": []string{"true"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting management group %s: %w", name, err)
+	}
+	return managementGroupNode(props), nil
+}
+
+// managementGroupNode builds the node from a management group body, or one of the "properties.children" of it.
+func managementGroupNode(props map[string]interface{}) *ManagementGroupNode {
+	node := &ManagementGroupNode{}
+	node.Id, _ = props["id"].(string)
+	node.Name, _ = props["name"].(string)
+	if v, ok := LookupPath(props, "properties.displayName").(string); ok {
+		node.DisplayName = v
+	} else if v, ok := props["displayName"].(string); ok {
+		node.DisplayName = v
+	}
+	children, ok := LookupPath(props, "properties.children").([]interface{})
+	if !ok {
+		children, _ = props["children"].([]interface{})
+	}
+	for _, child := range children {
+		if m, ok := child.(map[string]interface{}); ok {
+			node.Children = append(node.Children, managementGroupNode(m))
+		}
+	}
+	sort.Slice(node.Children, func(i, j int) bool {
+		return node.Children[i].Id < node.Children[j].Id
+	})
+	return node
+}
@@ -0,0 +1,96 @@
+package azlist
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// NotFoundWarning counts the child (or extension) resource listings of a resource type that are responded with 404, which are ignored unless Option.RecordNotFound is set.
+// The error code tells the cause, e.g. "ResourceNotFound" usually means the resource type is not applicable to the parent resource,
+// while "InvalidResourceType" or "NoRegisteredProviderFound" means the resource type (or its provider) is not available in the subscription.
+type NotFoundWarning struct {
+	ResourceType string `json:"resourceType"`
+	ErrorCode    string `json:"errorCode,omitempty"`
+	Count        int    `json:"count"`
+	// Endpoint is one of the listed endpoints, as an example.
+	Endpoint string `json:"endpoint"`
+}
+
+// notFoundRecorder collects the 404 responses of the listings during a List call.
+type notFoundRecorder struct {
+	mu       sync.Mutex
+	warnings map[string]*NotFoundWarning
+}
+
+func newNotFoundRecorder() *notFoundRecorder {
+	return &notFoundRecorder{warnings: map[string]*NotFoundWarning{}}
+}
+
+type notFoundRecorderKey struct{}
+
+func withNotFoundRecorder(ctx context.Context, r *notFoundRecorder) context.Context {
+	return context.WithValue(ctx, notFoundRecorderKey{}, r)
+}
+
+func notFoundRecorderFrom(ctx context.Context) *notFoundRecorder {
+	r, _ := ctx.Value(notFoundRecorderKey{}).(*notFoundRecorder)
+	return r
+}
+
+func (r *notFoundRecorder) record(rt, endpoint, errorCode string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := strings.ToUpper(rt) + ":" + strings.ToUpper(errorCode)
+	if w, ok := r.warnings[key]; ok {
+		w.Count++
+		return
+	}
+	r.warnings[key] = &NotFoundWarning{ResourceType: rt, ErrorCode: errorCode, Count: 1, Endpoint: endpoint}
+}
+
+// list returns the warnings ordered by the counts in descending order, then the resource types.
+func (r *notFoundRecorder) list() []NotFoundWarning {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []NotFoundWarning
+	for _, w := range r.warnings {
+		out = append(out, *w)
+	}
+	sortNotFoundWarnings(out)
+	return out
+}
+
+func sortNotFoundWarnings(warnings []NotFoundWarning) {
+	sort.Slice(warnings, func(i, j int) bool {
+		if warnings[i].Count != warnings[j].Count {
+			return warnings[i].Count > warnings[j].Count
+		}
+		if !strings.EqualFold(warnings[i].ResourceType, warnings[j].ResourceType) {
+			return strings.ToUpper(warnings[i].ResourceType) < strings.ToUpper(warnings[j].ResourceType)
+		}
+		return warnings[i].ErrorCode < warnings[j].ErrorCode
+	})
+}
+
+// mergeNotFoundWarnings merges the warnings of the same resource type and error code, by summing up their counts.
+func mergeNotFoundWarnings(warnings []NotFoundWarning) []NotFoundWarning {
+	r := newNotFoundRecorder()
+	for _, w := range warnings {
+		key := strings.ToUpper(w.ResourceType) + ":" + strings.ToUpper(w.ErrorCode)
+		if v, ok := r.warnings[key]; ok {
+			v.Count += w.Count
+			continue
+		}
+		w := w
+		r.warnings[key] = &w
+	}
+	return r.list()
+}
@@ -0,0 +1,304 @@
+package azlist
+
+import (
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ARMSchemaCachePath returns the default path of the cached ARM schema file written by UpdateARMSchemaCache, whose api-versions are merged into the embedded ARM schema
+// if set as the Option.ARMSchemaCacheFile.
+func ARMSchemaCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "azlist", "armschema.json"), nil
+}
+
+// armSchemaMetaFile is the provenance of the embedded ARM schema file, which is regenerated together with it by "make schema-meta".
+//
+//go:embed armschema_meta.json
+var armSchemaMetaFile []byte
+
+// The provenance of the embedded ARM schema file, which defaults to the armSchemaMetaFile.
+// To override them from outside, use go build -ldflags "-X 'github.com/magodo/azlist/azlist.schemaCommit=$(COMMIT)' -X 'github.com/magodo/azlist/azlist.schemaGeneratedAt=$(DATE)'"
+var (
+	schemaCommit      string
+	schemaGeneratedAt string
+)
+
+// embeddedSchemaMeta returns the commit and generation time of the embedded ARM schema file.
+func embeddedSchemaMeta() (commit, generatedAt string) {
+	var meta struct {
+		Commit      string `json:"commit"`
+		GeneratedAt string `json:"generatedAt"`
+	}
+	// The metadata file is generated, whose malformation only leaves the provenance empty.
+	json.Unmarshal(armSchemaMetaFile, &meta)
+	commit, generatedAt = meta.Commit, meta.GeneratedAt
+	if schemaCommit != "" {
+		commit = schemaCommit
+	}
+	if schemaGeneratedAt != "" {
+		generatedAt = schemaGeneratedAt
+	}
+	return commit, generatedAt
+}
+
+// ARMSchemaSource tells where the ARM schema is from.
+type ARMSchemaSource string
+
+const (
+	ARMSchemaSourceEmbedded ARMSchemaSource = "embedded"
+	ARMSchemaSourceCache    ARMSchemaSource = "cache"
+	ARMSchemaSourceCustom   ARMSchemaSource = "custom"
+	ARMSchemaSourceLive     ARMSchemaSource = "live"
+)
+
+// ARMSchemaInfo describes the provenance of the ARM schema, which tells how stale the resource type knowledge is.
+type ARMSchemaInfo struct {
+	Source ARMSchemaSource `json:"source"`
+	// Path is the path of the cached ARM schema file, for the "cache" source.
+	Path string `json:"path,omitempty"`
+	// Commit and GeneratedAt are the provenance of the embedded ARM schema file, if set at build time.
+	Commit      string `json:"commit,omitempty"`
+	GeneratedAt string `json:"generatedAt,omitempty"`
+	// Digest is the digest of the ARM schema file, in the form of "sha256:<hex>".
+	Digest string `json:"digest,omitempty"`
+	// Overlaid tells whether an overlay file is merged.
+	Overlaid      bool `json:"overlaid,omitempty"`
+	ResourceTypes int  `json:"resourceTypes"`
+	// LatestAPIVersion is the latest api-version of all the resource types.
+	LatestAPIVersion string `json:"latestApiVersion"`
+}
+
+func newARMSchemaInfo(source ARMSchemaSource, path string, armSchemaFile []byte) (ARMSchemaInfo, error) {
+	var schemas map[string][]string
+	if err := json.Unmarshal(armSchemaFile, &schemas); err != nil {
+		return ARMSchemaInfo{}, err
+	}
+	return newARMSchemaInfoOfTypes(source, path, armSchemaFile, schemas), nil
+}
+
+func newARMSchemaInfoOfTypes(source ARMSchemaSource, path string, armSchemaFile []byte, schemas map[string][]string) ARMSchemaInfo {
+	info := armSchemaInfoOfTypes(source, schemas)
+	info.Path = path
+	sum := sha256.Sum256(armSchemaFile)
+	info.Digest = "sha256:" + hex.EncodeToString(sum[:])
+	if source == ARMSchemaSourceEmbedded {
+		info.Commit, info.GeneratedAt = embeddedSchemaMeta()
+	}
+	return info
+}
+
+func armSchemaInfoOfTypes(source ARMSchemaSource, types map[string][]string) ARMSchemaInfo {
+	info := ARMSchemaInfo{
+		Source:        source,
+		ResourceTypes: len(types),
+	}
+	for _, versions := range types {
+		for _, v := range versions {
+			if v > info.LatestAPIVersion {
+				info.LatestAPIVersion = v
+			}
+		}
+	}
+	return info
+}
+
+// SchemaInfo returns the provenance of the ARM schema that is used by default, i.e. the embedded one merged with the cache file (see LoadARMSchemaFile).
+func SchemaInfo(cacheFile string) (ARMSchemaInfo, error) {
+	b, source, err := loadARMSchemaFile(cacheFile)
+	if err != nil {
+		return ARMSchemaInfo{}, err
+	}
+	path := ""
+	if source == ARMSchemaSourceCache {
+		path = cacheFile
+	}
+	return newARMSchemaInfo(source, path, b)
+}
+
+// LoadARMSchemaFile returns the content of the embedded ARMSchemaFile, with the api-versions of the cache file (see UpdateARMSchemaCache) merged if it is set and exists.
+// The cache file only adds the resource types and api-versions, so that the newer ones embedded by later releases are not shadowed by a stale cache.
+func LoadARMSchemaFile(cacheFile string) ([]byte, error) {
+	b, _, err := loadARMSchemaFile(cacheFile)
+	return b, err
+}
+
+func loadARMSchemaFile(cacheFile string) ([]byte, ARMSchemaSource, error) {
+	if cacheFile == "" {
+		return ARMSchemaFile, ARMSchemaSourceEmbedded, nil
+	}
+	b, err := os.ReadFile(cacheFile)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ARMSchemaFile, ARMSchemaSourceEmbedded, nil
+		}
+		return nil, "", fmt.Errorf("reading the cached ARM schema file %s: %v", cacheFile, err)
+	}
+	var cached map[string][]string
+	if err := json.Unmarshal(b, &cached); err != nil {
+		return nil, "", fmt.Errorf("parsing the cached ARM schema file %s: %v", cacheFile, err)
+	}
+	var schemas map[string][]string
+	if err := json.Unmarshal(ARMSchemaFile, &schemas); err != nil {
+		return nil, "", err
+	}
+	mergeARMSchemaVersions(schemas, cached)
+	b, err = json.Marshal(schemas)
+	if err != nil {
+		return nil, "", err
+	}
+	return b, ARMSchemaSourceCache, nil
+}
+
+// mergeARMSchemaVersions merges the resource types and their api-versions into the schemas, where the resource types are matched case insensitively.
+func mergeARMSchemaVersions(schemas, types map[string][]string) {
+	keys := map[string]string{}
+	for rt := range schemas {
+		keys[strings.ToUpper(rt)] = rt
+	}
+	for rt, versions := range types {
+		if key, ok := keys[strings.ToUpper(rt)]; ok {
+			rt = key
+		}
+		seen := map[string]bool{}
+		var merged []string
+		for _, v := range append(schemas[rt], versions...) {
+			if !seen[v] {
+				seen[v] = true
+				merged = append(merged, v)
+			}
+		}
+		sort.Strings(merged)
+		schemas[rt] = merged
+	}
+}
+
+// LoadARMSchemaTree builds the ARM schema tree from the ARM schema file, or the one returned by LoadARMSchemaFile of the cache file if nil, with the overlay file merged if not nil.
+func LoadARMSchemaTree(armSchemaFile, overlay []byte, cacheFile string) (ARMSchemaTree, error) {
+	tree, _, err := loadARMSchemaTree(armSchemaFile, overlay, cacheFile)
+	return tree, err
+}
+
+func loadARMSchemaTree(armSchemaFile, overlay []byte, cacheFile string) (ARMSchemaTree, ARMSchemaInfo, error) {
+	source, path := ARMSchemaSourceCustom, ""
+	if armSchemaFile == nil {
+		var err error
+		if armSchemaFile, source, err = loadARMSchemaFile(cacheFile); err != nil {
+			return nil, ARMSchemaInfo{}, err
+		}
+		if source == ARMSchemaSourceCache {
+			path = cacheFile
+		}
+	}
+
+	var overlays [][]byte
+	if overlay != nil {
+		overlays = append(overlays, overlay)
+	}
+	schemas, err := parseARMSchema(armSchemaFile, overlays...)
+	if err != nil {
+		return nil, ARMSchemaInfo{}, err
+	}
+	// The schemas are consumed by the building.
+	info := newARMSchemaInfoOfTypes(source, path, armSchemaFile, schemas)
+	info.Overlaid = overlay != nil
+	tree, err := BuildARMSchemaTreeFromTypes(schemas)
+	if err != nil {
+		return nil, ARMSchemaInfo{}, err
+	}
+	return tree, info, nil
+}
+
+// RefreshSchema rebuilds the ARM schema tree from the live resource provider metadata of the subscription, and swaps it in.
+// It is safe to call concurrently with the List calls, which see either the old or the new tree for each lookup.
+func (l *Lister) RefreshSchema(ctx context.Context) error {
+	if l.SubscriptionId == "" {
+		return fmt.Errorf("refreshing the schema requires a subscription id")
+	}
+	types, err := l.Client.ProviderResourceTypes(ctx, l.SubscriptionId)
+	if err != nil {
+		return err
+	}
+	// The types are consumed by the building.
+	info := armSchemaInfoOfTypes(ARMSchemaSourceLive, types)
+	tree, err := BuildARMSchemaTreeFromTypes(types)
+	if err != nil {
+		return err
+	}
+	l.schemaMu.Lock()
+	l.ARMSchemaTree = tree
+	l.ARMSchemaInfo = info
+	l.schemaMu.Unlock()
+	l.Debug("Live schema loaded", "resource types", len(tree))
+	return nil
+}
+
+// RunSchemaRefresh refreshes the ARM schema tree (see RefreshSchema) every interval, until the context is done.
+// It is meant for the long lived Listers, which shall run it in a separate goroutine. A failed refresh keeps the current tree.
+func (l *Lister) RunSchemaRefresh(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("schema refresh interval must be positive, got %s", interval)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := l.RefreshSchema(ctx); err != nil {
+				l.Warn("Refreshing the schema", "error", err)
+			}
+		}
+	}
+}
+
+// UpdateARMSchemaCache writes the resource types and their api-versions to the cache file (see ARMSchemaCachePath if empty), replacing the previous content.
+// The cache file is merged into the embedded ARM schema when loaded (see LoadARMSchemaFile). It returns the cache path and the number of resource types written.
+func UpdateARMSchemaCache(cacheFile string, types map[string][]string) (string, int, error) {
+	b, err := json.MarshalIndent(types, "", "  ")
+	if err != nil {
+		return "", 0, err
+	}
+	// Ensure the schema is valid, before it is used by the following runs.
+	var schemas map[string][]string
+	if err := json.Unmarshal(ARMSchemaFile, &schemas); err != nil {
+		return "", 0, err
+	}
+	mergeARMSchemaVersions(schemas, types)
+	if _, err := BuildARMSchemaTreeFromTypes(schemas); err != nil {
+		return "", 0, fmt.Errorf("building the ARM schema tree: %v", err)
+	}
+
+	path := cacheFile
+	if path == "" {
+		if path, err = ARMSchemaCachePath(); err != nil {
+			return "", 0, err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", 0, err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", 0, err
+	}
+	return path, len(types), nil
+}
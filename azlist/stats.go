@@ -0,0 +1,148 @@
+package azlist
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Progress is a snapshot of the progress of a List call.
+type Progress struct {
+	// Phase is the current phase, e.g. "listing child resources".
+	Phase string
+	// Discovered is the number of resources discovered so far.
+	Discovered int
+	// Errors is the number of the child and extension resource listings failed so far.
+	Errors int
+	// Queued is the number of the child and extension resource listings waiting to run.
+	Queued int
+	// InFlight is the number of the running child and extension resource listings, per provider.
+	InFlight map[string]int
+	// Completed is the number of the child and extension resource listings succeeded so far.
+	Completed int
+}
+
+// listStats tracks the progress of a List call.
+type listStats struct {
+	mu       sync.Mutex
+	progress Progress
+}
+
+func newListStats() *listStats {
+	return &listStats{progress: Progress{InFlight: map[string]int{}}}
+}
+
+type listStatsKey struct{}
+
+func withListStats(ctx context.Context, stats *listStats) context.Context {
+	return context.WithValue(ctx, listStatsKey{}, stats)
+}
+
+func listStatsFrom(ctx context.Context) *listStats {
+	stats, _ := ctx.Value(listStatsKey{}).(*listStats)
+	return stats
+}
+
+func (s *listStats) setPhase(phase string, discovered int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.progress.Phase = phase
+	s.progress.Discovered = discovered
+}
+
+func (s *listStats) enqueue() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.progress.Queued++
+}
+
+// discover counts the n resources discovered, e.g. by an ARG page, other than the child and extension resource listings.
+func (s *listStats) discover(n int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.progress.Discovered += n
+}
+
+// drop uncounts the n queued listings that are dropped without running.
+func (s *listStats) drop(n int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.progress.Queued -= n
+}
+
+func (s *listStats) start(provider string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.progress.Queued--
+	s.progress.InFlight[provider]++
+}
+
+func (s *listStats) done(provider string, discovered int, failed bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.progress.InFlight[provider]--
+	if s.progress.InFlight[provider] == 0 {
+		delete(s.progress.InFlight, provider)
+	}
+	s.progress.Discovered += discovered
+	if failed {
+		s.progress.Errors++
+	} else {
+		s.progress.Completed++
+	}
+}
+
+func (s *listStats) snapshot() Progress {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.progress
+	p.InFlight = map[string]int{}
+	for k, v := range s.progress.InFlight {
+		p.InFlight[k] = v
+	}
+	return p
+}
+
+// reportProgress logs the progress every interval, and passes it to the OnProgress callback if any, until the context is done.
+func (l *Lister) reportProgress(ctx context.Context, stats *listStats, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p := stats.snapshot()
+			var inflight []string
+			for provider, n := range p.InFlight {
+				inflight = append(inflight, provider+"="+strconv.Itoa(n))
+			}
+			sort.Strings(inflight)
+			l.Debug("List progress", "phase", p.Phase, "discovered", p.Discovered, "queued", p.Queued, "in-flight", strings.Join(inflight, ","), "completed", p.Completed, "failed", p.Errors)
+			if l.OnProgress != nil {
+				l.OnProgress(p)
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package azlist
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resourcegraph/armresourcegraph"
+)
+
+// Subscription is a subscription accessible in the tenant.
+type Subscription struct {
+	Id          string            `json:"id"`
+	DisplayName string            `json:"displayName"`
+	State       string            `json:"state"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	// QuotaId tells the offer type of the subscription, e.g. "PayAsYouGo_2014-09-01", "MSDN_2014-09-01".
+	QuotaId string `json:"quotaId,omitempty"`
+}
+
+// SubscriptionFilter selects the subscriptions to list in the tenant mode. A subscription is selected if it matches all the non-empty conditions.
+type SubscriptionFilter struct {
+	// Name matches the display name of the subscription.
+	Name *regexp.Regexp
+	// Tags matches the tags of the subscription, where the keys are case insensitive and the values are case sensitive.
+	Tags map[string]string
+	// OfferTypes matches the quota id of the subscription by prefix (case insensitive), e.g. "MSDN" for the Visual Studio subscriptions.
+	OfferTypes []string
+	// Exclude are the ids or display names (case insensitive) of the subscriptions to skip.
+	Exclude []string
+}
+
+// IsEmpty tells whether the filter selects all the subscriptions.
+func (f SubscriptionFilter) IsEmpty() bool {
+	return f.Name == nil && len(f.Tags) == 0 && len(f.OfferTypes) == 0 && len(f.Exclude) == 0
+}
+
+// Match tells whether the subscription is selected by the filter.
+func (f SubscriptionFilter) Match(sub Subscription) bool {
+	for _, v := range f.Exclude {
+		if strings.EqualFold(v, sub.Id) || strings.EqualFold(v, sub.DisplayName) {
+			return false
+		}
+	}
+	if f.Name != nil && !f.Name.MatchString(sub.DisplayName) {
+		return false
+	}
+	for k, v := range f.Tags {
+		found := false
+		for tk, tv := range sub.Tags {
+			if strings.EqualFold(k, tk) && v == tv {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.OfferTypes) != 0 {
+		found := false
+		for _, v := range f.OfferTypes {
+			if strings.HasPrefix(strings.ToLower(sub.QuotaId), strings.ToLower(v)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Subscriptions lists the subscriptions accessible in the tenant by ARG, ordered by the id.
+func (l *Lister) Subscriptions(ctx context.Context) ([]Subscription, error) {
+	query := "ResourceContainers | where type =~ 'microsoft.resources/subscriptions' | project subscriptionId, name, tags, state = properties.state, quotaId = properties.subscriptionPolicies.quotaId"
+	var (
+		subs      []Subscription
+		skipToken *string
+	)
+	for {
+		resp, err := l.Client.resourceGraph.Resources(ctx, armresourcegraph.QueryRequest{
+			Query: &query,
+			Options: &armresourcegraph.QueryRequestOptions{
+				ResultFormat: ptr(armresourcegraph.ResultFormatObjectArray),
+				Top:          ptr(int32(1000)),
+				SkipToken:    skipToken,
+			},
+		}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("executing ARG query %q: %w", query, err)
+		}
+		rows, _ := resp.Data.([]interface{})
+		for _, row := range rows {
+			m, ok := row.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			sub := Subscription{Tags: map[string]string{}}
+			sub.Id, _ = m["subscriptionId"].(string)
+			sub.DisplayName, _ = m["name"].(string)
+			sub.State, _ = m["state"].(string)
+			sub.QuotaId, _ = m["quotaId"].(string)
+			if tags, ok := m["tags"].(map[string]interface{}); ok {
+				for k, v := range tags {
+					sub.Tags[k] = fmt.Sprint(v)
+				}
+			}
+			if sub.Id != "" {
+				subs = append(subs, sub)
+			}
+		}
+		if resp.SkipToken == nil || *resp.SkipToken == "" {
+			break
+		}
+		skipToken = resp.SkipToken
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		return subs[i].Id < subs[j].Id
+	})
+	return subs, nil
+}
@@ -0,0 +1,72 @@
+package azlist
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/magodo/armid"
+)
+
+// TenantResourceTypes are the resource types listed at the tenant root scope for IncludeTenantResources.
+var TenantResourceTypes = []string{
+	"Microsoft.Management/managementGroups",
+	"Microsoft.Authorization/policyDefinitions",
+	"Microsoft.Authorization/policySetDefinitions",
+}
+
+// ListTenantResources lists the resources of the TenantResourceTypes at the tenant root scope, whose ids are tenant scoped (e.g. "/providers/Microsoft.Authorization/policyDefinitions/xxx").
+// The management groups are each read afterwards, so that their bodies contain the details of the hierarchy (i.e. "properties.details.parent").
+// A management group that fails to read is recorded as a ListError, and keeps the body from the list response.
+func (l *Lister) ListTenantResources(ctx context.Context) ([]AzureResource, []ListError, []UnparseableResource, error) {
+	var (
+		rl []AzureResource
+		el []ListError
+		ul []UnparseableResource
+	)
+	for _, rt := range TenantResourceTypes {
+		var versions []string
+		if entry, ok := l.schemaEntry(rt); ok {
+			versions = entry.Versions
+		}
+		version := l.apiVersion(ctx, rt, versions)
+		provider, typ, _ := strings.Cut(rt, "/")
+		endpoint := strings.ToUpper("/providers/" + rt)
+
+		l.Debug("Listing tenant resources", "resource type", rt, "api version", version)
+		items, err := l.listChildItems(ctx, "providers/"+provider, typ, version, nil)
+		if err != nil {
+			el = append(el, newListError(endpoint, version, err))
+		}
+		for _, w := range items {
+			props, b, err := decodeItem(w)
+			if err != nil {
+				el = append(el, newListError(endpoint, version, err))
+				continue
+			}
+			id, err := itemId(props, b)
+			if err != nil {
+				el = append(el, newListError(endpoint, version, err))
+				continue
+			}
+			azureId, err := l.ParseResourceId(id)
+			if err != nil {
+				ul = append(ul, UnparseableResource{Id: id, Properties: props, Message: err.Error()})
+				continue
+			}
+			if _, ok := azureId.(*armid.ManagementGroup); ok {
+				if mgProps, err := l.Client.resource.Get(ctx, azureId.String(), version); err != nil {
+					el = append(el, newListError(strings.ToUpper(azureId.String()), version, fmt.Errorf("getting management group %s: %w", azureId.String(), err)))
+				} else {
+					props = mgProps
+				}
+			}
+			rl = append(rl, AzureResource{
+				Id:         azureId,
+				Properties: props,
+				APIVersion: version,
+			})
+		}
+	}
+	return rl, el, ul, nil
+}
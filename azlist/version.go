@@ -0,0 +1,63 @@
+package azlist
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VersionStrategy decides which api-version of a resource type in the ARM schema is used, when it is not pinned.
+type VersionStrategy string
+
+const (
+	// VersionStrategyLatest uses the lexically last api-version, which might be a preview one.
+	VersionStrategyLatest VersionStrategy = "latest"
+	// VersionStrategyLatestStable uses the last stable api-version, or the last preview one if there is no stable one.
+	VersionStrategyLatestStable VersionStrategy = "latest-stable"
+	// VersionStrategyOldestStable uses the first stable api-version, or the first preview one if there is no stable one.
+	VersionStrategyOldestStable VersionStrategy = "oldest-stable"
+)
+
+// PossibleVersionStrategyValues returns the possible values of the VersionStrategy.
+func PossibleVersionStrategyValues() []VersionStrategy {
+	return []VersionStrategy{VersionStrategyLatest, VersionStrategyLatestStable, VersionStrategyOldestStable}
+}
+
+// Validate checks the version strategy is one of the PossibleVersionStrategyValues.
+func (s VersionStrategy) Validate() error {
+	var values []string
+	for _, v := range PossibleVersionStrategyValues() {
+		if s == v {
+			return nil
+		}
+		values = append(values, fmt.Sprintf("%q", v))
+	}
+	return fmt.Errorf("unknown version strategy %q: possible values are %s", s, strings.Join(values, ", "))
+}
+
+// Pick picks the api-version from the versions, which are sorted in ascending order. It returns empty string if there is no version.
+func (s VersionStrategy) Pick(versions []string) string {
+	if len(versions) == 0 {
+		return ""
+	}
+	switch s {
+	case VersionStrategyLatestStable:
+		for i := len(versions) - 1; i >= 0; i-- {
+			if isStableVersion(versions[i]) {
+				return versions[i]
+			}
+		}
+	case VersionStrategyOldestStable:
+		for _, v := range versions {
+			if isStableVersion(v) {
+				return v
+			}
+		}
+		return versions[0]
+	}
+	return versions[len(versions)-1]
+}
+
+// isStableVersion tells whether the api-version is a stable one, i.e. not suffixed by "-preview", "-beta", etc.
+func isStableVersion(version string) bool {
+	return !strings.Contains(version, "-preview") && !strings.Contains(version, "-beta") && !strings.Contains(version, "-alpha") && !strings.Contains(version, "-privatepreview")
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/magodo/azlist/azlist"
+	"github.com/magodo/workerpool"
+)
+
+// bulkOperation operates on one resource, returning a message on success.
+type bulkOperation func(res azlist.AzureResource) (string, error)
+
+// bulkRun runs the operation on each resource in parallel, and prints the outcome of each operation.
+// Resources for which the operation returns an empty message without error are skipped silently.
+func bulkRun(w io.Writer, action string, parallelism int, rl []azlist.AzureResource, op bulkOperation) error {
+	type outcome struct {
+		res azlist.AzureResource
+		msg string
+		err error
+	}
+
+	var failures int
+	wp := workerpool.NewWorkPool(parallelism)
+	wp.Run(func(i interface{}) error {
+		o := i.(outcome)
+		switch {
+		case o.err != nil:
+			failures++
+			fmt.Fprintf(w, "Failed to %s %s: %v\n", action, o.res.Id.String(), o.err)
+		case o.msg != "":
+			fmt.Fprintln(w, o.msg)
+		}
+		return nil
+	})
+	for _, res := range rl {
+		res := res
+		wp.AddTask(func() (interface{}, error) {
+			msg, err := op(res)
+			return outcome{res: res, msg: msg, err: err}, nil
+		})
+	}
+	if err := wp.Done(); err != nil {
+		return err
+	}
+	if failures != 0 {
+		return fmt.Errorf("failed to %s %d resources", action, failures)
+	}
+	return nil
+}
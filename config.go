@@ -0,0 +1,46 @@
+package main
+
+import (
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// rootContext returns the context of the root app, which defines the global flags.
+func rootContext(ctx *cli.Context) *cli.Context {
+	// The global flags are defined by the root app, which is the outermost one in the lineage.
+	root := ctx
+	for _, c := range ctx.Lineage() {
+		if c.App != nil {
+			root = c
+		}
+	}
+	return root
+}
+
+// flagValue returns the value of the flag in the context, where the string slices are turned into []string, and the durations into strings.
+func flagValue(ctx *cli.Context, name string) interface{} {
+	switch v := ctx.Value(name).(type) {
+	case cli.StringSlice:
+		return v.Value()
+	case *cli.StringSlice:
+		return v.Value()
+	case time.Duration:
+		return v.String()
+	default:
+		return v
+	}
+}
+
+// effectiveConfig returns the resolved values of the global flags, after the flags and environment variables are merged, keyed by the flag names.
+func effectiveConfig(ctx *cli.Context) map[string]interface{} {
+	out := map[string]interface{}{}
+	for _, f := range rootContext(ctx).App.Flags {
+		name := f.Names()[0]
+		if name == "help" || name == "version" {
+			continue
+		}
+		out[name] = flagValue(ctx, name)
+	}
+	return out
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+// newCredential returns the managed identity credential if useManagedIdentity is set (the user-assigned one if the client id is not empty, otherwise the system-assigned one),
+// or the default credential.
+func newCredential(clientOpt policy.ClientOptions, tenantId string, useManagedIdentity bool, miClientId string) (azcore.TokenCredential, error) {
+	if !useManagedIdentity && miClientId == "" {
+		return azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
+			ClientOptions: clientOpt,
+			TenantID:      tenantId,
+		})
+	}
+
+	// Leave the retry options zero valued, so that the IMDS specific defaults apply: IMDS throttles the token requests per VM (429),
+	// and recommends retrying on 404, 429 and 5xx with exponential backoff, which is up to 5 retries and 1 minute delay.
+	// The other managed identity endpoints (e.g. App Service, Functions, Container Apps) use the default retry options, which retry on 429 as well.
+	clientOpt.Retry = policy.RetryOptions{}
+	var id azidentity.ManagedIDKind
+	if miClientId != "" {
+		id = azidentity.ClientID(miClientId)
+	}
+	return azidentity.NewManagedIdentityCredential(&azidentity.ManagedIdentityCredentialOptions{
+		ClientOptions: clientOpt,
+		ID:            id,
+	})
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/magodo/azlist/azlist"
+)
+
+// volatileFields returns the default volatile fields, together with the additional ones in form of "[<resource type>:]<dotted path>".
+func volatileFields(extra []string) azlist.VolatileFields {
+	fields := azlist.VolatileFields{}
+	for rt, paths := range azlist.DefaultVolatileFields {
+		fields[rt] = append(fields[rt], paths...)
+	}
+	for _, field := range extra {
+		rt, path := "*", field
+		if before, after, ok := strings.Cut(field, ":"); ok {
+			rt, path = before, after
+		}
+		fields.Add(rt, path)
+	}
+	return fields
+}
+
+// diffBaseline compares the list result with the baseline, which is the JSON output of a previous run.
+func diffBaseline(path string, result *azlist.ListResult, withBody bool, fields azlist.VolatileFields) ([]azlist.ResourceDiff, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading --baseline: %v", err)
+	}
+	var baseline azlist.ListResult
+	if err := json.Unmarshal(b, &baseline); err != nil {
+		return nil, fmt.Errorf("parsing --baseline: %v", err)
+	}
+	return azlist.DiffResources(baseline.Resources, result.Resources, withBody, fields), nil
+}
@@ -0,0 +1,179 @@
+//go:build e2e
+
+// Package e2e runs azlist against the resources provisioned by main.bicep in a disposable resource group of a real subscription.
+// It is opt-in via "make e2e", with the following environment variables:
+//   - AZLIST_E2E_SUBSCRIPTION_ID (required): The subscription to provision the resources in.
+//   - AZLIST_E2E_LOCATION: The location of the resources. Defaults to "westus2".
+//   - AZLIST_E2E_PRINCIPAL_ID: The principal of the role assignment. Defaults to the signed in user of the Azure CLI.
+//   - AZLIST_E2E_KEEP: Keep the resource group after the tests, if set.
+//
+// The Azure CLI (with bicep) is required, and is expected to be logged in.
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/google/uuid"
+	"github.com/magodo/azlist/azlist"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	subscriptionId string
+	resourceGroup  string
+)
+
+func TestMain(m *testing.M) {
+	subscriptionId = os.Getenv("AZLIST_E2E_SUBSCRIPTION_ID")
+	if subscriptionId == "" {
+		fmt.Fprintln(os.Stderr, "AZLIST_E2E_SUBSCRIPTION_ID is not set, skipping the e2e tests")
+		os.Exit(0)
+	}
+	resourceGroup = "azlist-e2e-" + uuid.NewString()[:8]
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	if err := provision(); err != nil {
+		fmt.Fprintf(os.Stderr, "provisioning: %v\n", err)
+		deprovision()
+		return 1
+	}
+	defer deprovision()
+	return m.Run()
+}
+
+func az(args ...string) (string, error) {
+	args = append(args, "--subscription", subscriptionId, "--only-show-errors")
+	out, err := exec.Command("az", args...).Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("az %s: %v: %s", strings.Join(args, " "), err, ee.Stderr)
+		}
+		return "", fmt.Errorf("az %s: %v", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func provision() error {
+	location := os.Getenv("AZLIST_E2E_LOCATION")
+	if location == "" {
+		location = "westus2"
+	}
+	principalId := os.Getenv("AZLIST_E2E_PRINCIPAL_ID")
+	if principalId == "" {
+		out, err := exec.Command("az", "ad", "signed-in-user", "show", "--query", "id", "-o", "tsv").Output()
+		if err != nil {
+			return fmt.Errorf("getting the signed in user: %v", err)
+		}
+		principalId = strings.TrimSpace(string(out))
+	}
+	if _, err := az("group", "create", "-n", resourceGroup, "-l", location); err != nil {
+		return err
+	}
+	if _, err := az("deployment", "group", "create", "-g", resourceGroup, "-f", "main.bicep", "-p", "principalId="+principalId); err != nil {
+		return err
+	}
+	return nil
+}
+
+func deprovision() {
+	if _, ok := os.LookupEnv("AZLIST_E2E_KEEP"); ok {
+		fmt.Fprintf(os.Stderr, "keeping the resource group %s\n", resourceGroup)
+		return
+	}
+	if _, err := az("group", "delete", "-n", resourceGroup, "--yes", "--no-wait"); err != nil {
+		fmt.Fprintf(os.Stderr, "deleting the resource group %s: %v\n", resourceGroup, err)
+	}
+}
+
+func newLister(t *testing.T, opt azlist.Option) *azlist.Lister {
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	require.NoError(t, err)
+	opt.SubscriptionId = subscriptionId
+	opt.Cred = cred
+	opt.ClientOpt = arm.ClientOptions{}
+	l, err := azlist.NewLister(opt)
+	require.NoError(t, err)
+	return l
+}
+
+// list lists the resources of the resource group, and waits for the ARG to index the tracked resources, which takes a while after the provisioning.
+func list(t *testing.T, opt azlist.Option) []string {
+	l := newLister(t, opt)
+	predicate := fmt.Sprintf("resourceGroup =~ %q", resourceGroup)
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+	defer cancel()
+	for {
+		result, err := l.List(ctx, predicate)
+		require.NoError(t, err)
+		var ids []string
+		for _, res := range result.Resources {
+			ids = append(ids, strings.ToLower(res.Id.String()))
+		}
+		if len(ids) >= 3 {
+			return ids
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("timeout waiting for the ARG to index the resources, got %v", ids)
+		case <-time.After(30 * time.Second):
+		}
+	}
+}
+
+func resourceId(rest string) string {
+	return strings.ToLower(fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/%s", subscriptionId, resourceGroup, rest))
+}
+
+// hasId tells whether any of the ids contains the substring, for the resources whose names are generated.
+func hasId(ids []string, substr string) bool {
+	for _, id := range ids {
+		if strings.Contains(id, strings.ToLower(substr)) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestList(t *testing.T) {
+	ids := list(t, azlist.Option{})
+	require.Contains(t, ids, resourceId("Microsoft.Network/virtualNetworks/azlist-e2e-vnet"))
+	require.Contains(t, ids, resourceId("Microsoft.Network/networkSecurityGroups/azlist-e2e-nsg"))
+	require.True(t, hasId(ids, "/providers/Microsoft.Storage/storageAccounts/azliste2e"))
+	require.NotContains(t, ids, resourceId("Microsoft.Network/virtualNetworks/azlist-e2e-vnet/subnets/subnet1"))
+}
+
+func TestListRecursive(t *testing.T) {
+	ids := list(t, azlist.Option{Recursive: true})
+	require.Contains(t, ids, resourceId("Microsoft.Network/virtualNetworks/azlist-e2e-vnet/subnets/subnet1"))
+	require.Contains(t, ids, resourceId("Microsoft.Network/virtualNetworks/azlist-e2e-vnet/subnets/subnet2"))
+	require.Contains(t, ids, resourceId("Microsoft.Network/networkSecurityGroups/azlist-e2e-nsg/securityRules/allow-ssh"))
+	require.True(t, hasId(ids, "/blobServices/default"))
+}
+
+func TestListExtension(t *testing.T) {
+	ids := list(t, azlist.Option{
+		ExtensionResourceTypes: []azlist.ExtensionResource{{Type: "Microsoft.Authorization/roleAssignments"}},
+	})
+	var found bool
+	for _, id := range ids {
+		if strings.Contains(id, "/providers/microsoft.storage/storageaccounts/") && strings.Contains(id, "/providers/microsoft.authorization/roleassignments/") {
+			found = true
+		}
+	}
+	require.True(t, found, "no role assignment found on the storage account: %v", ids)
+}
+
+func TestListIncludeResourceGroup(t *testing.T) {
+	ids := list(t, azlist.Option{IncludeResourceGroup: true})
+	require.Contains(t, ids, strings.ToLower(fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", subscriptionId, resourceGroup)))
+}
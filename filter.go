@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/magodo/azlist/azlist"
+)
+
+// filterTypes keeps the resources whose types match any of the includes (all if no include), and don't match any of the excludes.
+// The patterns are case insensitive globs (e.g. "Microsoft.Insights/*"), or exact resource types.
+// A pattern matching a resource type also matches its child resource types.
+func filterTypes(rl []azlist.AzureResource, includes, excludes []string) ([]azlist.AzureResource, error) {
+	match := func(patterns []string, rt string) (bool, error) {
+		segs := strings.Split(rt, "/")
+		for _, pattern := range patterns {
+			for i := 2; i <= len(segs); i++ {
+				ok, err := path.Match(strings.ToLower(pattern), strings.Join(segs[:i], "/"))
+				if err != nil {
+					return false, fmt.Errorf("invalid type pattern %q: %v", pattern, err)
+				}
+				if ok {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	}
+
+	var out []azlist.AzureResource
+	for _, res := range rl {
+		rt := strings.ToLower(azlist.ResourceType(res.Id))
+		if len(includes) != 0 {
+			ok, err := match(includes, rt)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		ok, err := match(excludes, rt)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			continue
+		}
+		out = append(out, res)
+	}
+	return out, nil
+}
+
+// kqlQuote quotes the string as a KQL string literal, so that the user input can't break out of it.
+func kqlQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// tagPredicates returns the ARG where predicates matching the tags, whose keys are compared case sensitively, and values case insensitively.
+func tagPredicates(tags map[string]string) []string {
+	var out []string
+	for k, v := range tags {
+		out = append(out, fmt.Sprintf("tags[%s] =~ %s", kqlQuote(k), kqlQuote(v)))
+	}
+	sort.Strings(out)
+	return out
+}
+
+// filterTags keeps the resources having all the tags, whose keys are compared case sensitively, and values case insensitively, the same as the tagPredicates.
+// This applies to the child and extension resources as well, which are not constrained by the ARG query.
+// Resources without tags (e.g. most child and extension resources) take the tags of their nearest listed ancestor, and are dropped if there is none.
+func filterTags(rl []azlist.AzureResource, tags map[string]string) []azlist.AzureResource {
+	sources := valueSources(rl, func(res azlist.AzureResource) bool {
+		_, ok := res.Properties["tags"].(map[string]interface{})
+		return ok
+	})
+
+	var out []azlist.AzureResource
+	for _, res := range rl {
+		src, ok := sources[strings.ToUpper(res.Id.String())]
+		if !ok {
+			continue
+		}
+		resTags := src.Properties["tags"].(map[string]interface{})
+		matched := true
+		for k, v := range tags {
+			if rv, ok := resTags[k]; !ok || !strings.EqualFold(fmt.Sprint(rv), v) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			out = append(out, res)
+		}
+	}
+	return out
+}
+
+// locationPredicate returns the ARG where predicate matching any of the locations.
+func locationPredicate(locations []string) string {
+	var quoted []string
+	for _, loc := range locations {
+		quoted = append(quoted, kqlQuote(normalizeLocation(loc)))
+	}
+	return fmt.Sprintf("location in~ (%s)", strings.Join(quoted, ", "))
+}
+
+// filterLocations keeps the resources located in any of the locations.
+// Resources without a location (e.g. most child and extension resources) take the location of their nearest listed ancestor, and are dropped if there is none.
+func filterLocations(rl []azlist.AzureResource, locations []string) []azlist.AzureResource {
+	wanted := map[string]bool{}
+	for _, loc := range locations {
+		wanted[normalizeLocation(loc)] = true
+	}
+	sources := valueSources(rl, func(res azlist.AzureResource) bool {
+		loc, ok := res.Properties["location"].(string)
+		return ok && loc != ""
+	})
+
+	var out []azlist.AzureResource
+	for _, res := range rl {
+		src, ok := sources[strings.ToUpper(res.Id.String())]
+		if !ok {
+			continue
+		}
+		if wanted[normalizeLocation(src.Properties["location"].(string))] {
+			out = append(out, res)
+		}
+	}
+	return out
+}
+
+// valueSources maps the upper cased ids of the resources to the resources that their values (e.g. the location) are taken from, which is the resource itself if it has the value,
+// otherwise its nearest listed ancestor (i.e. the parent resource, or the parent scope of the extension resources) that has. Resources without such a source are absent.
+func valueSources(rl []azlist.AzureResource, has func(res azlist.AzureResource) bool) map[string]azlist.AzureResource {
+	withValue := map[string]azlist.AzureResource{}
+	for _, res := range rl {
+		if has(res) {
+			withValue[strings.ToUpper(res.Id.String())] = res
+		}
+	}
+	out := map[string]azlist.AzureResource{}
+	for _, res := range rl {
+		for id := res.Id; id != nil; {
+			if src, ok := withValue[strings.ToUpper(id.String())]; ok {
+				out[strings.ToUpper(res.Id.String())] = src
+				break
+			}
+			if parent := id.Parent(); parent != nil {
+				id = parent
+			} else {
+				id = id.ParentScope()
+			}
+		}
+	}
+	return out
+}
+
+// normalizeLocation turns the location display name (e.g. "West Europe") into the location name (e.g. "westeurope").
+func normalizeLocation(loc string) string {
+	return strings.ToLower(strings.ReplaceAll(loc, " ", ""))
+}
+
+// filterIds keeps the resources whose ids match the match regexp (if any), and don't match the exclude regexp (if any).
+func filterIds(rl []azlist.AzureResource, match, exclude *regexp.Regexp) []azlist.AzureResource {
+	var out []azlist.AzureResource
+	for _, res := range rl {
+		id := res.Id.String()
+		if match != nil && !match.MatchString(id) {
+			continue
+		}
+		if exclude != nil && exclude.MatchString(id) {
+			continue
+		}
+		out = append(out, res)
+	}
+	return out
+}
+
+// parseTimeOrAgo parses either a RFC3339 time, or a duration (e.g. "72h") before now.
+func parseTimeOrAgo(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither a RFC3339 time nor a duration", s)
+	}
+	return t, nil
+}
+
+// filterTimes keeps the resources whose time field (i.e. "createdTime" or "changedTime") in the body is after the time.
+// Only the tracked resources carry the field (see azlist.Option.ExpandTimes), hence the child and extension resources without it take the one of their nearest listed ancestor,
+// and are dropped if there is none.
+func filterTimes(rl []azlist.AzureResource, field string, after time.Time) []azlist.AzureResource {
+	timeOf := func(res azlist.AzureResource) (time.Time, bool) {
+		v, _ := res.Properties[field].(string)
+		t, err := time.Parse(time.RFC3339, v)
+		return t, err == nil
+	}
+	sources := valueSources(rl, func(res azlist.AzureResource) bool {
+		_, ok := timeOf(res)
+		return ok
+	})
+
+	var out []azlist.AzureResource
+	for _, res := range rl {
+		src, ok := sources[strings.ToUpper(res.Id.String())]
+		if !ok {
+			continue
+		}
+		if t, _ := timeOf(src); t.After(after) {
+			out = append(out, res)
+		}
+	}
+	return out
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/magodo/azlist/azlist"
+)
+
+// gitSnapshotRoots are the top level directories of the snapshot in the git working tree, i.e. the first segments of the lower cased resource ids.
+// They are owned by azlist, and rewritten by each snapshot.
+var gitSnapshotRoots = []string{"subscriptions", "providers"}
+
+// gitSnapshotPath returns the file path of the resource in the snapshot, relative to the working tree, which is derived from the lower cased resource id
+// (as the casing of the ids is not consistent across the API responses), e.g. "subscriptions/xxx/resourcegroups/rg1/providers/microsoft.network/virtualnetworks/vnet1.json".
+// See azlist.ResourceIdPath for details.
+func gitSnapshotPath(id string) string {
+	return azlist.ResourceIdPath(id) + ".json"
+}
+
+// writeGitSnapshot writes one file per resource into the git working tree, with the bodies canonicalized by the volatile fields, and replaces the previous snapshot.
+// If commit is set, the changes are committed, where the working tree is initialized if not exists.
+func writeGitSnapshot(w io.Writer, dir string, result *azlist.ListResult, fields azlist.VolatileFields, commit bool) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating git directory: %v", err)
+	}
+	for _, root := range gitSnapshotRoots {
+		if err := os.RemoveAll(filepath.Join(dir, root)); err != nil {
+			return fmt.Errorf("removing the previous snapshot: %v", err)
+		}
+	}
+	for _, res := range result.Resources {
+		path := filepath.Join(dir, gitSnapshotPath(res.Id.String()))
+		body := azlist.CanonicalizeBody(azlist.ResourceType(res.Id), res.Properties, fields)
+		b, err := json.MarshalIndent(body, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshalling %s: %v", res.Id.String(), err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, append(b, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(w, "Snapshot of %d resources written to %s\n", len(result.Resources), dir)
+	if !commit {
+		return nil
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		if _, err := runGit(dir, "init"); err != nil {
+			return err
+		}
+	}
+	// Only stage the roots that exist, or are removed by this snapshot, as git rejects the pathspecs matching nothing.
+	args := []string{"add", "-A", "--"}
+	for _, root := range gitSnapshotRoots {
+		tracked, err := runGit(dir, "ls-files", "--", root)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(filepath.Join(dir, root)); err == nil || len(bytes.TrimSpace(tracked)) != 0 {
+			args = append(args, root)
+		}
+	}
+	if len(args) > 3 {
+		if _, err := runGit(dir, args...); err != nil {
+			return err
+		}
+	}
+	staged, err := runGit(dir, "diff", "--cached", "--name-only")
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(staged)) == 0 {
+		fmt.Fprintf(w, "Snapshot unchanged, nothing to commit\n")
+		return nil
+	}
+	msg := fmt.Sprintf("azlist snapshot at %s\n\n%d resources, %d errors", time.Now().UTC().Format(time.RFC3339), len(result.Resources), len(result.Errors))
+	if _, err := runGit(dir, "commit", "-q", "-m", msg); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Snapshot committed\n")
+	return nil
+}
+
+func runGit(dir string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
@@ -7,11 +7,15 @@ require (
 	github.com/Azure/azure-sdk-for-go/sdk/azidentity v1.3.0
 	github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resourcegraph/armresourcegraph v0.6.0
 	github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources v1.1.1
+	github.com/google/uuid v1.3.0
 	github.com/hashicorp/go-hclog v1.3.1
+	github.com/jmespath/go-jmespath v0.4.0
 	github.com/magodo/armid v0.0.0-20220915030809-9ed860f93894
 	github.com/magodo/workerpool v0.0.0-20211124060943-1c48f3e5a514
 	github.com/stretchr/testify v1.7.5
 	github.com/urfave/cli/v2 v2.16.3
+	golang.org/x/term v0.6.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -21,7 +25,6 @@ require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/fatih/color v1.13.0 // indirect
 	github.com/golang-jwt/jwt/v4 v4.5.0 // indirect
-	github.com/google/uuid v1.3.0 // indirect
 	github.com/hashicorp/errwrap v1.0.0 // indirect
 	github.com/hashicorp/go-multierror v1.1.1 // indirect
 	github.com/kylelemons/godebug v1.1.0 // indirect
@@ -35,5 +38,4 @@ require (
 	golang.org/x/net v0.8.0 // indirect
 	golang.org/x/sys v0.6.0 // indirect
 	golang.org/x/text v0.8.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
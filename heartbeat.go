@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/magodo/azlist/azlist"
+	"github.com/magodo/azlist/output"
+)
+
+// heartbeat prints a one-line status to the file every interval (see --heartbeat), for the whole listing,
+// including the parts out of the List calls (e.g. resolving the subscriptions of a management group, and the local filtering).
+type heartbeat struct {
+	f    *os.File
+	term output.Terminal
+
+	mu     sync.Mutex
+	status string
+
+	stopc chan struct{}
+	done  chan struct{}
+}
+
+func startHeartbeat(f *os.File, interval time.Duration) *heartbeat {
+	h := &heartbeat{
+		f:     f,
+		term:  output.DetectTerminal(f),
+		stopc: make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go func() {
+		defer close(h.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-h.stopc:
+				return
+			case <-ticker.C:
+				h.mu.Lock()
+				status := h.status
+				h.mu.Unlock()
+				if status != "" {
+					output.StatusLine(h.f, h.term, status)
+				}
+			}
+		}
+	}()
+	return h
+}
+
+// setPhase sets the status to the phase out of the List calls.
+func (h *heartbeat) setPhase(phase string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status = "[azlist] " + phase
+}
+
+// onProgress sets the status to the progress of a List call, as the azlist.Option.OnProgress.
+func (h *heartbeat) onProgress(p azlist.Progress) {
+	h.setPhase(fmt.Sprintf("%s: %d discovered, %d errors", p.Phase, p.Discovered, p.Errors))
+}
+
+// stop stops the heartbeat, and clears the status line.
+func (h *heartbeat) stop() {
+	if h == nil {
+		return
+	}
+	close(h.stopc)
+	<-h.done
+	output.ClearStatusLine(h.f, h.term)
+}
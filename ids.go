@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// readIdsFile reads the resource ids from the file, or stdin if it is "-".
+func readIdsFile(path string) ([]string, error) {
+	var (
+		b   []byte
+		err error
+	)
+	if path == "-" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading --ids-file: %v", err)
+	}
+	ids, err := parseIds(b)
+	if err != nil {
+		return nil, fmt.Errorf("parsing --ids-file: %v", err)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no resource id found in --ids-file")
+	}
+	return ids, nil
+}
+
+// parseIds parses the resource ids, which are either one per line (the empty lines and the ones starting with "#" are skipped),
+// or the JSON output of azlist, i.e. an object whose "resources" are objects with the "id", or an array of such objects.
+func parseIds(b []byte) ([]string, error) {
+	type item struct {
+		Id string `json:"id"`
+	}
+	var items []item
+	switch trimmed := bytes.TrimSpace(b); {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		var result struct {
+			Resources []item `json:"resources"`
+		}
+		if err := json.Unmarshal(trimmed, &result); err != nil {
+			return nil, err
+		}
+		items = result.Resources
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		if err := json.Unmarshal(trimmed, &items); err != nil {
+			return nil, err
+		}
+	default:
+		var ids []string
+		scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			ids = append(ids, line)
+		}
+		return ids, scanner.Err()
+	}
+	var ids []string
+	for _, item := range items {
+		if item.Id != "" {
+			ids = append(ids, item.Id)
+		}
+	}
+	return ids, nil
+}
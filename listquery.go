@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/magodo/azlist/armresources"
+)
+
+// parseListQueryParameters parses the --list-query-param, each in form of "<resource type>:<key>=<value>".
+// As the flag values are split by commas, a value without "=" is appended to the previous one, so that e.g. "$expand=a,b" is kept as is.
+func parseListQueryParameters(values []string) (map[string]url.Values, error) {
+	out := map[string]url.Values{}
+	var (
+		lastRT  string
+		lastKey string
+	)
+	for _, v := range values {
+		rt, kv, ok := strings.Cut(v, ":")
+		if !ok || !strings.Contains(kv, "=") {
+			if lastKey == "" {
+				return nil, fmt.Errorf(`malformed --list-query-param %q, expect "<resource type>:<key>=<value>"`, v)
+			}
+			q := out[lastRT]
+			q[lastKey][len(q[lastKey])-1] += "," + v
+			continue
+		}
+		key, value, _ := strings.Cut(kv, "=")
+		if rt == "" || key == "" {
+			return nil, fmt.Errorf(`malformed --list-query-param %q, expect "<resource type>:<key>=<value>"`, v)
+		}
+		rt = strings.ToUpper(rt)
+		if out[rt] == nil {
+			out[rt] = url.Values{}
+		}
+		out[rt].Add(key, value)
+		lastRT, lastKey = rt, key
+	}
+	return out, nil
+}
+
+// parseListURLTemplates parses the --list-url-template, each in form of "<resource type>=<URL path template>".
+func parseListURLTemplates(values []string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, v := range values {
+		rt, tmpl, ok := strings.Cut(v, "=")
+		if !ok || rt == "" || tmpl == "" {
+			return nil, fmt.Errorf(`malformed --list-url-template %q, expect "<resource type>=<URL path template>"`, v)
+		}
+		if err := armresources.ValidateListURLTemplate(tmpl); err != nil {
+			return nil, fmt.Errorf("--list-url-template %q: %v", v, err)
+		}
+		out[strings.ToUpper(rt)] = tmpl
+	}
+	return out, nil
+}
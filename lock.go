@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/magodo/azlist/azlist"
+)
+
+// lockApply creates (or updates) a management lock on each listed resource, by the lister of its subscription.
+func lockApply(ctx context.Context, w io.Writer, ls listers, result *azlist.ListResult, name, level, notes string, dryRun bool) error {
+	return bulkRun(w, "lock", ls[0].Parallelism, result.Resources, func(res azlist.AzureResource) (string, error) {
+		if dryRun {
+			return fmt.Sprintf("Would lock %s (%s)", res.Id.String(), level), nil
+		}
+		l, err := ls.listerOf(res)
+		if err != nil {
+			return "", err
+		}
+		if err := l.Client.CreateOrUpdateLock(ctx, res.Id, name, level, notes); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Locked %s (%s)", res.Id.String(), level), nil
+	})
+}
+
+// lockRemove removes the management lock from each listed resource, by the lister of its subscription.
+func lockRemove(ctx context.Context, w io.Writer, ls listers, result *azlist.ListResult, name string, dryRun bool) error {
+	return bulkRun(w, "unlock", ls[0].Parallelism, result.Resources, func(res azlist.AzureResource) (string, error) {
+		if dryRun {
+			return fmt.Sprintf("Would unlock %s", res.Id.String()), nil
+		}
+		l, err := ls.listerOf(res)
+		if err != nil {
+			return "", err
+		}
+		if err := l.Client.DeleteLock(ctx, res.Id, name); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Unlocked %s", res.Id.String()), nil
+	})
+}
@@ -3,16 +3,24 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"regexp"
+	"sort"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resourcegraph/armresourcegraph"
+	"github.com/jmespath/go-jmespath"
+	"github.com/magodo/armid"
+	"github.com/magodo/azlist/analyze"
 	"github.com/magodo/azlist/azlist"
+	"github.com/magodo/azlist/output"
 
 	"github.com/urfave/cli/v2"
 )
@@ -20,24 +28,472 @@ import (
 func main() {
 	var (
 		flagEnvironment                 string
-		flagSubscriptionId              string
+		flagManagedIdentity             bool
+		flagMIClientId                  string
+		flagSubscriptionIds             cli.StringSlice
 		flagRecursive                   bool
+		flagMaxDepth                    int
+		flagRecurseOnlyTypes            cli.StringSlice
+		flagSkipChildTypes              cli.StringSlice
+		flagNoDefaultSkips              bool
+		flagSkipManagedRG               bool
+		flagListQueryParameters         cli.StringSlice
+		flagListURLTemplates            cli.StringSlice
+		flagExpandMetadata              cli.StringSlice
 		flagWithBody                    bool
+		flagBodyDigest                  bool
+		flagStripNoise                  bool
+		flagBaseline                    string
+		flagShowBodyDiff                bool
+		flagDiffFormat                  string
+		flagGitDir                      string
+		flagGitCommit                   bool
+		flagVolatileFields              cli.StringSlice
 		flagIncludeManaged              bool
 		flagIncludeResourceGroup        bool
+		flagIncludeAncestors            bool
+		flagIncludeTenantResources      bool
 		flagParallelism                 int
+		flagTop                         int
 		flagExtensions                  cli.StringSlice
+		flagAPIVersions                 cli.StringSlice
+		flagAPIVersionStrategy          string
+		flagLiveSchema                  bool
+		flagSchemaFile                  string
+		flagSchemaOverlay               string
 		flagARGTable                    string
 		flagARGAuthorizationScopeFilter string
+		flagARGAllowPartialScopes       bool
+		flagARGPageSize                 int
+		flagARGSkip                     int
+		flagAllSubscriptions            bool
+		flagManagementGroup             string
+		flagSubscriptionFilters         cli.StringSlice
+		flagExcludeSubscriptions        cli.StringSlice
+		flagPreset                      string
+		flagResourceGroup               string
+		flagVars                        cli.StringSlice
+		flagIdsFile                     string
+		flagIncludeTypes                cli.StringSlice
+		flagTags                        cli.StringSlice
+		flagLocations                   cli.StringSlice
+		flagMatchId                     string
+		flagCreatedAfter                string
+		flagChangedAfter                string
+		flagExcludeId                   string
+		flagExcludeTypes                cli.StringSlice
+		flagExpiringWithin              int
 		flagPrintError                  bool
+		flagRecordNotFound              bool
+		flagEstimateUsage               bool
+		flagRunInterval                 time.Duration
+		flagProgressInterval            time.Duration
+		flagHeartbeat                   time.Duration
+		flagOutput                      string
+		flagColumns                     cli.StringSlice
+		flagNaturalSort                 bool
+		flagOutputDir                   string
+		flagFormatTemplate              string
+		flagQuery                       string
+		flagSelect                      cli.StringSlice
 		flagLogLevel                    string
+		flagArtifactsDir                string
+
+		// runArtifacts is the working directory of this run, if --artifacts-dir is specified.
+		runArtifacts *artifacts
 	)
 
-	app := &cli.App{
+	// newListOption builds the list option from the flags, except the subscription id.
+	newListOption := func() (*azlist.Option, error) {
+		var logger *slog.Logger
+		if flagLogLevel != "" {
+			var level slog.Level
+			switch strings.ToLower(flagLogLevel) {
+			case "error":
+				level = slog.LevelError
+			case "warn":
+				level = slog.LevelWarn
+			case "info":
+				level = slog.LevelInfo
+			case "debug":
+				level = slog.LevelDebug
+			default:
+				return nil, fmt.Errorf("unknown log level specified: %q", flagLogLevel)
+			}
+			var w io.Writer = os.Stderr
+			if runArtifacts != nil {
+				w = io.MultiWriter(os.Stderr, runArtifacts.log)
+			}
+			logger = slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level}))
+		} else if runArtifacts != nil {
+			logger = slog.New(slog.NewTextHandler(runArtifacts.log, &slog.HandlerOptions{Level: slog.LevelInfo}))
+		}
+
+		cloudCfg := cloud.AzurePublic
+		switch strings.ToLower(flagEnvironment) {
+		case "public":
+			cloudCfg = cloud.AzurePublic
+		case "usgovernment":
+			cloudCfg = cloud.AzureGovernment
+		case "china":
+			cloudCfg = cloud.AzureChina
+		default:
+			return nil, fmt.Errorf("unknown environment specified: %q", flagEnvironment)
+		}
+
+		if v, ok := os.LookupEnv("ARM_TENANT_ID"); ok {
+			os.Setenv("AZURE_TENANT_ID", v)
+		}
+		if v, ok := os.LookupEnv("ARM_CLIENT_ID"); ok {
+			os.Setenv("AZURE_CLIENT_ID", v)
+		}
+		if v, ok := os.LookupEnv("ARM_CLIENT_SECRET"); ok {
+			os.Setenv("AZURE_CLIENT_SECRET", v)
+		}
+		if v, ok := os.LookupEnv("ARM_CLIENT_CERTIFICATE_PATH"); ok {
+			os.Setenv("AZURE_CLIENT_CERTIFICATE_PATH", v)
+		}
+
+		clientOpt := arm.ClientOptions{
+			ClientOptions: policy.ClientOptions{
+				Cloud: cloudCfg,
+				Telemetry: policy.TelemetryOptions{
+					ApplicationID: "azlist",
+					Disabled:      false,
+				},
+				Logging: policy.LogOptions{
+					IncludeBody: true,
+				},
+			},
+		}
+
+		cred, err := newCredential(clientOpt.ClientOptions, os.Getenv("ARM_TENANT_ID"), flagManagedIdentity, flagMIClientId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to obtain a credential: %v", err)
+		}
+
+		// The audit log only records the ARM requests, not the token requests of the credential.
+		if runArtifacts != nil {
+			clientOpt.PerRetryPolicies = append(clientOpt.PerRetryPolicies, auditPolicy{a: runArtifacts})
+		}
+
+		var extensions []azlist.ExtensionResource
+		for _, rt := range flagExtensions.Value() {
+			extensions = append(extensions, azlist.ExtensionResource{Type: rt})
+		}
+
+		apiVersions, err := parseKeyValues(flagAPIVersions.Value())
+		if err != nil {
+			return nil, fmt.Errorf("parsing --api-version: %v", err)
+		}
+
+		listQueryParameters, err := parseListQueryParameters(flagListQueryParameters.Value())
+		if err != nil {
+			return nil, err
+		}
+
+		listURLTemplates, err := parseListURLTemplates(flagListURLTemplates.Value())
+		if err != nil {
+			return nil, err
+		}
+
+		var armSchema []byte
+		if flagSchemaFile != "" {
+			if armSchema, err = os.ReadFile(flagSchemaFile); err != nil {
+				return nil, fmt.Errorf("reading --schema-file: %v", err)
+			}
+		}
+
+		var armSchemaOverlay []byte
+		if flagSchemaOverlay != "" {
+			if armSchemaOverlay, err = os.ReadFile(flagSchemaOverlay); err != nil {
+				return nil, fmt.Errorf("reading --schema-overlay: %v", err)
+			}
+		}
+
+		opt := azlist.Option{
+			Cred:      cred,
+			ClientOpt: clientOpt,
+
+			Logger:                      logger,
+			Parallelism:                 flagParallelism,
+			Top:                         flagTop,
+			ProgressInterval:            flagProgressInterval,
+			ExpandTimes:                 flagCreatedAfter != "" || flagChangedAfter != "",
+			Recursive:                   flagRecursive,
+			MaxDepth:                    flagMaxDepth,
+			RecurseOnlyTypes:            flagRecurseOnlyTypes.Value(),
+			SkipChildTypes:              flagSkipChildTypes.Value(),
+			NoDefaultSkips:              flagNoDefaultSkips,
+			SkipManagedResourceGroups:   flagSkipManagedRG,
+			ListQueryParameters:         listQueryParameters,
+			ListURLTemplates:            listURLTemplates,
+			ExpandMetadataTypes:         flagExpandMetadata.Value(),
+			RecordNotFound:              flagRecordNotFound,
+			IncludeManaged:              flagIncludeManaged,
+			IncludeResourceGroup:        flagIncludeResourceGroup,
+			IncludeAncestors:            flagIncludeAncestors,
+			IncludeTenantResources:      flagIncludeTenantResources,
+			ExtensionResourceTypes:      extensions,
+			APIVersions:                 apiVersions,
+			VersionStrategy:             azlist.VersionStrategy(flagAPIVersionStrategy),
+			LiveSchema:                  flagLiveSchema,
+			ARMSchema:                   armSchema,
+			ARMSchemaOverlay:            armSchemaOverlay,
+			ARMSchemaCacheFile:          schemaCacheFile(),
+			ARGTable:                    flagARGTable,
+			ARGAuthorizationScopeFilter: armresourcegraph.AuthorizationScopeFilter(flagARGAuthorizationScopeFilter),
+			ARGAllowPartialScopes:       flagARGAllowPartialScopes,
+			ARGPageSize:                 int32(flagARGPageSize),
+			ARGSkip:                     int32(flagARGSkip),
+		}
+
+		if flagPreset != "" {
+			p := presets[flagPreset]
+			if opt.ARGTable == "" {
+				opt.ARGTable = p.table
+			}
+			if opt.ARGAuthorizationScopeFilter == "" {
+				opt.ARGAuthorizationScopeFilter = armresourcegraph.AuthorizationScopeFilter(p.authorizationScopeFilter)
+			}
+			opt.Recursive = opt.Recursive || p.recursive
+		}
+
+		if flagHeartbeat > 0 {
+			opt.ProgressInterval = flagHeartbeat
+		}
+
+		return &opt, nil
+	}
+
+	// listResources lists the resources by the only ARG where predicate in the arguments, in each of the subscriptions.
+	listResources := func(ctx *cli.Context) (listers, *azlist.ListResult, error) {
+		if ctx.NArg() > 1 {
+			return nil, nil, fmt.Errorf("More than one where predicates specified")
+		}
+		var predicates []string
+		if flagPreset != "" {
+			p, ok := presets[flagPreset]
+			if !ok {
+				return nil, nil, fmt.Errorf("unknown preset specified: %q", flagPreset)
+			}
+			predicates = append(predicates, p.predicate)
+		}
+		if flagResourceGroup != "" {
+			predicates = append(predicates, "resourceGroup =~ "+kqlQuote(flagResourceGroup))
+		}
+		var matchId, excludeId *regexp.Regexp
+		if flagMatchId != "" {
+			var err error
+			if matchId, err = regexp.Compile(flagMatchId); err != nil {
+				return nil, nil, fmt.Errorf("compiling --match-id: %v", err)
+			}
+		}
+		if flagExcludeId != "" {
+			var err error
+			if excludeId, err = regexp.Compile(flagExcludeId); err != nil {
+				return nil, nil, fmt.Errorf("compiling --exclude-id: %v", err)
+			}
+		}
+		var createdAfter, changedAfter time.Time
+		if flagCreatedAfter != "" {
+			var err error
+			if createdAfter, err = parseTimeOrAgo(flagCreatedAfter); err != nil {
+				return nil, nil, fmt.Errorf("parsing --created-after: %v", err)
+			}
+		}
+		if flagChangedAfter != "" {
+			var err error
+			if changedAfter, err = parseTimeOrAgo(flagChangedAfter); err != nil {
+				return nil, nil, fmt.Errorf("parsing --changed-after: %v", err)
+			}
+		}
+		tags, err := parseKeyValues(flagTags.Value())
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing --tag: %v", err)
+		}
+		predicates = append(predicates, tagPredicates(tags)...)
+		if len(flagLocations.Value()) != 0 {
+			predicates = append(predicates, locationPredicate(flagLocations.Value()))
+		}
+		if ctx.NArg() == 1 {
+			predicate, err := readPredicate(ctx.Args().First())
+			if err != nil {
+				return nil, nil, err
+			}
+			vars, err := parseVars(flagVars.Value())
+			if err != nil {
+				return nil, nil, err
+			}
+			if predicate, err = expandPredicate(predicate, vars); err != nil {
+				return nil, nil, err
+			}
+			predicates = append(predicates, predicate)
+		}
+		var (
+			predicate string
+			ids       []string
+		)
+		if flagIdsFile != "" {
+			// The predicates derived from the other flags (e.g. --tag) are applied as filters on the result below.
+			// The preset and resource group predicates are ARG only, which have no local filter counterpart.
+			if ctx.NArg() == 1 || flagPreset != "" || flagResourceGroup != "" {
+				return nil, nil, fmt.Errorf("--ids-file can't be used with a where predicate, --preset or --resource-group")
+			}
+			var err error
+			if ids, err = readIdsFile(flagIdsFile); err != nil {
+				return nil, nil, err
+			}
+		} else {
+			if len(predicates) == 0 {
+				return nil, nil, fmt.Errorf("No ARG where predicate specified")
+			}
+			predicate = predicates[0]
+			if len(predicates) > 1 {
+				predicate = "(" + strings.Join(predicates, ") and (") + ")"
+			}
+		}
+		if err := validateTypePatterns(append(flagIncludeTypes.Value(), flagExcludeTypes.Value()...)); err != nil {
+			return nil, nil, err
+		}
+		if err := validateTypePatterns(append(flagRecurseOnlyTypes.Value(), flagSkipChildTypes.Value()...)); err != nil {
+			return nil, nil, err
+		}
+		opt, err := newListOption()
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := validateOption(os.Stderr, opt); err != nil {
+			return nil, nil, err
+		}
+		var hb *heartbeat
+		if flagHeartbeat > 0 {
+			hb = startHeartbeat(os.Stderr, flagHeartbeat)
+			defer hb.stop()
+			opt.OnProgress = hb.onProgress
+		}
+		// The local filters below might drop any of the listed resources, in which case the listing is not stopped by --top, but truncated after the filtering.
+		if len(tags) != 0 || len(flagLocations.Value()) != 0 || flagCreatedAfter != "" || flagChangedAfter != "" || matchId != nil || excludeId != nil ||
+			len(flagIncludeTypes.Value()) != 0 || len(flagExcludeTypes.Value()) != 0 || flagExpiringWithin > 0 {
+			opt.Top = 0
+		}
+		subscriptionFilter, err := parseSubscriptionFilter(flagSubscriptionFilters.Value(), flagExcludeSubscriptions.Value())
+		if err != nil {
+			return nil, nil, err
+		}
+		if !subscriptionFilter.IsEmpty() && !flagAllSubscriptions && flagManagementGroup == "" {
+			return nil, nil, fmt.Errorf("--subscription-filter and --exclude-subscription can only be used with --all-subscriptions or --management-group")
+		}
+		subscriptionIds := flagSubscriptionIds.Value()
+		if flagIdsFile != "" {
+			if flagManagementGroup != "" || flagAllSubscriptions || len(subscriptionIds) > 1 {
+				return nil, nil, fmt.Errorf("--ids-file can only be used with at most one --subscription-id")
+			}
+			if len(subscriptionIds) == 0 {
+				// A single tenant wide lister reads the resources of any subscription.
+				opt.AllSubscriptions = true
+				subscriptionIds = []string{""}
+			}
+		} else if flagManagementGroup != "" {
+			if flagAllSubscriptions || len(subscriptionIds) != 0 {
+				return nil, nil, fmt.Errorf("--management-group can't be used with --subscription-id or --all-subscriptions")
+			}
+			hb.setPhase("resolving the subscriptions of the management group")
+			node, err := managementGroupTree(ctx.Context, *opt, flagManagementGroup)
+			if err != nil {
+				return nil, nil, err
+			}
+			if subscriptionIds = node.Subscriptions(); len(subscriptionIds) == 0 {
+				return nil, nil, fmt.Errorf("no subscription found under management group %s", flagManagementGroup)
+			}
+			if !subscriptionFilter.IsEmpty() {
+				if subscriptionIds, err = filterSubscriptions(ctx.Context, *opt, subscriptionFilter, subscriptionIds); err != nil {
+					return nil, nil, err
+				}
+				if len(subscriptionIds) == 0 {
+					return nil, nil, fmt.Errorf("no subscription under management group %s is selected by the subscription filter", flagManagementGroup)
+				}
+			}
+		} else if flagAllSubscriptions && !subscriptionFilter.IsEmpty() {
+			// Listing in each of the selected subscriptions, instead of a single tenant wide lister.
+			hb.setPhase("resolving the subscriptions")
+			if subscriptionIds, err = filterSubscriptions(ctx.Context, *opt, subscriptionFilter, nil); err != nil {
+				return nil, nil, err
+			}
+			if len(subscriptionIds) == 0 {
+				return nil, nil, fmt.Errorf("no subscription is selected by the subscription filter")
+			}
+		} else if flagAllSubscriptions {
+			// A single tenant wide lister, whose subscription id is empty.
+			opt.AllSubscriptions = true
+			subscriptionIds = []string{""}
+		} else if len(subscriptionIds) == 0 {
+			return nil, nil, fmt.Errorf("either --subscription-id or --all-subscriptions is required")
+		}
+		var (
+			ls      listers
+			results []*azlist.ListResult
+		)
+		for i, subscriptionId := range subscriptionIds {
+			hb.setPhase(fmt.Sprintf("listing in subscription %d of %d", i+1, len(subscriptionIds)))
+			opt.SubscriptionId = subscriptionId
+			l, err := azlist.NewLister(*opt)
+			if err != nil {
+				return nil, nil, err
+			}
+			var result *azlist.ListResult
+			if ids != nil {
+				result, err = l.ListIds(ctx.Context, ids)
+			} else {
+				result, err = l.List(ctx.Context, predicate)
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("listing in subscription %s: %w", subscriptionId, err)
+			}
+			ls = append(ls, l)
+			results = append(results, result)
+		}
+		hb.setPhase("filtering the resources")
+		result := azlist.MergeListResults(results...)
+		if len(tags) != 0 {
+			result.Resources = filterTags(result.Resources, tags)
+		}
+		if len(flagLocations.Value()) != 0 {
+			result.Resources = filterLocations(result.Resources, flagLocations.Value())
+		}
+		if flagCreatedAfter != "" {
+			result.Resources = filterTimes(result.Resources, "createdTime", createdAfter)
+		}
+		if flagChangedAfter != "" {
+			result.Resources = filterTimes(result.Resources, "changedTime", changedAfter)
+		}
+		if matchId != nil || excludeId != nil {
+			result.Resources = filterIds(result.Resources, matchId, excludeId)
+		}
+		if len(flagIncludeTypes.Value()) != 0 || len(flagExcludeTypes.Value()) != 0 {
+			result.Resources, err = filterTypes(result.Resources, flagIncludeTypes.Value(), flagExcludeTypes.Value())
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		if flagExpiringWithin > 0 {
+			result.Resources = filterExpiring(result.Resources, time.Duration(flagExpiringWithin)*24*time.Hour)
+		}
+		if flagTop > 0 && len(result.Resources) > flagTop {
+			result.Resources = result.Resources[:flagTop]
+		}
+		if flagEstimateUsage {
+			printUsageEstimate(os.Stderr, result.Requests, flagRunInterval)
+		}
+		return ls, result, nil
+	}
+
+	var app *cli.App
+	app = &cli.App{
 		Name:      "azlist",
 		Version:   getVersion(),
 		Usage:     "List Azure resources by an Azure Resource Graph `where` predicate",
-		UsageText: "azlist [option] <ARG where predicate>",
+		UsageText: "azlist [option] [<ARG where predicate> | -]",
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:        "env",
@@ -46,13 +502,48 @@ func main() {
 				Destination: &flagEnvironment,
 				Value:       "public",
 			},
+			&cli.BoolFlag{
+				Name:        "managed-identity",
+				EnvVars:     []string{"AZLIST_MANAGED_IDENTITY"},
+				Usage:       "Authenticate by the managed identity of the hosting Azure environment (e.g. VM, Functions, Container Apps), instead of the default credential chain",
+				Destination: &flagManagedIdentity,
+			},
 			&cli.StringFlag{
+				Name:        "mi-client-id",
+				EnvVars:     []string{"AZLIST_MI_CLIENT_ID"},
+				Usage:       "The client id of the user-assigned managed identity to authenticate by. Implies --managed-identity",
+				Destination: &flagMIClientId,
+			},
+			&cli.StringSliceFlag{
 				Name:        "subscription-id",
 				EnvVars:     []string{"AZLIST_SUBSCRIPTION_ID", "ARM_SUBSCRIPTION_ID"},
 				Aliases:     []string{"s"},
-				Required:    true,
-				Usage:       "The subscription id",
-				Destination: &flagSubscriptionId,
+				Usage:       "The subscription id. Can be specified multiple times (or comma separated), in which case the results of each subscription are aggregated",
+				Destination: &flagSubscriptionIds,
+			},
+			&cli.BoolFlag{
+				Name:        "all-subscriptions",
+				EnvVars:     []string{"AZLIST_ALL_SUBSCRIPTIONS"},
+				Usage:       "List across all the subscriptions accessible in the tenant, instead of the ones specified by --subscription-id",
+				Destination: &flagAllSubscriptions,
+			},
+			&cli.StringFlag{
+				Name:        "management-group",
+				EnvVars:     []string{"AZLIST_MANAGEMENT_GROUP"},
+				Usage:       "List in every subscription under the management group (recursively), instead of the ones specified by --subscription-id",
+				Destination: &flagManagementGroup,
+			},
+			&cli.StringSliceFlag{
+				Name:        "subscription-filter",
+				EnvVars:     []string{"AZLIST_SUBSCRIPTION_FILTER"},
+				Usage:       `Only list in the subscriptions matching all the filters, with --all-subscriptions or --management-group. Each is one of "name:<regex>" (on the display name), "tag:<key>=<value>" and "offer:<quota id prefix>" (e.g. "offer:MSDN"). Can be specified multiple times, where the offer filters match any`,
+				Destination: &flagSubscriptionFilters,
+			},
+			&cli.StringSliceFlag{
+				Name:        "exclude-subscription",
+				EnvVars:     []string{"AZLIST_EXCLUDE_SUBSCRIPTION"},
+				Usage:       "Skip the subscription (by id or display name), with --all-subscriptions or --management-group. Can be specified multiple times",
+				Destination: &flagExcludeSubscriptions,
 			},
 			&cli.BoolFlag{
 				Name:        "recursive",
@@ -61,6 +552,54 @@ func main() {
 				Usage:       "Recursively list child resources of the query result",
 				Destination: &flagRecursive,
 			},
+			&cli.IntFlag{
+				Name:        "max-depth",
+				EnvVars:     []string{"AZLIST_MAX_DEPTH"},
+				Usage:       "The levels of child resources to list with --recursive, e.g. 1 only lists the direct children of the query result. 0 means no limit",
+				Destination: &flagMaxDepth,
+			},
+			&cli.StringSliceFlag{
+				Name:        "recurse-only-type",
+				EnvVars:     []string{"AZLIST_RECURSE_ONLY_TYPE"},
+				Usage:       `Only list the child resources of the types matching the glob pattern with --recursive (e.g. "Microsoft.Sql/*"), where the pattern also matches the ancestor types. Can be specified multiple times`,
+				Destination: &flagRecurseOnlyTypes,
+			},
+			&cli.StringSliceFlag{
+				Name:        "skip-child-type",
+				EnvVars:     []string{"AZLIST_SKIP_CHILD_TYPE"},
+				Usage:       `Skip listing the child resources of the types matching the glob pattern with --recursive (e.g. "Microsoft.Web/sites/snapshots"), together with their descendants. Can be specified multiple times`,
+				Destination: &flagSkipChildTypes,
+			},
+			&cli.BoolFlag{
+				Name:        "no-default-skips",
+				EnvVars:     []string{"AZLIST_NO_DEFAULT_SKIPS"},
+				Usage:       `List the child resource types whose list endpoints are known to fail (e.g. "Microsoft.KeyVault/vaults/accessPolicies"), which are skipped with --recursive by default`,
+				Destination: &flagNoDefaultSkips,
+			},
+			&cli.BoolFlag{
+				Name:        "skip-managed-rg",
+				EnvVars:     []string{"AZLIST_SKIP_MANAGED_RG"},
+				Usage:       `Skip listing the child resources with --recursive in the resource groups managed by other resources (i.e. whose "managedBy" is set), e.g. the node resource groups of AKS clusters`,
+				Destination: &flagSkipManagedRG,
+			},
+			&cli.StringSliceFlag{
+				Name:        "list-query-param",
+				EnvVars:     []string{"AZLIST_LIST_QUERY_PARAM"},
+				Usage:       `Extra query parameter of the list calls on the child or extension resource type, in form of "<resource type>:<key>=<value>" (e.g. "Microsoft.Authorization/roleAssignments:$filter=atScope()"). Can be specified multiple times`,
+				Destination: &flagListQueryParameters,
+			},
+			&cli.StringSliceFlag{
+				Name:        "list-url-template",
+				EnvVars:     []string{"AZLIST_LIST_URL_TEMPLATE"},
+				Usage:       `The URL path template of the collection of the child or extension resource type, for the ones that can't be listed at "/{resourceId}/{resourceType}", in form of "<resource type>=<template>" (e.g. "Microsoft.Web/sites/sourcecontrols=/{resourceId}/sourcecontrols/web"). The template can refer to {resourceId}, {resourceType}, {subscriptionId}, {resourceGroupName}, {name} and {location} of the parent resource. Can be specified multiple times`,
+				Destination: &flagListURLTemplates,
+			},
+			&cli.StringSliceFlag{
+				Name:        "expand-metadata",
+				EnvVars:     []string{"AZLIST_EXPAND_METADATA"},
+				Usage:       `Include the "createdTime", "changedTime" and "provisioningState" in the bodies of the child or extension resources of the type, whose list calls support the "$expand" of them (most resource providers don't). It is overridden by the "$expand" set by --list-query-param. Can be specified multiple times`,
+				Destination: &flagExpandMetadata,
+			},
 			&cli.BoolFlag{
 				Name:        "with-body",
 				EnvVars:     []string{"AZLIST_WITH_BODY"},
@@ -68,6 +607,43 @@ func main() {
 				Usage:       "Print each resource's body",
 				Destination: &flagWithBody,
 			},
+			&cli.BoolFlag{
+				Name:        "body-digest",
+				EnvVars:     []string{"AZLIST_BODY_DIGEST"},
+				Usage:       `Replace each resource's body with a stable digest of it (i.e. {"digest": "sha256:<hex>"}), which is useful for change detection`,
+				Destination: &flagBodyDigest,
+			},
+			&cli.BoolFlag{
+				Name:        "strip-noise",
+				EnvVars:     []string{"AZLIST_STRIP_NOISE"},
+				Usage:       "Strip the volatile and noisy fields (e.g. etag, provisioning state, timestamps, system data) from each resource's body, so that the output is stable across runs",
+				Destination: &flagStripNoise,
+			},
+			&cli.StringFlag{
+				Name:        "baseline",
+				EnvVars:     []string{"AZLIST_BASELINE"},
+				Usage:       "The JSON output of a previous run, to report the resources added and removed since then, instead of the resources themselves",
+				Destination: &flagBaseline,
+			},
+			&cli.BoolFlag{
+				Name:        "show-body-diff",
+				EnvVars:     []string{"AZLIST_SHOW_BODY_DIFF"},
+				Usage:       "With --baseline, also report the resources whose bodies changed (excluding the volatile fields, see --volatile-field), as JSON patch style operations",
+				Destination: &flagShowBodyDiff,
+			},
+			&cli.StringFlag{
+				Name:        "diff-format",
+				EnvVars:     []string{"AZLIST_DIFF_FORMAT"},
+				Usage:       `The format of the body differences reported by --show-body-diff. Possible values are "json-patch" (RFC 6902, with the old values tested before changed) and "merge-patch" (RFC 7386), which only report the changed resources. Defaults to the --output format`,
+				Destination: &flagDiffFormat,
+			},
+			&cli.StringSliceFlag{
+				Name:        "volatile-field",
+				Aliases:     []string{"diff-ignore"},
+				EnvVars:     []string{"AZLIST_VOLATILE_FIELD", "AZLIST_DIFF_IGNORE"},
+				Usage:       `Additional volatile field that is removed from the resource body before digesting or diffing (see --show-body-diff), in form of "[<resource type>:]<dotted path>" (e.g. "properties.provisioningState", or "Microsoft.Web/sites:properties.lastModifiedTimeUtc"). Can be specified multiple times`,
+				Destination: &flagVolatileFields,
+			},
 			&cli.BoolFlag{
 				Name:        "include-managed",
 				Aliases:     []string{"m"},
@@ -81,6 +657,18 @@ func main() {
 				Usage:       "Include the resource groups that the listed resources belong to",
 				Destination: &flagIncludeResourceGroup,
 			},
+			&cli.BoolFlag{
+				Name:        "include-ancestors",
+				EnvVars:     []string{"AZLIST_INCLUDE_ANCESTORS"},
+				Usage:       "Include the management groups that the subscriptions of the listed resources belong to",
+				Destination: &flagIncludeAncestors,
+			},
+			&cli.BoolFlag{
+				Name:        "include-tenant-resources",
+				EnvVars:     []string{"AZLIST_INCLUDE_TENANT_RESOURCES"},
+				Usage:       "Include the resources at the tenant root scope, i.e. the management groups and the tenant scoped policy (set) definitions, regardless of the where predicate",
+				Destination: &flagIncludeTenantResources,
+			},
 			&cli.IntFlag{
 				Name:        "parallelism",
 				EnvVars:     []string{"AZLIST_PARALLELISM"},
@@ -89,14 +677,55 @@ func main() {
 				Value:       10,
 				Destination: &flagParallelism,
 			},
+			&cli.IntFlag{
+				Name:        "top",
+				EnvVars:     []string{"AZLIST_TOP"},
+				Usage:       "Stop listing once the number of resources is reached, for a quick sampling. With the filters that are applied after the listing (e.g. --tag, --location, --type), the resources are listed in full, then truncated after the filtering",
+				Destination: &flagTop,
+			},
 			&cli.StringSliceFlag{
 				Name:    "extension",
 				EnvVars: []string{"AZLIST_EXTENSION"},
 				Usage: `Specify a list of extension resource types (e.g. "Microsoft.Authorization/roleAssignments"). Some extension resource types have special filtering, which includes:
 	- Microsoft.Authorization/roleAssignments: Only role assignments whose "scope" is the same as the current resource is listed
+	- Microsoft.Authorization/policyAssignments: Only policy assignments whose "scope" is the same as the current resource is listed
+	- Microsoft.Authorization/locks: Only locks at the scope of the current resource is listed, excluding the ones inherited from the parent scopes
+	- Microsoft.Insights/diagnosticSettings: Only diagnostic settings of the current resource is listed, using the api-version of its scope (management group, subscription or resource). Resource groups are skipped, and so are the resources not supporting diagnostic settings
+	- Microsoft.Security/assessments, Microsoft.Security/subAssessments: Only the Defender for Cloud (sub-)assessments of the current resource is listed
 `,
 				Destination: &flagExtensions,
 			},
+			&cli.StringSliceFlag{
+				Name:        "api-version",
+				EnvVars:     []string{"AZLIST_API_VERSION"},
+				Usage:       `Pin the api-version of a resource type, in form of "<resource type>=<api-version>" (e.g. "Microsoft.Web/sites/slots=2022-03-01"), instead of using the latest one in the ARM schema. Can be specified multiple times`,
+				Destination: &flagAPIVersions,
+			},
+			&cli.StringFlag{
+				Name:        "api-version-strategy",
+				EnvVars:     []string{"AZLIST_API_VERSION_STRATEGY"},
+				Usage:       `The strategy to pick the api-version of the resource types that are not pinned by --api-version. Possible values are "latest", "latest-stable" (skips the preview versions if a stable one exists) and "oldest-stable".`,
+				Value:       "latest",
+				Destination: &flagAPIVersionStrategy,
+			},
+			&cli.StringFlag{
+				Name:        "schema-file",
+				EnvVars:     []string{"AZLIST_SCHEMA_FILE"},
+				Usage:       `A custom ARM schema file (e.g. for private resource providers or air-gapped clouds), which maps the resource types to their api-versions, e.g. {"Microsoft.Network/virtualNetworks": ["2022-07-01"]}. It is used instead of the embedded one`,
+				Destination: &flagSchemaFile,
+			},
+			&cli.StringFlag{
+				Name:        "schema-overlay",
+				EnvVars:     []string{"AZLIST_SCHEMA_OVERLAY"},
+				Usage:       `An ARM schema overlay file, in the same format as --schema-file, whose resource types add or override the ones of the ARM schema. A resource type with an empty api-version list is removed`,
+				Destination: &flagSchemaOverlay,
+			},
+			&cli.BoolFlag{
+				Name:        "live-schema",
+				EnvVars:     []string{"AZLIST_LIVE_SCHEMA"},
+				Usage:       "Discover the resource types and api-versions from the live resource provider metadata of the subscription, instead of the embedded schema, so that the newly released resource types are listed",
+				Destination: &flagLiveSchema,
+			},
 			&cli.StringFlag{
 				Name:        "table",
 				Aliases:     []string{"t"},
@@ -110,6 +739,104 @@ func main() {
 				Usage:       `The Azure Resource Graph Authorization Scope Filter parameter. Possible values are: "AtScopeAndBelow", "AtScopeAndAbove", "AtScopeAboveAndBelow" and "AtScopeExact"`,
 				Destination: &flagARGAuthorizationScopeFilter,
 			},
+			&cli.BoolFlag{
+				Name:        "allow-partial-scopes",
+				EnvVars:     []string{"AZLIST_ALLOW_PARTIAL_SCOPES"},
+				Usage:       "Allow the Azure Resource Graph query to succeed with the subscriptions that the caller has access to, instead of failing entirely",
+				Destination: &flagARGAllowPartialScopes,
+			},
+			&cli.IntFlag{
+				Name:        "arg-page-size",
+				EnvVars:     []string{"AZLIST_ARG_PAGE_SIZE"},
+				Usage:       "The number of records per Azure Resource Graph page, between 1 and 1000. Lower it to work around payload size errors for resources with huge bodies",
+				Value:       1000,
+				Destination: &flagARGPageSize,
+			},
+			&cli.IntFlag{
+				Name:        "arg-skip",
+				EnvVars:     []string{"AZLIST_ARG_SKIP"},
+				Usage:       "The number of Azure Resource Graph records to skip at the beginning",
+				Destination: &flagARGSkip,
+			},
+			&cli.StringFlag{
+				Name:        "preset",
+				EnvVars:     []string{"AZLIST_PRESET"},
+				Usage:       fmt.Sprintf("A builtin listing that sets the ARG table, predicate and authorization scope filter, in which case the predicate argument is optional and is combined with the preset one. Possible values are %s.", presetNames()),
+				Destination: &flagPreset,
+			},
+			&cli.StringSliceFlag{
+				Name:        "var",
+				EnvVars:     []string{"AZLIST_VAR"},
+				Usage:       `A variable in form of "<name>=<value>", which substitutes the "${<name>}" in the predicate argument as is (e.g. "tags.env =~ '${env}'"). Can be specified multiple times`,
+				Destination: &flagVars,
+			},
+			&cli.StringFlag{
+				Name:        "ids-file",
+				EnvVars:     []string{"AZLIST_IDS_FILE"},
+				Usage:       `Start from the resources of the ids in the file (or "-" for stdin), instead of an ARG query. The file contains one id per line, or is the JSON output of a previous run. Can't be used with a where predicate, --preset or --resource-group`,
+				Destination: &flagIdsFile,
+			},
+			&cli.StringFlag{
+				Name:        "resource-group",
+				EnvVars:     []string{"AZLIST_RESOURCE_GROUP"},
+				Aliases:     []string{"g"},
+				Usage:       "List the resources in the resource group, in which case the predicate argument is optional and is combined with it",
+				Destination: &flagResourceGroup,
+			},
+			&cli.StringSliceFlag{
+				Name:        "tag",
+				EnvVars:     []string{"AZLIST_TAG"},
+				Usage:       `Only keep the resources having the tag, in form of "key=value". The key is case sensitive, while the value is not. This applies to both the ARG query and the child and extension resources, where the resources without tags take the ones of their nearest listed ancestor. Can be specified multiple times`,
+				Destination: &flagTags,
+			},
+			&cli.StringSliceFlag{
+				Name:        "location",
+				EnvVars:     []string{"AZLIST_LOCATION"},
+				Usage:       "Only keep the resources in the location. This applies to both the ARG query and the child and extension resources, which take the location of their parents. Can be specified multiple times (or comma separated)",
+				Destination: &flagLocations,
+			},
+			&cli.StringFlag{
+				Name:        "match-id",
+				EnvVars:     []string{"AZLIST_MATCH_ID"},
+				Usage:       `Only keep the resources whose ids match the regexp (e.g. "(?i)/virtualMachines/web-")`,
+				Destination: &flagMatchId,
+			},
+			&cli.StringFlag{
+				Name:        "exclude-id",
+				EnvVars:     []string{"AZLIST_EXCLUDE_ID"},
+				Usage:       "Drop the resources whose ids match the regexp",
+				Destination: &flagExcludeId,
+			},
+			&cli.StringFlag{
+				Name:        "created-after",
+				EnvVars:     []string{"AZLIST_CREATED_AFTER"},
+				Usage:       `Only keep the resources created after the time, which is either a RFC3339 time or a duration before now (e.g. "72h"). The child and extension resources take the created time of their nearest listed ancestor. Resources whose created time is unknown are dropped`,
+				Destination: &flagCreatedAfter,
+			},
+			&cli.StringFlag{
+				Name:        "changed-after",
+				EnvVars:     []string{"AZLIST_CHANGED_AFTER"},
+				Usage:       `Only keep the resources changed after the time, which is either a RFC3339 time or a duration before now (e.g. "72h"). The child and extension resources take the changed time of their nearest listed ancestor. Resources whose changed time is unknown are dropped`,
+				Destination: &flagChangedAfter,
+			},
+			&cli.StringSliceFlag{
+				Name:        "include-type",
+				EnvVars:     []string{"AZLIST_INCLUDE_TYPE"},
+				Usage:       `Only keep the resources (including the child and extension resources) of the type, which is a case insensitive glob (e.g. "Microsoft.Network/*") or an exact type. Can be specified multiple times`,
+				Destination: &flagIncludeTypes,
+			},
+			&cli.StringSliceFlag{
+				Name:        "exclude-type",
+				EnvVars:     []string{"AZLIST_EXCLUDE_TYPE"},
+				Usage:       `Drop the resources (including the child and extension resources) of the type, which is a case insensitive glob (e.g. "Microsoft.Insights/*") or an exact type. Can be specified multiple times`,
+				Destination: &flagExcludeTypes,
+			},
+			&cli.IntFlag{
+				Name:        "expiring-within",
+				EnvVars:     []string{"AZLIST_EXPIRING_WITHIN"},
+				Usage:       `Only keep resources (e.g. policy exemptions, Key Vault secrets, certificates) that expire within the number of days, including the expired ones. Use with the "expiry" output for a report`,
+				Destination: &flagExpiringWithin,
+			},
 			&cli.BoolFlag{
 				Name:        "print-error",
 				Aliases:     []string{"e"},
@@ -117,6 +844,98 @@ func main() {
 				Usage:       "Print errors received during listing resources",
 				Destination: &flagPrintError,
 			},
+			&cli.BoolFlag{
+				Name:        "record-not-found",
+				EnvVars:     []string{"AZLIST_RECORD_NOT_FOUND"},
+				Usage:       "Record the child and extension resource listings responded with 404 (which are ignored by default), counted per resource type and error code, which are printed with --print-error",
+				Destination: &flagRecordNotFound,
+			},
+			&cli.BoolFlag{
+				Name:        "estimate-usage",
+				EnvVars:     []string{"AZLIST_ESTIMATE_USAGE"},
+				Usage:       "Print the API requests sent compared to the throttling limits to stderr",
+				Destination: &flagEstimateUsage,
+			},
+			&cli.DurationFlag{
+				Name:        "run-interval",
+				EnvVars:     []string{"AZLIST_RUN_INTERVAL"},
+				Usage:       "The interval that azlist is scheduled to run at, used by --estimate-usage to warn if it would exceed the throttling limits",
+				Destination: &flagRunInterval,
+			},
+			&cli.DurationFlag{
+				Name:        "progress-interval",
+				EnvVars:     []string{"AZLIST_PROGRESS_INTERVAL"},
+				Usage:       `The interval to log a summary of the listing progress (queued, in-flight per provider, completed and failed requests), at the "debug" log level`,
+				Destination: &flagProgressInterval,
+			},
+			&cli.DurationFlag{
+				Name:        "heartbeat",
+				EnvVars:     []string{"AZLIST_HEARTBEAT"},
+				Usage:       "The interval to print a one-line status (phase, discovered count, errors) to stderr, so that CI systems with no output timeouts don't kill long runs. On a terminal, the status is updated in place. It overrides the --progress-interval",
+				Destination: &flagHeartbeat,
+			},
+			&cli.StringFlag{
+				Name:        "output",
+				Aliases:     []string{"o"},
+				EnvVars:     []string{"AZLIST_OUTPUT"},
+				Usage:       `The output format. Possible values are "text", "json", "azapi-import", "crossplane", "ansible", "ssh-config", "cyclonedx", "csv", "ocsf", "steampipe", "tree", "dot", "tf-import", "expiry" and "identity-map".`,
+				Value:       "text",
+				Destination: &flagOutput,
+			},
+			&cli.StringSliceFlag{
+				Name:        "columns",
+				EnvVars:     []string{"AZLIST_COLUMNS"},
+				Usage:       `The columns of the "csv" output, each is a dotted path into the resource body (e.g. "tags.env"). Defaults to "id,type,location,resourceGroup".`,
+				Destination: &flagColumns,
+			},
+			&cli.BoolFlag{
+				Name:        "natural-sort",
+				EnvVars:     []string{"AZLIST_NATURAL_SORT"},
+				Usage:       `Order the resources in the "text" (except with --format-template) and "tree" outputs naturally, i.e. the numbers in the ids are compared by value (e.g. "vm2" before "vm10"). By default, the resources are ordered by their ids byte-wise, regardless of the locale`,
+				Destination: &flagNaturalSort,
+			},
+			&cli.StringFlag{
+				Name:        "output-dir",
+				EnvVars:     []string{"AZLIST_OUTPUT_DIR"},
+				Usage:       `The output directory, which is required by the output formats that write multiple files (i.e. "steampipe").`,
+				Destination: &flagOutputDir,
+			},
+			&cli.StringFlag{
+				Name:        "format-template",
+				EnvVars:     []string{"AZLIST_FORMAT_TEMPLATE"},
+				Usage:       `A Go text/template rendered per resource for the "text" output, with access to .Id, .Type, .Name, .Location, .APIVersion, .Scope, .DiscoveredThrough and .Properties (e.g. '{{ .Id }} {{ get .Properties "tags.env" }}')`,
+				Destination: &flagFormatTemplate,
+			},
+			&cli.StringFlag{
+				Name:        "query",
+				EnvVars:     []string{"AZLIST_QUERY"},
+				Usage:       `A JMESPath query applied to each resource body. An object result replaces the body, a null or false result filters out the resource, a true result keeps the body as is`,
+				Destination: &flagQuery,
+			},
+			&cli.StringSliceFlag{
+				Name:        "select",
+				EnvVars:     []string{"AZLIST_SELECT"},
+				Usage:       `Only keep the dotted paths (e.g. "location,properties.hardwareProfile.vmSize") of each resource body. Can be specified multiple times (or comma separated)`,
+				Destination: &flagSelect,
+			},
+			&cli.StringFlag{
+				Name:        "git-dir",
+				EnvVars:     []string{"AZLIST_GIT_DIR"},
+				Usage:       `Write one JSON file per resource into the git working tree, at the path derived from the lower cased resource id (e.g. "subscriptions/<id>/resourcegroups/<name>.json"), with the volatile fields removed (see --volatile-field). The "subscriptions" and "providers" directories are replaced by each run`,
+				Destination: &flagGitDir,
+			},
+			&cli.BoolFlag{
+				Name:        "git-commit",
+				EnvVars:     []string{"AZLIST_GIT_COMMIT"},
+				Usage:       "Commit the snapshot written to --git-dir, if changed. The working tree is initialized if not exists",
+				Destination: &flagGitCommit,
+			},
+			&cli.StringFlag{
+				Name:        "artifacts-dir",
+				EnvVars:     []string{"AZLIST_ARTIFACTS_DIR"},
+				Usage:       "The directory to write the artifacts of the run into, i.e. the result, errors, log, audit log of the API requests, summary and effective configuration, which are put in a timestamped sub-directory. The artifacts are written even if the run fails",
+				Destination: &flagArtifactsDir,
+			},
 			&cli.StringFlag{
 				Name:        "log-level",
 				Aliases:     []string{"L"},
@@ -125,136 +944,509 @@ func main() {
 				Destination: &flagLogLevel,
 			},
 		},
-		Action: func(ctx *cli.Context) error {
-			if ctx.NArg() == 0 {
-				return fmt.Errorf("No ARG where predicate specified")
-			}
-			if ctx.NArg() > 1 {
-				return fmt.Errorf("More than one where predicates specified")
-			}
-
-			var logger *slog.Logger
-			if flagLogLevel != "" {
-				var level slog.Level
-				switch strings.ToLower(flagLogLevel) {
-				case "error":
-					level = slog.LevelError
-				case "warn":
-					level = slog.LevelWarn
-				case "info":
-					level = slog.LevelInfo
-				case "debug":
-					level = slog.LevelDebug
+		Commands: []*cli.Command{
+			{
+				Name:  "config",
+				Usage: "Inspect the configuration",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "show",
+						Usage:     "Print the effective configuration, i.e. the global options resolved from the flags and environment variables",
+						UsageText: "azlist [option] config show",
+						Action: func(ctx *cli.Context) error {
+							enc := json.NewEncoder(os.Stdout)
+							enc.SetIndent("", "  ")
+							return enc.Encode(effectiveConfig(ctx))
+						},
+					},
+				},
+			},
+			{
+				Name:  "query",
+				Usage: `Manage the named queries, i.e. the predicates together with the global options, which are saved in the user config directory (or "$AZLIST_QUERY_DIR" if set)`,
+				Subcommands: []*cli.Command{
+					{
+						Name:      "save",
+						Usage:     "Save the predicate (or read from stdin if it is \"-\") as a named query, together with the global options explicitly specified in the command line (i.e. not the environment variables)",
+						UsageText: "azlist [option] query save [command option] <name> <ARG where predicate>",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "force",
+								Usage: "Overwrite the query if exists",
+							},
+						},
+						Action: func(ctx *cli.Context) error {
+							if ctx.NArg() != 2 {
+								return fmt.Errorf("exactly a name and a predicate are expected")
+							}
+							predicate, err := readPredicate(ctx.Args().Get(1))
+							if err != nil {
+								return err
+							}
+							path, err := saveQuery(ctx.Args().First(), savedQuery{Predicate: predicate, Flags: explicitFlags(ctx)}, ctx.Bool("force"))
+							if err != nil {
+								return err
+							}
+							fmt.Printf("Query %q saved to %s\n", ctx.Args().First(), path)
+							return nil
+						},
+					},
+					{
+						Name:      "ls",
+						Usage:     "List the saved queries",
+						UsageText: "azlist [option] query ls",
+						Action: func(ctx *cli.Context) error {
+							queries, names, err := listQueries()
+							if err != nil {
+								return err
+							}
+							if flagOutput == "json" {
+								enc := json.NewEncoder(os.Stdout)
+								enc.SetIndent("", "  ")
+								return enc.Encode(queries)
+							}
+							for _, name := range names {
+								fmt.Printf("%s\t%s\n", name, queries[name].Predicate)
+							}
+							return nil
+						},
+					},
+					{
+						Name:      "run",
+						Usage:     "Run the saved query, where the global options explicitly specified in the command line or by the environment variables take precedence over the saved ones",
+						UsageText: "azlist [option] query run <name>",
+						Action: func(ctx *cli.Context) error {
+							if ctx.NArg() != 1 {
+								return fmt.Errorf("exactly one query name is expected")
+							}
+							q, err := loadQuery(ctx.Args().First())
+							if err != nil {
+								return err
+							}
+							if err := applyFlags(ctx, q.Flags); err != nil {
+								return fmt.Errorf("applying the options of query %q: %v", ctx.Args().First(), err)
+							}
+							runCtx, err := contextWithArgs(ctx, q.Predicate)
+							if err != nil {
+								return err
+							}
+							return app.Action(runCtx)
+						},
+					},
+				},
+			},
+			{
+				Name:  "schema",
+				Usage: "Manage the ARM schema, i.e. the resource types and api-versions known by azlist",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "update",
+						Usage:     "Update the cached ARM schema from the resource provider metadata of the subscription, whose resource types and api-versions are merged into the embedded one by the following runs",
+						UsageText: "azlist [option] schema update",
+						Action: func(ctx *cli.Context) error {
+							if len(flagSubscriptionIds.Value()) == 0 {
+								return fmt.Errorf("--subscription-id is required to read the resource provider metadata")
+							}
+							opt, err := newListOption()
+							if err != nil {
+								return err
+							}
+							client, err := azlist.NewClient(flagSubscriptionIds.Value()[0], opt.Cred, opt.ClientOpt)
+							if err != nil {
+								return err
+							}
+							types, err := client.ProviderResourceTypes(ctx.Context, flagSubscriptionIds.Value()[0])
+							if err != nil {
+								return fmt.Errorf("listing the resource providers: %v", err)
+							}
+							path, n, err := azlist.UpdateARMSchemaCache(schemaCacheFile(), types)
+							if err != nil {
+								return fmt.Errorf("updating the ARM schema cache: %v", err)
+							}
+							fmt.Printf("ARM schema updated with %d resource types: %s\n", n, path)
+							return nil
+						},
+					},
+					{
+						Name:      "show",
+						Usage:     "Print the api-versions and child resource types of a resource type in the ARM schema, as well as the api-version that is picked",
+						UsageText: "azlist [option] schema show <resource type>",
+						Action: func(ctx *cli.Context) error {
+							if ctx.NArg() != 1 {
+								return fmt.Errorf("exactly one resource type is expected")
+							}
+							opt, err := newListOption()
+							if err != nil {
+								return err
+							}
+							tree, err := azlist.LoadARMSchemaTree(opt.ARMSchema, opt.ARMSchemaOverlay, opt.ARMSchemaCacheFile)
+							if err != nil {
+								return fmt.Errorf("building the ARM schema tree: %v", err)
+							}
+							return schemaShow(os.Stdout, tree, ctx.Args().First(), opt.APIVersions, opt.VersionStrategy)
+						},
+					},
+				},
+			},
+			{
+				Name:  "mg",
+				Usage: "Inspect the management group hierarchy",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "tree",
+						Usage:     "Print the management group and subscription hierarchy under a management group. The subscriptions under a node can then be listed by --management-group",
+						UsageText: "azlist [option] mg tree [command option]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "name",
+								Usage: "The name of the management group. Defaults to the tenant root management group",
+							},
+						},
+						Action: func(ctx *cli.Context) error {
+							opt, err := newListOption()
+							if err != nil {
+								return err
+							}
+							node, err := managementGroupTree(ctx.Context, *opt, ctx.String("name"))
+							if err != nil {
+								return err
+							}
+							if flagOutput == "json" {
+								enc := json.NewEncoder(os.Stdout)
+								enc.SetIndent("", "  ")
+								return enc.Encode(node)
+							}
+							return writeManagementGroupTree(os.Stdout, node)
+						},
+					},
+				},
+			},
+			{
+				Name:      "analyze",
+				Usage:     "Run analyzers against the listed resources and report the findings",
+				UsageText: "azlist [option] analyze [command option] <ARG where predicate>",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:  "analyzer",
+						Usage: fmt.Sprintf("The analyzer to run. Can be specified multiple times. Defaults to all. Possible values are %q", analyze.Names()),
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					_, result, err := listResources(ctx)
+					if err != nil {
+						return err
+					}
+					findings, err := analyze.Run(result, ctx.StringSlice("analyzer")...)
+					if err != nil {
+						return err
+					}
+					if flagOutput == "json" {
+						enc := json.NewEncoder(os.Stdout)
+						enc.SetIndent("", "  ")
+						return enc.Encode(findings)
+					}
+					for _, f := range findings {
+						fmt.Println(f)
+					}
+					return nil
+				},
+			},
+			{
+				Name:  "tag",
+				Usage: "Manage the tags of the listed resources",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "apply",
+						Usage:     "Apply (add or update) tags on every listed resource",
+						UsageText: "azlist [option] tag apply [command option] <ARG where predicate>",
+						Flags: []cli.Flag{
+							&cli.StringSliceFlag{
+								Name:     "set",
+								Usage:    `The tag to apply, in form of "key=value". Can be specified multiple times`,
+								Required: true,
+							},
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "Only print the resources to be tagged",
+							},
+						},
+						Action: func(ctx *cli.Context) error {
+							tags, err := parseKeyValues(ctx.StringSlice("set"))
+							if err != nil {
+								return fmt.Errorf("parsing --set: %v", err)
+							}
+							ls, result, err := listResources(ctx)
+							if err != nil {
+								return err
+							}
+							return tagApply(ctx.Context, os.Stdout, ls, result, tags, ctx.Bool("dry-run"))
+						},
+					},
+				},
+			},
+			{
+				Name:  "lock",
+				Usage: "Manage the management locks of the listed resources",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "apply",
+						Usage:     "Apply a management lock on every listed resource",
+						UsageText: "azlist [option] lock apply [command option] <ARG where predicate>",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "level",
+								Usage: `The lock level. Possible values are "CanNotDelete" and "ReadOnly"`,
+								Value: "CanNotDelete",
+							},
+							&cli.StringFlag{
+								Name:  "name",
+								Usage: "The lock name",
+								Value: "azlist",
+							},
+							&cli.StringFlag{
+								Name:  "notes",
+								Usage: "The lock notes",
+							},
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "Only print the resources to be locked",
+							},
+						},
+						Action: func(ctx *cli.Context) error {
+							level := ctx.String("level")
+							if level != "CanNotDelete" && level != "ReadOnly" {
+								return fmt.Errorf("unknown lock level specified: %q", level)
+							}
+							ls, result, err := listResources(ctx)
+							if err != nil {
+								return err
+							}
+							return lockApply(ctx.Context, os.Stdout, ls, result, ctx.String("name"), level, ctx.String("notes"), ctx.Bool("dry-run"))
+						},
+					},
+					{
+						Name:      "remove",
+						Usage:     "Remove the management lock from every listed resource",
+						UsageText: "azlist [option] lock remove [command option] <ARG where predicate>",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "name",
+								Usage: "The lock name",
+								Value: "azlist",
+							},
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "Only print the resources to be unlocked",
+							},
+						},
+						Action: func(ctx *cli.Context) error {
+							ls, result, err := listResources(ctx)
+							if err != nil {
+								return err
+							}
+							return lockRemove(ctx.Context, os.Stdout, ls, result, ctx.String("name"), ctx.Bool("dry-run"))
+						},
+					},
+				},
+			},
+			{
+				Name:      "move-check",
+				Usage:     "Validate whether the listed resources can be moved to the target resource group",
+				UsageText: "azlist [option] move-check [command option] <ARG where predicate>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "target-rg",
+						Usage:    "The name of the target resource group",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "target-subscription-id",
+						Usage: "The subscription id of the target resource group. Defaults to the listing subscription, if there is only one",
+					},
+				},
+				Action: func(ctx *cli.Context) error {
+					ls, result, err := listResources(ctx)
+					if err != nil {
+						return err
+					}
+					target := &armid.ResourceGroup{
+						Name: ctx.String("target-rg"),
+					}
+					if len(ls) == 1 {
+						target.SubscriptionId = ls[0].SubscriptionId
+					}
+					if sub := ctx.String("target-subscription-id"); sub != "" {
+						target.SubscriptionId = sub
+					}
+					if target.SubscriptionId == "" {
+						return fmt.Errorf("--target-subscription-id is required when listing across multiple subscriptions")
+					}
+					return moveCheck(ctx.Context, os.Stdout, ls, result, target)
+				},
+			},
+		},
+		Action: func(ctx *cli.Context) (err error) {
+			switch flagOutput {
+			case "text", "json", "azapi-import", "crossplane", "ansible", "ssh-config", "cyclonedx", "csv", "ocsf", "tree", "dot", "tf-import", "expiry", "identity-map":
+			case "steampipe":
+				if flagOutputDir == "" {
+					return fmt.Errorf("--output-dir is required for output format %q", flagOutput)
 				}
-				logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
-			}
-
-			cloudCfg := cloud.AzurePublic
-			switch strings.ToLower(flagEnvironment) {
-			case "public":
-				cloudCfg = cloud.AzurePublic
-			case "usgovernment":
-				cloudCfg = cloud.AzureGovernment
-			case "china":
-				cloudCfg = cloud.AzureChina
 			default:
-				return fmt.Errorf("unknown environment specified: %q", flagEnvironment)
+				return fmt.Errorf("unknown output format specified: %q", flagOutput)
 			}
 
-			if v, ok := os.LookupEnv("ARM_TENANT_ID"); ok {
-				os.Setenv("AZURE_TENANT_ID", v)
-			}
-			if v, ok := os.LookupEnv("ARM_CLIENT_ID"); ok {
-				os.Setenv("AZURE_CLIENT_ID", v)
-			}
-			if v, ok := os.LookupEnv("ARM_CLIENT_SECRET"); ok {
-				os.Setenv("AZURE_CLIENT_SECRET", v)
+			var tmpl *template.Template
+			if flagFormatTemplate != "" {
+				if flagOutput != "text" {
+					return fmt.Errorf("--format-template can only be used with the %q output", "text")
+				}
+				var err error
+				tmpl, err = output.ParseTemplate(flagFormatTemplate)
+				if err != nil {
+					return fmt.Errorf("parsing the format template: %v", err)
+				}
 			}
-			if v, ok := os.LookupEnv("ARM_CLIENT_CERTIFICATE_PATH"); ok {
-				os.Setenv("AZURE_CLIENT_CERTIFICATE_PATH", v)
+
+			var query *jmespath.JMESPath
+			if flagQuery != "" {
+				var err error
+				query, err = jmespath.Compile(flagQuery)
+				if err != nil {
+					return fmt.Errorf("compiling the query: %v", err)
+				}
 			}
 
-			clientOpt := arm.ClientOptions{
-				ClientOptions: policy.ClientOptions{
-					Cloud: cloudCfg,
-					Telemetry: policy.TelemetryOptions{
-						ApplicationID: "azlist",
-						Disabled:      false,
-					},
-					Logging: policy.LogOptions{
-						IncludeBody: true,
-					},
-				},
+			var result *azlist.ListResult
+			if flagArtifactsDir != "" {
+				if runArtifacts, err = newArtifacts(flagArtifactsDir); err != nil {
+					return err
+				}
+				// The artifacts are written on failure as well, which is when they are needed the most.
+				defer func() {
+					if ferr := runArtifacts.finish(result, err, effectiveConfig(ctx)); ferr != nil && err == nil {
+						err = fmt.Errorf("writing artifacts: %v", ferr)
+					}
+				}()
 			}
 
-			cred, err := azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
-				ClientOptions: clientOpt.ClientOptions,
-				TenantID:      os.Getenv("ARM_TENANT_ID"),
-			})
+			_, result, err = listResources(ctx)
 			if err != nil {
-				return fmt.Errorf("failed to obtain a credential: %v", err)
-			}
-
-			var extensions []azlist.ExtensionResource
-			for _, rt := range flagExtensions.Value() {
-				extension := azlist.ExtensionResource{Type: rt}
-				if strings.EqualFold(rt, "Microsoft.Authorization/roleAssignments") {
-					extension.Filter = func(res, extensionRes map[string]interface{}) bool {
-						idRaw, ok := res["id"]
-						if !ok {
-							return false
-						}
-						id := idRaw.(string)
-
-						propsRaw, ok := extensionRes["properties"]
-						if !ok {
-							return false
-						}
-						scopeRaw, ok := propsRaw.(map[string]interface{})["scope"]
-						if !ok {
-							return false
-						}
-						scope := scopeRaw.(string)
-
-						return strings.EqualFold(id, scope)
+				return err
+			}
+
+			if query != nil {
+				result.Resources, err = applyQuery(result.Resources, query)
+				if err != nil {
+					return err
+				}
+			}
+			if len(flagSelect.Value()) != 0 {
+				result.Resources = selectPaths(result.Resources, flagSelect.Value())
+			}
+			if flagStripNoise {
+				for i, res := range result.Resources {
+					result.Resources[i].Properties = azlist.CanonicalizeBody(azlist.ResourceType(res.Id), res.Properties, azlist.NoiseFields)
+				}
+			}
+			switch flagDiffFormat {
+			case "", "json-patch", "merge-patch":
+			default:
+				return fmt.Errorf("unknown diff format specified: %q", flagDiffFormat)
+			}
+			if flagDiffFormat != "" && !flagShowBodyDiff {
+				return fmt.Errorf("--diff-format can only be used with --show-body-diff")
+			}
+			if flagBaseline != "" {
+				diffs, err := diffBaseline(flagBaseline, result, flagShowBodyDiff, volatileFields(flagVolatileFields.Value()))
+				if err != nil {
+					return err
+				}
+				if flagPrintError {
+					printErrors(os.Stderr, result)
+				}
+				switch flagDiffFormat {
+				case "json-patch":
+					return output.DiffJSONPatch(os.Stdout, diffs)
+				case "merge-patch":
+					return output.DiffMergePatch(os.Stdout, diffs)
+				}
+				if flagOutput == "json" {
+					return output.DiffJSON(os.Stdout, diffs)
+				}
+				return output.Diff(os.Stdout, diffs)
+			}
+			if flagBodyDigest {
+				volatileFields := volatileFields(flagVolatileFields.Value())
+				for i, res := range result.Resources {
+					body := azlist.CanonicalizeBody(azlist.ResourceType(res.Id), res.Properties, volatileFields)
+					digest, err := azlist.BodyDigest(body)
+					if err != nil {
+						return fmt.Errorf("digesting the body of %s: %v", res.Id.String(), err)
 					}
+					result.Resources[i].Properties = map[string]interface{}{"digest": digest}
 				}
-				extensions = append(extensions, extension)
 			}
 
-			opt := azlist.Option{
-				SubscriptionId: flagSubscriptionId,
-				Cred:           cred,
-				ClientOpt:      clientOpt,
+			if flagGitDir != "" {
+				if err := writeGitSnapshot(os.Stderr, flagGitDir, result, volatileFields(flagVolatileFields.Value()), flagGitCommit); err != nil {
+					return fmt.Errorf("writing git snapshot: %v", err)
+				}
+			}
 
-				Logger:                      logger,
-				Parallelism:                 flagParallelism,
-				Recursive:                   flagRecursive,
-				IncludeManaged:              flagIncludeManaged,
-				IncludeResourceGroup:        flagIncludeResourceGroup,
-				ExtensionResourceTypes:      extensions,
-				ARGTable:                    flagARGTable,
-				ARGAuthorizationScopeFilter: armresourcegraph.AuthorizationScopeFilter(flagARGAuthorizationScopeFilter),
+			if flagOutput != "text" {
+				if flagPrintError {
+					printErrors(os.Stderr, result)
+				}
+				switch flagOutput {
+				case "json":
+					return output.JSON(os.Stdout, result)
+				case "azapi-import":
+					return output.AzapiImport(os.Stdout, result)
+				case "crossplane":
+					return output.Crossplane(os.Stdout, result)
+				case "ansible":
+					return output.Ansible(os.Stdout, result)
+				case "ssh-config":
+					return output.SSHConfig(os.Stdout, result)
+				case "cyclonedx":
+					return output.CycloneDX(os.Stdout, result)
+				case "csv":
+					return output.CSV(os.Stdout, result, flagColumns.Value())
+				case "ocsf":
+					return output.OCSF(os.Stdout, result)
+				case "steampipe":
+					return output.Steampipe(flagOutputDir, result)
+				case "tree":
+					less := output.ByteLess
+					if flagNaturalSort {
+						less = output.NaturalLess
+					}
+					return output.Tree(os.Stdout, result, less)
+				case "dot":
+					return output.DOT(os.Stdout, result)
+				case "tf-import":
+					return output.TFImport(os.Stdout, result)
+				case "expiry":
+					return output.Expiry(os.Stdout, result)
+				case "identity-map":
+					return output.IdentityMap(os.Stdout, result)
+				}
 			}
 
-			l, err := azlist.NewLister(opt)
-			if err != nil {
-				return err
+			if flagPrintError {
+				printErrors(os.Stdout, result)
 			}
 
-			result, err := l.List(ctx.Context, ctx.Args().First())
-			if err != nil {
-				return err
+			// The --format-template output keeps the order of the ids, as the --natural-sort only applies to the "text" and "tree" outputs.
+			if tmpl != nil {
+				return output.Template(os.Stdout, result, tmpl)
 			}
 
-			if flagPrintError {
-				if len(result.Errors) != 0 {
-					fmt.Println("Listing errors:")
-					for _, err := range result.Errors {
-						fmt.Printf("\t%v\n", err)
-					}
-					fmt.Println()
-				}
+			if flagNaturalSort {
+				sort.SliceStable(result.Resources, func(i, j int) bool {
+					return output.NaturalLess(result.Resources[i].Id.String(), result.Resources[j].Id.String())
+				})
 			}
 
 			for _, res := range result.Resources {
@@ -265,12 +1457,65 @@ func main() {
 				}
 			}
 
+			// Records without resource ids, e.g. from tables other than "Resources".
+			for _, row := range result.Rows {
+				b, _ := json.Marshal(row)
+				fmt.Println(string(b))
+			}
+
 			return nil
 		},
 	}
 
+	cli.VersionPrinter = printVersion
+
 	if err := app.Run(os.Args); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
+
+func printErrors(w io.Writer, result *azlist.ListResult) {
+	if len(result.Errors) != 0 {
+		fmt.Fprintln(w, "Listing errors:")
+		for _, err := range result.Errors {
+			fmt.Fprintf(w, "\t[%s] %v\n", azlist.ClassifyError(err), err)
+		}
+		fmt.Fprintln(w)
+	}
+	if len(result.Unparseable) != 0 {
+		fmt.Fprintln(w, "Unparseable resource ids:")
+		for _, res := range result.Unparseable {
+			fmt.Fprintf(w, "\t%s: %s\n", res.Id, res.Message)
+		}
+		fmt.Fprintln(w)
+	}
+	if len(result.NotFound) != 0 {
+		fmt.Fprintln(w, "Listings not found (404):")
+		for _, nf := range result.NotFound {
+			fmt.Fprintf(w, "\t[%s] %s: %d (e.g. %s)\n", nf.ErrorCode, nf.ResourceType, nf.Count, nf.Endpoint)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+type listers []*azlist.Lister
+
+// listerOf returns the lister of the subscription of the resource.
+func (ls listers) listerOf(res azlist.AzureResource) (*azlist.Lister, error) {
+	if l := ls.forSubscription(res.SubscriptionId); l != nil {
+		return l, nil
+	}
+	return nil, fmt.Errorf("no lister found for subscription %q", res.SubscriptionId)
+}
+
+// forSubscription returns the lister of the subscription, or nil if not found.
+// A tenant wide lister (i.e. with an empty subscription id) serves any subscription.
+func (ls listers) forSubscription(subscriptionId string) *azlist.Lister {
+	for _, l := range ls {
+		if l.SubscriptionId == "" || strings.EqualFold(l.SubscriptionId, subscriptionId) {
+			return l
+		}
+	}
+	return nil
+}
@@ -0,0 +1,558 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/magodo/armid"
+	"github.com/magodo/azlist/azlist"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+type fakeCredential struct{}
+
+func (fakeCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "fake", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+// testRequest is a request received by the test server.
+type testRequest struct {
+	Method     string
+	Path       string
+	APIVersion string
+	Body       map[string]interface{}
+}
+
+// newTestListers returns the listers of the subscription "xxx", whose requests are served by the handler of a test server, and recorded in order.
+func newTestListers(t *testing.T, handler http.HandlerFunc) (listers, func() []testRequest) {
+	var (
+		mu       sync.Mutex
+		requests []testRequest
+	)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := testRequest{Method: r.Method, Path: r.URL.Path, APIVersion: r.URL.Query().Get("api-version")}
+		if b, _ := io.ReadAll(r.Body); len(b) != 0 {
+			require.NoError(t, json.Unmarshal(b, &req.Body))
+		}
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	opt := azlist.Option{
+		SubscriptionId: "xxx",
+		Cred:           fakeCredential{},
+		Parallelism:    1,
+	}
+	opt.ClientOpt.Transport = srv.Client()
+	opt.ClientOpt.Retry.MaxRetries = -1
+	opt.ClientOpt.Cloud = cloud.Configuration{
+		ActiveDirectoryAuthorityHost: srv.URL,
+		Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+			cloud.ResourceManager: {Endpoint: srv.URL, Audience: srv.URL},
+		},
+	}
+	l, err := azlist.NewLister(opt)
+	require.NoError(t, err)
+	return listers{l}, func() []testRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]testRequest{}, requests...)
+	}
+}
+
+func TestParseIds(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		expect []string
+		err    bool
+	}{
+		{
+			name:   "lines",
+			input:  "\n# comment\n/subscriptions/xxx/resourceGroups/rg1\n  /subscriptions/xxx/resourceGroups/rg2  \n\n",
+			expect: []string{"/subscriptions/xxx/resourceGroups/rg1", "/subscriptions/xxx/resourceGroups/rg2"},
+		},
+		{
+			name:   "json output",
+			input:  `{"resources": [{"id": "/subscriptions/xxx/resourceGroups/rg1"}, {"name": "noid"}], "errors": []}`,
+			expect: []string{"/subscriptions/xxx/resourceGroups/rg1"},
+		},
+		{
+			name:   "json array",
+			input:  ` [{"id": "/subscriptions/xxx/resourceGroups/rg1"}, {"id": "/subscriptions/xxx/resourceGroups/rg2"}]`,
+			expect: []string{"/subscriptions/xxx/resourceGroups/rg1", "/subscriptions/xxx/resourceGroups/rg2"},
+		},
+		{
+			name:  "invalid json",
+			input: `{"resources": [`,
+			err:   true,
+		},
+		{
+			name:  "empty",
+			input: "\n# nothing\n",
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, err := parseIds([]byte(tt.input))
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expect, ids)
+		})
+	}
+}
+
+func testResources(t *testing.T, bodies ...map[string]interface{}) []azlist.AzureResource {
+	var rl []azlist.AzureResource
+	for _, body := range bodies {
+		id, err := armid.ParseResourceId(body["id"].(string))
+		require.NoError(t, err)
+		rl = append(rl, azlist.AzureResource{Id: id, Properties: body})
+	}
+	return rl
+}
+
+func resourceIds(rl []azlist.AzureResource) []string {
+	out := []string{}
+	for _, res := range rl {
+		out = append(out, res.Id.String())
+	}
+	return out
+}
+
+const (
+	testRG     = "/subscriptions/xxx/resourceGroups/rg1"
+	testVnet   = testRG + "/providers/Microsoft.Network/virtualNetworks/vnet1"
+	testSubnet = testVnet + "/subnets/subnet1"
+	testVnet2  = testRG + "/providers/Microsoft.Network/virtualNetworks/vnet2"
+	testSA     = testRG + "/providers/Microsoft.Storage/storageAccounts/sa1"
+)
+
+func TestFilterTypes(t *testing.T) {
+	rl := testResources(t,
+		map[string]interface{}{"id": testVnet},
+		map[string]interface{}{"id": testSubnet},
+		map[string]interface{}{"id": testSA},
+	)
+	cases := []struct {
+		name     string
+		includes []string
+		excludes []string
+		expect   []string
+		err      bool
+	}{
+		{name: "no pattern", expect: []string{testVnet, testSubnet, testSA}},
+		{name: "include with children", includes: []string{"Microsoft.Network/virtualNetworks"}, expect: []string{testVnet, testSubnet}},
+		{name: "include glob", includes: []string{"microsoft.storage/*"}, expect: []string{testSA}},
+		{name: "exclude child", includes: []string{"Microsoft.Network/*"}, excludes: []string{"Microsoft.Network/virtualNetworks/subnets"}, expect: []string{testVnet}},
+		{name: "invalid pattern", includes: []string{"Microsoft.Network/["}, err: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := filterTypes(rl, tt.includes, tt.excludes)
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expect, resourceIds(out))
+		})
+	}
+}
+
+func TestFilterTags(t *testing.T) {
+	rl := testResources(t,
+		map[string]interface{}{"id": testRG, "tags": map[string]interface{}{"env": "prod", "team": "a"}},
+		map[string]interface{}{"id": testVnet, "tags": map[string]interface{}{"Env": "prod"}},
+		map[string]interface{}{"id": testSubnet},
+		map[string]interface{}{"id": testVnet2},
+		map[string]interface{}{"id": testSA, "tags": map[string]interface{}{"env": "test"}},
+	)
+	cases := []struct {
+		name   string
+		tags   map[string]string
+		expect []string
+	}{
+		// The vnet2 takes the tags of the resource group, while the subnet takes the ones of the vnet, whose key is of another casing.
+		{name: "case insensitive value", tags: map[string]string{"env": "PROD"}, expect: []string{testRG, testVnet2}},
+		{name: "case sensitive key", tags: map[string]string{"Env": "prod"}, expect: []string{testVnet, testSubnet}},
+		{name: "all tags", tags: map[string]string{"env": "prod", "team": "a"}, expect: []string{testRG, testVnet2}},
+		{name: "no match", tags: map[string]string{"env": "dev"}, expect: []string{}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expect, resourceIds(filterTags(rl, tt.tags)))
+		})
+	}
+}
+
+func TestKqlQuote(t *testing.T) {
+	cases := []struct {
+		input  string
+		expect string
+	}{
+		{"rg1", `'rg1'`},
+		{"rg1' or true or '", `'rg1\' or true or \''`},
+		{`rg1\`, `'rg1\\'`},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.expect, kqlQuote(c.input))
+	}
+	require.Equal(t, `location in~ ('westus', 'east\'us')`, locationPredicate([]string{"West US", "east'us"}))
+}
+
+func TestFilterLocations(t *testing.T) {
+	rl := testResources(t,
+		map[string]interface{}{"id": testVnet, "location": "westeurope"},
+		map[string]interface{}{"id": testSubnet},
+		map[string]interface{}{"id": testVnet2, "location": "eastus"},
+		// No location, nor any listed ancestor.
+		map[string]interface{}{"id": testSA},
+	)
+	cases := []struct {
+		name      string
+		locations []string
+		expect    []string
+	}{
+		{name: "display name", locations: []string{"West Europe"}, expect: []string{testVnet, testSubnet}},
+		{name: "any location", locations: []string{"westeurope", "EastUS"}, expect: []string{testVnet, testSubnet, testVnet2}},
+		{name: "no match", locations: []string{"westus"}, expect: []string{}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expect, resourceIds(filterLocations(rl, tt.locations)))
+		})
+	}
+}
+
+func TestFilterIds(t *testing.T) {
+	rl := testResources(t,
+		map[string]interface{}{"id": testVnet},
+		map[string]interface{}{"id": testSubnet},
+		map[string]interface{}{"id": testSA},
+	)
+	cases := []struct {
+		name    string
+		match   string
+		exclude string
+		expect  []string
+	}{
+		{name: "none", expect: []string{testVnet, testSubnet, testSA}},
+		{name: "match", match: `(?i)/virtualnetworks/`, expect: []string{testVnet, testSubnet}},
+		{name: "exclude", exclude: `/subnets/`, expect: []string{testVnet, testSA}},
+		{name: "match and exclude", match: `Microsoft.Network`, exclude: `/subnets/`, expect: []string{testVnet}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var match, exclude *regexp.Regexp
+			if tt.match != "" {
+				match = regexp.MustCompile(tt.match)
+			}
+			if tt.exclude != "" {
+				exclude = regexp.MustCompile(tt.exclude)
+			}
+			require.Equal(t, tt.expect, resourceIds(filterIds(rl, match, exclude)))
+		})
+	}
+}
+
+func TestFilterTimes(t *testing.T) {
+	rl := testResources(t,
+		map[string]interface{}{"id": testVnet, "createdTime": "2023-01-02T00:00:00Z"},
+		map[string]interface{}{"id": testSubnet},
+		map[string]interface{}{"id": testVnet2, "createdTime": "2022-01-01T00:00:00Z"},
+		map[string]interface{}{"id": testSA, "createdTime": "malformed"},
+	)
+	cases := []struct {
+		name   string
+		after  time.Time
+		expect []string
+	}{
+		{name: "inherited", after: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), expect: []string{testVnet, testSubnet}},
+		{name: "all", after: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), expect: []string{testVnet, testSubnet, testVnet2}},
+		{name: "none", after: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), expect: []string{}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expect, resourceIds(filterTimes(rl, "createdTime", tt.after)))
+		})
+	}
+}
+
+func TestParseTimeOrAgo(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		expect func() time.Time
+		err    bool
+	}{
+		{name: "duration", input: "72h", expect: func() time.Time { return time.Now().Add(-72 * time.Hour) }},
+		{name: "RFC3339", input: "2023-01-02T03:04:05Z", expect: func() time.Time { return time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC) }},
+		{name: "invalid", input: "yesterday", err: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTimeOrAgo(tt.input)
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.WithinDuration(t, tt.expect(), got, time.Minute)
+		})
+	}
+}
+
+func TestParseVarsAndExpandPredicate(t *testing.T) {
+	cases := []struct {
+		name      string
+		vars      []string
+		predicate string
+		expect    string
+		err       bool
+	}{
+		{
+			name:      "simple",
+			vars:      []string{"env=prod"},
+			predicate: "tags.env =~ '${env}'",
+			expect:    "tags.env =~ 'prod'",
+		},
+		{
+			// The flag values split by commas are rejoined.
+			name:      "comma rejoined",
+			vars:      []string{"regions='eastus'", "'westus'", "env="},
+			predicate: "location in (${regions}) and tags.env =~ '${env}'",
+			expect:    "location in ('eastus','westus') and tags.env =~ ''",
+		},
+		{name: "leading value without name", vars: []string{"'eastus'"}, err: true},
+		{name: "invalid name", vars: []string{"1env=prod"}, err: true},
+		{name: "undefined variable", predicate: "tags.env =~ '${env}'", err: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			vars, err := parseVars(tt.vars)
+			if err == nil {
+				got, err = expandPredicate(tt.predicate, vars)
+			}
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func TestParseListQueryParameters(t *testing.T) {
+	cases := []struct {
+		name   string
+		values []string
+		expect map[string]url.Values
+		err    bool
+	}{
+		{
+			name:   "comma rejoined",
+			values: []string{"Microsoft.Web/sites:$expand=a", "b", "microsoft.web/sites:$top=10", "Microsoft.Network/virtualNetworks:$filter=x eq 'y'"},
+			expect: map[string]url.Values{
+				"MICROSOFT.WEB/SITES":               {"$expand": {"a,b"}, "$top": {"10"}},
+				"MICROSOFT.NETWORK/VIRTUALNETWORKS": {"$filter": {"x eq 'y'"}},
+			},
+		},
+		{name: "leading value without key", values: []string{"b"}, err: true},
+		{name: "no resource type", values: []string{":$top=10"}, err: true},
+		{name: "no key", values: []string{"Microsoft.Web/sites:=10"}, err: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseListQueryParameters(tt.values)
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func TestExplicitAndApplyFlags(t *testing.T) {
+	var (
+		flagOutput string
+		flagTop    int
+		flagTags   cli.StringSlice
+		explicit   map[string][]string
+	)
+	app := &cli.App{
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Value: "text", Destination: &flagOutput},
+			&cli.IntFlag{Name: "top", Destination: &flagTop},
+			&cli.StringSliceFlag{Name: "tag", Destination: &flagTags},
+		},
+		Commands: []*cli.Command{
+			{
+				Name: "run",
+				Action: func(ctx *cli.Context) error {
+					explicit = explicitFlags(ctx)
+					return applyFlags(ctx, map[string][]string{
+						"output": {"json"},
+						"top":    {"10"},
+						"tag":    {"env=prod", "team=a"},
+					})
+				},
+			},
+		},
+	}
+	require.NoError(t, app.Run([]string{"azlist", "-o", "csv", "run"}))
+	// The ones explicitly set in the command line (by any name) take precedence.
+	require.Equal(t, map[string][]string{"output": {"csv"}}, explicit)
+	require.Equal(t, "csv", flagOutput)
+	require.Equal(t, 10, flagTop)
+	require.Equal(t, []string{"env=prod", "team=a"}, flagTags.Value())
+
+	// The ones set by the environment variables are not saved, but take precedence as well.
+	t.Setenv("AZLIST_TEST_TOP", "5")
+	app.Flags[1].(*cli.IntFlag).EnvVars = []string{"AZLIST_TEST_TOP"}
+	require.NoError(t, app.Run([]string{"azlist", "run"}))
+	require.Empty(t, explicit)
+	require.Equal(t, 5, flagTop)
+	require.Equal(t, "json", flagOutput)
+
+	app.Commands[0].Action = func(ctx *cli.Context) error {
+		return applyFlags(ctx, map[string][]string{"unknown": {"x"}})
+	}
+	require.Error(t, app.Run([]string{"azlist", "run"}))
+}
+
+func TestTagApply(t *testing.T) {
+	ls, requests := newTestListers(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	})
+
+	// The resource whose tags are up to date is skipped, while the others are merged with the tags, regardless of their api-versions.
+	rl := testResources(t,
+		map[string]interface{}{"id": testRG, "tags": map[string]interface{}{"env": "prod", "owner": "alice"}},
+		map[string]interface{}{"id": testVnet, "tags": map[string]interface{}{"env": "dev"}},
+	)
+	for i := range rl {
+		rl[i].SubscriptionId = "xxx"
+	}
+	result := &azlist.ListResult{Resources: rl}
+	tags := map[string]string{"env": "prod"}
+
+	var buf bytes.Buffer
+	require.NoError(t, tagApply(context.Background(), &buf, ls, result, tags, true))
+	require.Equal(t, "Would tag "+testVnet+"\n", buf.String())
+	require.Empty(t, requests())
+
+	buf.Reset()
+	require.NoError(t, tagApply(context.Background(), &buf, ls, result, tags, false))
+	require.Equal(t, "Tagged "+testVnet+"\n", buf.String())
+	require.Equal(t, []testRequest{
+		{
+			Method:     http.MethodPatch,
+			Path:       testVnet + "/providers/Microsoft.Resources/tags/default",
+			APIVersion: "2021-04-01",
+			Body: map[string]interface{}{
+				"operation":  "Merge",
+				"properties": map[string]interface{}{"tags": map[string]interface{}{"env": "prod"}},
+			},
+		},
+	}, requests())
+}
+
+func TestMoveCheck(t *testing.T) {
+	const (
+		otherRG   = "/subscriptions/yyy/resourceGroups/rg3"
+		otherVnet = otherRG + "/providers/Microsoft.Network/virtualNetworks/vnet3"
+		targetNic = "/subscriptions/xxx/resourceGroups/target/providers/Microsoft.Network/networkInterfaces/nic1"
+	)
+	ls, requests := newTestListers(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	rl := testResources(t,
+		map[string]interface{}{"id": testRG},
+		map[string]interface{}{"id": testVnet},
+		map[string]interface{}{"id": testSubnet},
+		map[string]interface{}{"id": testVnet + "/providers/Microsoft.Authorization/locks/lock1"},
+		map[string]interface{}{"id": otherVnet},
+		map[string]interface{}{"id": targetNic},
+	)
+	target, err := armid.ParseResourceId("/subscriptions/xxx/resourceGroups/target")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = moveCheck(context.Background(), &buf, ls, &azlist.ListResult{Resources: rl}, target.(*armid.ResourceGroup))
+	require.EqualError(t, err, "1 out of 2 resource groups can't be moved")
+	// Only the top level resources out of the target resource group are validated, grouped by their resource groups.
+	require.Equal(t, "Can move from "+testRG+":\n\t"+testVnet+"\n\n"+
+		"Cannot move from "+otherRG+":\n\t"+otherVnet+"\nReason: no lister found for subscription \"yyy\"\n\n", buf.String())
+	reqs := requests()
+	require.Len(t, reqs, 1)
+	require.Equal(t, http.MethodPost, reqs[0].Method)
+	require.Equal(t, testRG+"/validateMoveResources", reqs[0].Path)
+	require.Equal(t, map[string]interface{}{
+		"resources":           []interface{}{testVnet},
+		"targetResourceGroup": target.String(),
+	}, reqs[0].Body)
+}
+
+func TestLock(t *testing.T) {
+	ls, requests := newTestListers(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte(`{}`))
+	})
+	rl := testResources(t, map[string]interface{}{"id": testVnet})
+	rl[0].SubscriptionId = "xxx"
+	result := &azlist.ListResult{Resources: rl}
+	lockPath := testVnet + "/providers/Microsoft.Authorization/locks/azlist"
+
+	var buf bytes.Buffer
+	require.NoError(t, lockApply(context.Background(), &buf, ls, result, "azlist", "CanNotDelete", "by azlist", true))
+	require.NoError(t, lockRemove(context.Background(), &buf, ls, result, "azlist", true))
+	require.Equal(t, "Would lock "+testVnet+" (CanNotDelete)\nWould unlock "+testVnet+"\n", buf.String())
+	require.Empty(t, requests())
+
+	buf.Reset()
+	require.NoError(t, lockApply(context.Background(), &buf, ls, result, "azlist", "CanNotDelete", "by azlist", false))
+	require.NoError(t, lockRemove(context.Background(), &buf, ls, result, "azlist", false))
+	require.Equal(t, "Locked "+testVnet+" (CanNotDelete)\nUnlocked "+testVnet+"\n", buf.String())
+	require.Equal(t, []testRequest{
+		{
+			Method:     http.MethodPut,
+			Path:       lockPath,
+			APIVersion: "2016-09-01",
+			Body: map[string]interface{}{
+				"properties": map[string]interface{}{"level": "CanNotDelete", "notes": "by azlist"},
+			},
+		},
+		{
+			Method:     http.MethodDelete,
+			Path:       lockPath,
+			APIVersion: "2016-09-01",
+		},
+	}, requests())
+}
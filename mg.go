@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/magodo/azlist/azlist"
+)
+
+// managementGroupTree reads the management group hierarchy under the management group, or the tenant root one if the name is empty.
+func managementGroupTree(ctx context.Context, opt azlist.Option, name string) (*azlist.ManagementGroupNode, error) {
+	opt.AllSubscriptions = true
+	opt.LiveSchema = false
+	l, err := azlist.NewLister(opt)
+	if err != nil {
+		return nil, err
+	}
+	return l.ManagementGroupTree(ctx, name)
+}
+
+// writeManagementGroupTree writes the management group hierarchy as an indented tree, with the subscriptions as the leaves.
+func writeManagementGroupTree(w io.Writer, node *azlist.ManagementGroupNode) error {
+	if _, err := fmt.Fprintln(w, managementGroupLabel(node)); err != nil {
+		return err
+	}
+	return writeManagementGroupChildren(w, node, "")
+}
+
+func writeManagementGroupChildren(w io.Writer, node *azlist.ManagementGroupNode, prefix string) error {
+	for i, child := range node.Children {
+		branch, indent := "├── ", "│   "
+		if i == len(node.Children)-1 {
+			branch, indent = "└── ", "    "
+		}
+		if _, err := fmt.Fprintln(w, prefix+branch+managementGroupLabel(child)); err != nil {
+			return err
+		}
+		if err := writeManagementGroupChildren(w, child, prefix+indent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func managementGroupLabel(node *azlist.ManagementGroupNode) string {
+	kind := "management group"
+	if node.IsSubscription() {
+		kind = "subscription"
+	}
+	if node.DisplayName != "" && node.DisplayName != node.Name {
+		return fmt.Sprintf("%s [%s] (%s)", node.DisplayName, node.Name, kind)
+	}
+	return fmt.Sprintf("%s (%s)", node.Name, kind)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/magodo/armid"
+	"github.com/magodo/azlist/azlist"
+	"github.com/magodo/workerpool"
+)
+
+// moveCheck validates whether the listed resources can be moved to the target resource group, one validation per source resource group.
+// Only the top level resources are validated, as child and extension resources are moved together with their parents.
+// A resource group whose subscription has no lister is reported as can't be moved.
+func moveCheck(ctx context.Context, w io.Writer, ls listers, result *azlist.ListResult, target *armid.ResourceGroup) error {
+	groups := map[string][]armid.ResourceId{}
+	rgs := map[string]*armid.ResourceGroup{}
+	for _, res := range result.Resources {
+		rg, ok := res.Id.ParentScope().(*armid.ResourceGroup)
+		if !ok || res.Id.Parent() != nil {
+			continue
+		}
+		key := strings.ToUpper(rg.String())
+		rgs[key] = rg
+		groups[key] = append(groups[key], res.Id)
+	}
+
+	type moveResult struct {
+		rg  *armid.ResourceGroup
+		ids []armid.ResourceId
+		err error
+	}
+	var results []moveResult
+
+	wp := workerpool.NewWorkPool(ls[0].Parallelism)
+	wp.Run(func(i interface{}) error {
+		results = append(results, i.(moveResult))
+		return nil
+	})
+	for key, ids := range groups {
+		rg, ids := rgs[key], ids
+		if strings.EqualFold(rg.String(), target.String()) {
+			continue
+		}
+		l := ls.forSubscription(rg.SubscriptionId)
+		wp.AddTask(func() (interface{}, error) {
+			if l == nil {
+				return moveResult{rg: rg, ids: ids, err: fmt.Errorf("no lister found for subscription %q", rg.SubscriptionId)}, nil
+			}
+			return moveResult{rg: rg, ids: ids, err: l.Client.ValidateMoveResources(ctx, rg, ids, target)}, nil
+		})
+	}
+	if err := wp.Done(); err != nil {
+		return err
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].rg.String() < results[j].rg.String()
+	})
+	var failures int
+	for _, res := range results {
+		if res.err != nil {
+			failures++
+			fmt.Fprintf(w, "Cannot move from %s:\n", res.rg.String())
+		} else {
+			fmt.Fprintf(w, "Can move from %s:\n", res.rg.String())
+		}
+		for _, id := range res.ids {
+			fmt.Fprintf(w, "\t%s\n", id.String())
+		}
+		if res.err != nil {
+			fmt.Fprintf(w, "Reason: %v\n", res.err)
+		}
+		fmt.Fprintln(w)
+	}
+	if failures != 0 {
+		return fmt.Errorf("%d out of %d resource groups can't be moved", failures, len(results))
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/magodo/armid"
+	"github.com/magodo/azlist/azlist"
+)
+
+var ansibleGroupInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+func ansibleGroupName(prefix string, parts ...string) string {
+	return prefix + "_" + ansibleGroupInvalidChars.ReplaceAllString(strings.Join(parts, "_"), "_")
+}
+
+type ansibleGroup struct {
+	Hosts    []string `json:"hosts,omitempty"`
+	Children []string `json:"children,omitempty"`
+}
+
+// Ansible writes an Ansible dynamic inventory (in JSON) of the virtual machines and virtual machine scale set instances.
+// Hosts are grouped by their resource groups ("rg_<name>"), tags ("tag_<key>_<value>") and scale sets ("vmss_<name>").
+// The host variables are populated from the resource bodies.
+func Ansible(w io.Writer, result *azlist.ListResult) error {
+	groups := map[string]*ansibleGroup{}
+	addHost := func(group, host string) {
+		g, ok := groups[group]
+		if !ok {
+			g = &ansibleGroup{}
+			groups[group] = g
+		}
+		g.Hosts = append(g.Hosts, host)
+	}
+
+	vmssTags := map[string]map[string]interface{}{}
+	for _, res := range result.Resources {
+		if strings.EqualFold(azlist.ResourceType(res.Id), "Microsoft.Compute/virtualMachineScaleSets") {
+			tags, _ := res.Properties["tags"].(map[string]interface{})
+			vmssTags[strings.ToUpper(res.Id.String())] = tags
+		}
+	}
+
+	hostvars := map[string]interface{}{}
+	hostNames := map[string]int{}
+	for _, res := range result.Resources {
+		rt := azlist.ResourceType(res.Id)
+		var vmss armid.ResourceId
+		switch {
+		case strings.EqualFold(rt, "Microsoft.Compute/virtualMachines"):
+		case strings.EqualFold(rt, "Microsoft.Compute/virtualMachineScaleSets/virtualMachines"):
+			vmss = res.Id.Parent()
+		default:
+			continue
+		}
+
+		names := res.Id.Names()
+		host := names[len(names)-1]
+		if vmss != nil {
+			host = names[0] + "_" + host
+		}
+		hostNames[host]++
+		if cnt := hostNames[host]; cnt > 1 {
+			host = fmt.Sprintf("%s_%d", host, cnt)
+		}
+
+		vars := map[string]interface{}{
+			"azure_id":       res.Id.String(),
+			"azure_resource": res.Properties,
+		}
+		for k, path := range map[string]string{
+			"azure_location": "location",
+			"azure_vm_size":  "properties.hardwareProfile.vmSize",
+			"azure_os_type":  "properties.storageProfile.osDisk.osType",
+			"azure_tags":     "tags",
+		} {
+			if v, ok := lookupPath(res.Properties, path); ok {
+				vars[k] = v
+			}
+		}
+		hostvars[host] = vars
+
+		if rg, ok := res.Id.RootScope().(*armid.ResourceGroup); ok {
+			vars["azure_resource_group"] = rg.Name
+			addHost(ansibleGroupName("rg", strings.ToLower(rg.Name)), host)
+		}
+
+		tags := map[string]interface{}{}
+		if vmss != nil {
+			addHost(ansibleGroupName("vmss", strings.ToLower(names[0])), host)
+			for k, v := range vmssTags[strings.ToUpper(vmss.String())] {
+				tags[k] = v
+			}
+		}
+		if resTags, ok := res.Properties["tags"].(map[string]interface{}); ok {
+			for k, v := range resTags {
+				tags[k] = v
+			}
+		}
+		for k, v := range tags {
+			addHost(ansibleGroupName("tag", k, fmt.Sprint(v)), host)
+		}
+	}
+
+	var groupNames []string
+	for name, g := range groups {
+		sort.Strings(g.Hosts)
+		groupNames = append(groupNames, name)
+	}
+	sort.Strings(groupNames)
+
+	inventory := map[string]interface{}{
+		"_meta": map[string]interface{}{
+			"hostvars": hostvars,
+		},
+		"all": ansibleGroup{
+			Children: groupNames,
+		},
+	}
+	for name, g := range groups {
+		inventory[name] = g
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(inventory)
+}
@@ -0,0 +1,125 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/magodo/armid"
+	"github.com/magodo/azlist/azlist"
+	"gopkg.in/yaml.v3"
+)
+
+type crossplaneKind struct {
+	APIVersion string
+	Kind       string
+	// ParentNameFields are the forProvider fields that hold the names of the parent resources, from the top most parent.
+	ParentNameFields []string
+}
+
+// crossplaneKinds maps the (upper cased) ARM resource types to the managed resource kinds of the Upbound Azure provider.
+var crossplaneKinds = map[string]crossplaneKind{
+	"MICROSOFT.RESOURCES/RESOURCEGROUPS":                    {APIVersion: "azure.upbound.io/v1beta1", Kind: "ResourceGroup"},
+	"MICROSOFT.NETWORK/VIRTUALNETWORKS":                     {APIVersion: "network.azure.upbound.io/v1beta1", Kind: "VirtualNetwork"},
+	"MICROSOFT.NETWORK/VIRTUALNETWORKS/SUBNETS":             {APIVersion: "network.azure.upbound.io/v1beta1", Kind: "Subnet", ParentNameFields: []string{"virtualNetworkName"}},
+	"MICROSOFT.NETWORK/NETWORKSECURITYGROUPS":               {APIVersion: "network.azure.upbound.io/v1beta1", Kind: "NetworkSecurityGroup"},
+	"MICROSOFT.NETWORK/PUBLICIPADDRESSES":                   {APIVersion: "network.azure.upbound.io/v1beta1", Kind: "PublicIP"},
+	"MICROSOFT.NETWORK/NETWORKINTERFACES":                   {APIVersion: "network.azure.upbound.io/v1beta1", Kind: "NetworkInterface"},
+	"MICROSOFT.STORAGE/STORAGEACCOUNTS":                     {APIVersion: "storage.azure.upbound.io/v1beta1", Kind: "Account"},
+	"MICROSOFT.KEYVAULT/VAULTS":                             {APIVersion: "keyvault.azure.upbound.io/v1beta1", Kind: "Vault"},
+	"MICROSOFT.CONTAINERSERVICE/MANAGEDCLUSTERS":            {APIVersion: "containerservice.azure.upbound.io/v1beta1", Kind: "KubernetesCluster"},
+	"MICROSOFT.CONTAINERREGISTRY/REGISTRIES":                {APIVersion: "containerregistry.azure.upbound.io/v1beta1", Kind: "Registry"},
+	"MICROSOFT.MANAGEDIDENTITY/USERASSIGNEDIDENTITIES":      {APIVersion: "managedidentity.azure.upbound.io/v1beta1", Kind: "UserAssignedIdentity"},
+	"MICROSOFT.SQL/SERVERS":                                 {APIVersion: "sql.azure.upbound.io/v1beta1", Kind: "MSSQLServer"},
+	"MICROSOFT.WEB/SERVERFARMS":                             {APIVersion: "web.azure.upbound.io/v1beta1", Kind: "ServicePlan"},
+	"MICROSOFT.OPERATIONALINSIGHTS/WORKSPACES":              {APIVersion: "operationalinsights.azure.upbound.io/v1beta1", Kind: "Workspace"},
+	"MICROSOFT.NETWORK/PRIVATEDNSZONES":                     {APIVersion: "network.azure.upbound.io/v1beta1", Kind: "PrivateDNSZone"},
+	"MICROSOFT.NETWORK/PRIVATEDNSZONES/VIRTUALNETWORKLINKS": {APIVersion: "network.azure.upbound.io/v1beta1", Kind: "PrivateDNSZoneVirtualNetworkLink", ParentNameFields: []string{"privateDnsZoneName"}},
+}
+
+var k8sNameInvalidChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+type crossplaneManifest struct {
+	APIVersion string             `yaml:"apiVersion"`
+	Kind       string             `yaml:"kind"`
+	Metadata   crossplaneMetadata `yaml:"metadata"`
+	Spec       crossplaneSpec     `yaml:"spec"`
+}
+
+type crossplaneMetadata struct {
+	Name        string            `yaml:"name"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+type crossplaneSpec struct {
+	ManagementPolicies []string          `yaml:"managementPolicies"`
+	ForProvider        map[string]string `yaml:"forProvider"`
+}
+
+// Crossplane writes a Crossplane managed resource manifest for each resource whose type is supported by the Upbound Azure provider.
+// The manifests only observe the existing resources, which are identified by the "crossplane.io/external-name" annotation.
+// Resources of other types are emitted as comments.
+func Crossplane(w io.Writer, result *azlist.ListResult) error {
+	names := map[string]int{}
+	for _, res := range result.Resources {
+		rt := azlist.ResourceType(res.Id)
+		kind, ok := crossplaneKinds[strings.ToUpper(rt)]
+		if !ok {
+			if _, err := fmt.Fprintf(w, "# Skipping %s: no Crossplane kind known for %s\n", res.Id.String(), rt); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var externalName string
+		forProvider := map[string]string{}
+		if rg, ok := res.Id.(*armid.ResourceGroup); ok {
+			externalName = rg.Name
+		} else {
+			resNames := res.Id.Names()
+			externalName = resNames[len(resNames)-1]
+			if rg, ok := res.Id.RootScope().(*armid.ResourceGroup); ok {
+				forProvider["resourceGroupName"] = rg.Name
+			}
+			for i, field := range kind.ParentNameFields {
+				if i < len(resNames)-1 {
+					forProvider[field] = resNames[i]
+				}
+			}
+		}
+
+		name := strings.Trim(k8sNameInvalidChars.ReplaceAllString(strings.ToLower(externalName), "-"), "-")
+		if name == "" {
+			name = "resource"
+		}
+		key := kind.Kind + "/" + name
+		names[key]++
+		if cnt := names[key]; cnt > 1 {
+			name = fmt.Sprintf("%s-%d", name, cnt)
+		}
+
+		b, err := yaml.Marshal(crossplaneManifest{
+			APIVersion: kind.APIVersion,
+			Kind:       kind.Kind,
+			Metadata: crossplaneMetadata{
+				Name: name,
+				Annotations: map[string]string{
+					"crossplane.io/external-name": externalName,
+					"azlist/resource-id":          res.Id.String(),
+				},
+			},
+			Spec: crossplaneSpec{
+				ManagementPolicies: []string{"Observe"},
+				ForProvider:        forProvider,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("marshalling manifest of %s: %v", res.Id.String(), err)
+		}
+		if _, err := fmt.Fprintf(w, "---\n%s", b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
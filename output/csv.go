@@ -0,0 +1,97 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/magodo/armid"
+	"github.com/magodo/azlist/azlist"
+)
+
+// DefaultCSVColumns are the columns used by CSV when no column is specified.
+var DefaultCSVColumns = []string{"id", "type", "location", "resourceGroup"}
+
+// CSV writes the resources as CSV, with a header row followed by one row per resource.
+// Each column is a dotted path (e.g. "tags.env") into the resource body. Besides, "id", "type", "name", "resourceGroup", "subscriptionId" and "apiVersion"
+// are derived from the resource id when they are absent in the body. So are "scope" and "discoveredThrough" of the extension resources.
+// Non-string values are JSON encoded.
+func CSV(w io.Writer, result *azlist.ListResult, columns []string) error {
+	if len(columns) == 0 {
+		columns = DefaultCSVColumns
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	for _, res := range result.Resources {
+		record := make([]string, len(columns))
+		for i, col := range columns {
+			v, err := csvValue(res, col)
+			if err != nil {
+				return err
+			}
+			record[i] = v
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// resourceValue looks up the value of a dotted path from the resource body, with fallbacks for the well-known fields derived from the resource id.
+func resourceValue(res azlist.AzureResource, path string) (interface{}, bool) {
+	if path == "id" {
+		return res.Id.String(), true
+	}
+	if v, ok := lookupPath(res.Properties, path); ok {
+		return v, true
+	}
+	switch path {
+	case "type":
+		return azlist.ResourceType(res.Id), true
+	case "name":
+		return resourceName(res), true
+	case "apiVersion":
+		return res.APIVersion, true
+	case "scope":
+		if res.Scope != nil {
+			return res.Scope.String(), true
+		}
+	case "discoveredThrough":
+		if res.DiscoveredThrough != nil {
+			return res.DiscoveredThrough.String(), true
+		}
+	case "resourceGroup":
+		if rg, ok := res.Id.RootScope().(*armid.ResourceGroup); ok {
+			return rg.Name, true
+		}
+	case "subscriptionId":
+		switch root := res.Id.RootScope().(type) {
+		case *armid.ResourceGroup:
+			return root.SubscriptionId, true
+		case *armid.SubscriptionId:
+			return root.Id, true
+		}
+	}
+	return nil, false
+}
+
+// csvValue returns the string form of the resource value at the dotted path, where non-string values are JSON encoded.
+func csvValue(res azlist.AzureResource, path string) (string, error) {
+	v, ok := resourceValue(res, path)
+	if !ok || v == nil {
+		return "", nil
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("marshalling %q of %s: %v", path, res.Id.String(), err)
+	}
+	return string(b), nil
+}
@@ -0,0 +1,122 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/magodo/azlist/azlist"
+)
+
+// now and newUUID are overridable for tests.
+var (
+	now     = time.Now
+	newUUID = uuid.NewString
+)
+
+type cdxBOM struct {
+	BOMFormat    string          `json:"bomFormat"`
+	SpecVersion  string          `json:"specVersion"`
+	SerialNumber string          `json:"serialNumber"`
+	Version      int             `json:"version"`
+	Metadata     cdxMetadata     `json:"metadata"`
+	Components   []cdxComponent  `json:"components"`
+	Dependencies []cdxDependency `json:"dependencies,omitempty"`
+}
+
+type cdxMetadata struct {
+	Timestamp string   `json:"timestamp"`
+	Tools     cdxTools `json:"tools"`
+}
+
+type cdxTools struct {
+	Components []cdxComponent `json:"components"`
+}
+
+type cdxComponent struct {
+	Type       string        `json:"type"`
+	BOMRef     string        `json:"bom-ref,omitempty"`
+	Group      string        `json:"group,omitempty"`
+	Name       string        `json:"name"`
+	Properties []cdxProperty `json:"properties,omitempty"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+// CycloneDX writes the resources as a CycloneDX (v1.5) BOM document, where each resource is a "platform" component referenced by its resource id.
+// Each resource depends on its parent (or parent scope) resource, if the parent is also listed.
+func CycloneDX(w io.Writer, result *azlist.ListResult) error {
+	bom := cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + newUUID(),
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: now().UTC().Format(time.RFC3339),
+			Tools: cdxTools{
+				Components: []cdxComponent{{Type: "application", Name: "azlist"}},
+			},
+		},
+		Components: []cdxComponent{},
+	}
+
+	refs := map[string]string{}
+	for _, res := range result.Resources {
+		refs[strings.ToUpper(res.Id.String())] = res.Id.String()
+	}
+
+	for _, res := range result.Resources {
+		rt := azlist.ResourceType(res.Id)
+		comp := cdxComponent{
+			Type:   "platform",
+			BOMRef: res.Id.String(),
+			Group:  res.Id.Provider(),
+			Name:   resourceName(res),
+			Properties: []cdxProperty{
+				{Name: "azure:resourceType", Value: rt},
+			},
+		}
+		if loc, ok := res.Properties["location"].(string); ok && loc != "" {
+			comp.Properties = append(comp.Properties, cdxProperty{Name: "azure:location", Value: loc})
+		}
+		if res.APIVersion != "" {
+			comp.Properties = append(comp.Properties, cdxProperty{Name: "azure:apiVersion", Value: res.APIVersion})
+		}
+		if tags, ok := res.Properties["tags"].(map[string]interface{}); ok {
+			var keys []string
+			for k := range tags {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				v, _ := tags[k].(string)
+				comp.Properties = append(comp.Properties, cdxProperty{Name: "azure:tag:" + k, Value: v})
+			}
+		}
+		bom.Components = append(bom.Components, comp)
+
+		if parent := parentId(res); parent != nil {
+			if ref, ok := refs[strings.ToUpper(parent.String())]; ok {
+				bom.Dependencies = append(bom.Dependencies, cdxDependency{
+					Ref:       res.Id.String(),
+					DependsOn: []string{ref},
+				})
+			}
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(bom)
+}
@@ -0,0 +1,93 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/magodo/azlist/azlist"
+)
+
+// Diff writes the differences against the baseline, one resource per line prefixed by "+" (added), "-" (removed) or "~" (changed),
+// followed by the indented patch operations of the changed ones.
+func Diff(w io.Writer, diffs []azlist.ResourceDiff) error {
+	for _, d := range diffs {
+		prefix := "~"
+		switch d.Status {
+		case azlist.DiffStatusAdded:
+			prefix = "+"
+		case azlist.DiffStatusRemoved:
+			prefix = "-"
+		}
+		if _, err := fmt.Fprintf(w, "%s %s\n", prefix, d.Id); err != nil {
+			return err
+		}
+		for _, op := range d.Patch {
+			var line string
+			switch op.Op {
+			case "add":
+				line = fmt.Sprintf("%s %s: %s", op.Op, op.Path, jsonString(op.Value))
+			case "remove":
+				line = fmt.Sprintf("%s %s: %s", op.Op, op.Path, jsonString(op.OldValue))
+			default:
+				line = fmt.Sprintf("%s %s: %s -> %s", op.Op, op.Path, jsonString(op.OldValue), jsonString(op.Value))
+			}
+			if _, err := fmt.Fprintf(w, "    %s\n", line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DiffJSON writes the differences against the baseline as a JSON array.
+func DiffJSON(w io.Writer, diffs []azlist.ResourceDiff) error {
+	if diffs == nil {
+		diffs = []azlist.ResourceDiff{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(diffs)
+}
+
+func jsonString(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// DiffJSONPatch writes the RFC 6902 JSON patch documents of the changed resources (see azlist.JSONPatch), as a JSON array of objects with the "id" and the "patch".
+func DiffJSONPatch(w io.Writer, diffs []azlist.ResourceDiff) error {
+	type entry struct {
+		Id    string                   `json:"id"`
+		Patch []map[string]interface{} `json:"patch"`
+	}
+	out := []entry{}
+	for _, d := range diffs {
+		if d.Status == azlist.DiffStatusChanged {
+			out = append(out, entry{Id: d.Id, Patch: azlist.JSONPatch(d.Patch)})
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// DiffMergePatch writes the RFC 7386 JSON merge patches of the changed resources, as a JSON array of objects with the "id" and the "mergePatch".
+func DiffMergePatch(w io.Writer, diffs []azlist.ResourceDiff) error {
+	type entry struct {
+		Id         string                 `json:"id"`
+		MergePatch map[string]interface{} `json:"mergePatch"`
+	}
+	out := []entry{}
+	for _, d := range diffs {
+		if d.Status == azlist.DiffStatusChanged {
+			out = append(out, entry{Id: d.Id, MergePatch: d.MergePatch})
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
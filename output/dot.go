@@ -0,0 +1,53 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/magodo/armid"
+	"github.com/magodo/azlist/azlist"
+)
+
+// DOT writes the resources as a Graphviz DOT digraph. Each resource is a node, and there are two kinds of edges:
+//   - parent -> child (solid): between a resource and its nearest listed ancestor
+//   - manager -> managed (dashed): between a resource and the resource referenced by its "managedBy"
+func DOT(w io.Writer, result *azlist.ListResult) error {
+	var b strings.Builder
+	b.WriteString("digraph azlist {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+
+	listed := map[string]string{}
+	for _, res := range result.Resources {
+		listed[strings.ToUpper(res.Id.String())] = res.Id.String()
+	}
+
+	for _, res := range result.Resources {
+		fmt.Fprintf(&b, "  %q [label=%q];\n", res.Id.String(), resourceName(res)+"\n"+azlist.ResourceType(res.Id))
+	}
+
+	for _, res := range result.Resources {
+		for pid := parentId(res); pid != nil; pid = parentId(azlist.AzureResource{Id: pid}) {
+			if parent, ok := listed[strings.ToUpper(pid.String())]; ok {
+				fmt.Fprintf(&b, "  %q -> %q;\n", parent, res.Id.String())
+				break
+			}
+		}
+		if managedBy, ok := res.Properties["managedBy"].(string); ok && managedBy != "" {
+			manager, ok := listed[strings.ToUpper(managedBy)]
+			if !ok {
+				if _, err := armid.ParseResourceId(managedBy); err != nil {
+					continue
+				}
+				manager = managedBy
+				fmt.Fprintf(&b, "  %q [style=dashed];\n", manager)
+			}
+			fmt.Fprintf(&b, "  %q -> %q [style=dashed, label=\"managedBy\"];\n", manager, res.Id.String())
+		}
+	}
+
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
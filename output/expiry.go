@@ -0,0 +1,62 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/magodo/azlist/azlist"
+)
+
+// Expiry writes a report of the expiries of the resources (e.g. policy exemptions, Key Vault secrets and certificates), ordered by the expiry time, with the days left (negative for the expired ones).
+func Expiry(w io.Writer, result *azlist.ListResult) error {
+	type entry struct {
+		id     string
+		item   string
+		expiry time.Time
+	}
+	var entries []entry
+	for _, res := range result.Resources {
+		for _, expiry := range azlist.ResourceExpiries(res) {
+			entries = append(entries, entry{id: res.Id.String(), item: expiry.Item, expiry: expiry.Time})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].expiry.Before(entries[j].expiry)
+	})
+
+	// The rows are colored after being aligned, as the color sequences would otherwise break the alignment.
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "EXPIRY\tDAYS LEFT\tID\tITEM")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", e.expiry.UTC().Format(time.RFC3339), daysLeft(e.expiry), e.id, e.item)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	term := DetectTerminal(w)
+	for i, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if i > 0 {
+			switch days := daysLeft(entries[i-1].expiry); {
+			case days < 0:
+				line = term.colorize(line, colorRed)
+			case days < 30:
+				line = term.colorize(line, colorYellow)
+			}
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func daysLeft(t time.Time) int {
+	return int(t.Sub(now()).Hours() / 24)
+}
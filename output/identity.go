@@ -0,0 +1,86 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/magodo/azlist/azlist"
+)
+
+// IdentityMap writes the blast radius map of the user assigned identities, that is the resources referencing each identity and the role assignments granted to it.
+// Identities referenced but not listed are included, without their role assignments, as their principal ids are unknown.
+// Role assignments are only available when the "Microsoft.Authorization/roleAssignments" extension resource type is listed.
+func IdentityMap(w io.Writer, result *azlist.ListResult) error {
+	type identity struct {
+		id          string
+		principalId string
+		users       []string
+		roles       []string
+	}
+	identities := map[string]*identity{}
+	getIdentity := func(id string) *identity {
+		key := strings.ToUpper(id)
+		if _, ok := identities[key]; !ok {
+			identities[key] = &identity{id: id}
+		}
+		return identities[key]
+	}
+
+	var roleAssignments []azlist.AzureResource
+	for _, res := range result.Resources {
+		switch strings.ToUpper(azlist.ResourceType(res.Id)) {
+		case "MICROSOFT.MANAGEDIDENTITY/USERASSIGNEDIDENTITIES":
+			identity := getIdentity(res.Id.String())
+			if v, ok := lookupPath(res.Properties, "properties.principalId"); ok {
+				identity.principalId = fmt.Sprint(v)
+			}
+		case "MICROSOFT.AUTHORIZATION/ROLEASSIGNMENTS":
+			roleAssignments = append(roleAssignments, res)
+		}
+		uais, _ := lookupPath(res.Properties, "identity.userAssignedIdentities")
+		for id := range asMap(uais) {
+			identity := getIdentity(id)
+			identity.users = append(identity.users, res.Id.String())
+		}
+	}
+
+	byPrincipal := map[string]*identity{}
+	for _, identity := range identities {
+		if identity.principalId != "" {
+			byPrincipal[strings.ToLower(identity.principalId)] = identity
+		}
+	}
+	for _, ra := range roleAssignments {
+		principalId, _ := lookupPath(ra.Properties, "properties.principalId")
+		identity, ok := byPrincipal[strings.ToLower(fmt.Sprint(principalId))]
+		if !ok {
+			continue
+		}
+		scope, _ := lookupPath(ra.Properties, "properties.scope")
+		roleDefinitionId, _ := lookupPath(ra.Properties, "properties.roleDefinitionId")
+		identity.roles = append(identity.roles, fmt.Sprintf("%v at %v", roleDefinitionId, scope))
+	}
+
+	var keys []string
+	for key := range identities {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		identity := identities[key]
+		fmt.Fprintln(w, identity.id)
+		fmt.Fprintln(w, "  Used by:")
+		sort.Strings(identity.users)
+		for _, user := range identity.users {
+			fmt.Fprintf(w, "    %s\n", user)
+		}
+		fmt.Fprintln(w, "  Role assignments:")
+		sort.Strings(identity.roles)
+		for _, role := range identity.roles {
+			fmt.Fprintf(w, "    %s\n", role)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/magodo/azlist/azlist"
+)
+
+// JSON writes the whole list result as a single JSON document.
+func JSON(w io.Writer, result *azlist.ListResult) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
+}
@@ -0,0 +1,119 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/magodo/azlist/azlist"
+)
+
+const (
+	ocsfVersion = "1.1.0"
+
+	// The "Cloud Resources Inventory Info" class in the "Discovery" category.
+	ocsfCategoryUid = 5
+	ocsfClassUid    = 5023
+	// The "Collect" activity.
+	ocsfActivityId = 2
+	// The "Informational" severity.
+	ocsfSeverityId = 1
+)
+
+type ocsfEvent struct {
+	CategoryUid int            `json:"category_uid"`
+	ClassUid    int            `json:"class_uid"`
+	ActivityId  int            `json:"activity_id"`
+	TypeUid     int            `json:"type_uid"`
+	SeverityId  int            `json:"severity_id"`
+	Time        int64          `json:"time"`
+	Metadata    ocsfMetadata   `json:"metadata"`
+	Cloud       ocsfCloud      `json:"cloud"`
+	Resources   []ocsfResource `json:"resources"`
+}
+
+type ocsfMetadata struct {
+	Version string      `json:"version"`
+	Product ocsfProduct `json:"product"`
+}
+
+type ocsfProduct struct {
+	Name       string `json:"name"`
+	VendorName string `json:"vendor_name"`
+}
+
+type ocsfCloud struct {
+	Provider string       `json:"provider"`
+	Region   string       `json:"region,omitempty"`
+	Account  *ocsfAccount `json:"account,omitempty"`
+}
+
+type ocsfAccount struct {
+	Uid string `json:"uid"`
+}
+
+type ocsfResource struct {
+	Uid    string                 `json:"uid"`
+	Name   string                 `json:"name,omitempty"`
+	Type   string                 `json:"type"`
+	Region string                 `json:"region,omitempty"`
+	Group  *ocsfGroup             `json:"group,omitempty"`
+	Labels []string               `json:"labels,omitempty"`
+	Data   map[string]interface{} `json:"data,omitempty"`
+}
+
+type ocsfGroup struct {
+	Name string `json:"name"`
+}
+
+// OCSF writes one OCSF (v1.1.0) "Cloud Resources Inventory Info" event per resource, as newline delimited JSON.
+// Resource tags are rendered as "<key>:<value>" labels, and the resource body is kept as the resource data.
+func OCSF(w io.Writer, result *azlist.ListResult) error {
+	ts := now().UnixMilli()
+	enc := json.NewEncoder(w)
+	for _, res := range result.Resources {
+		region, _ := res.Properties["location"].(string)
+		resource := ocsfResource{
+			Uid:    res.Id.String(),
+			Name:   resourceName(res),
+			Type:   azlist.ResourceType(res.Id),
+			Region: region,
+			Data:   res.Properties,
+		}
+		if rg, ok := resourceValue(res, "resourceGroup"); ok {
+			resource.Group = &ocsfGroup{Name: fmt.Sprint(rg)}
+		}
+		if tags, ok := res.Properties["tags"].(map[string]interface{}); ok {
+			for k, v := range tags {
+				resource.Labels = append(resource.Labels, fmt.Sprintf("%s:%v", k, v))
+			}
+			sort.Strings(resource.Labels)
+		}
+
+		event := ocsfEvent{
+			CategoryUid: ocsfCategoryUid,
+			ClassUid:    ocsfClassUid,
+			ActivityId:  ocsfActivityId,
+			TypeUid:     ocsfClassUid*100 + ocsfActivityId,
+			SeverityId:  ocsfSeverityId,
+			Time:        ts,
+			Metadata: ocsfMetadata{
+				Version: ocsfVersion,
+				Product: ocsfProduct{Name: "azlist", VendorName: "azlist"},
+			},
+			Cloud: ocsfCloud{
+				Provider: "Azure",
+				Region:   region,
+			},
+			Resources: []ocsfResource{resource},
+		}
+		if sub, ok := resourceValue(res, "subscriptionId"); ok {
+			event.Cloud.Account = &ocsfAccount{Uid: fmt.Sprint(sub)}
+		}
+		if err := enc.Encode(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
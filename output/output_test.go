@@ -0,0 +1,216 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/magodo/armid"
+	"github.com/magodo/azlist/azlist"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+const testSubscription = "00000000-0000-0000-0000-000000000000"
+
+func testResult(t *testing.T) *azlist.ListResult {
+	bodies := []string{
+		`{"id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg1", "name": "rg1", "type": "Microsoft.Resources/resourceGroups", "location": "westus"}`,
+		`{"id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1", "name": "vnet1", "type": "Microsoft.Network/virtualNetworks", "location": "westus", "tags": {"env": "test"}, "properties": {"provisioningState": "Succeeded", "addressSpace": {"addressPrefixes": ["10.0.0.0/16"]}}}`,
+		`{"id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1/subnets/subnet1", "name": "subnet1", "type": "Microsoft.Network/virtualNetworks/subnets", "properties": {"addressPrefix": "10.0.1.0/24"}}`,
+		`{"id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg1/providers/Microsoft.Network/networkInterfaces/nic1", "name": "nic1", "type": "Microsoft.Network/networkInterfaces", "location": "westus", "properties": {"ipConfigurations": [{"name": "ipconfig1", "properties": {"privateIPAddress": "10.0.1.4", "subnet": {"id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1/subnets/subnet1"}}}]}}`,
+		`{"id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg1/providers/Microsoft.Compute/virtualMachines/vm1", "name": "vm1", "type": "Microsoft.Compute/virtualMachines", "location": "westus", "identity": {"type": "UserAssigned", "userAssignedIdentities": {"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg1/providers/Microsoft.ManagedIdentity/userAssignedIdentities/uai1": {}}}, "properties": {"hardwareProfile": {"vmSize": "Standard_B1s"}, "osProfile": {"adminUsername": "azureuser"}, "storageProfile": {"osDisk": {"osType": "Linux"}}, "networkProfile": {"networkInterfaces": [{"id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg1/providers/Microsoft.Network/networkInterfaces/nic1"}]}}}`,
+		`{"id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg1/providers/Microsoft.ManagedIdentity/userAssignedIdentities/uai1", "name": "uai1", "type": "Microsoft.ManagedIdentity/userAssignedIdentities", "location": "westus", "properties": {"principalId": "11111111-1111-1111-1111-111111111111"}}`,
+		`{"id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg1/providers/Microsoft.KeyVault/vaults/kv1/secrets/secret1", "name": "secret1", "type": "Microsoft.KeyVault/vaults/secrets", "properties": {"attributes": {"exp": 1893456000}}}`,
+		`{"id": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg1/providers/Microsoft.KeyVault/vaults/kv1/providers/Microsoft.Authorization/roleAssignments/ra1", "name": "ra1", "type": "Microsoft.Authorization/roleAssignments", "properties": {"principalId": "11111111-1111-1111-1111-111111111111", "roleDefinitionId": "/subscriptions/00000000-0000-0000-0000-000000000000/providers/Microsoft.Authorization/roleDefinitions/4633458b-17de-408a-b874-0445c86b69e6", "scope": "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg1/providers/Microsoft.KeyVault/vaults/kv1"}}`,
+	}
+	result := &azlist.ListResult{
+		Errors: []azlist.ListError{},
+	}
+	for _, body := range bodies {
+		var props map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(body), &props))
+		id, err := armid.ParseResourceId(props["id"].(string))
+		require.NoError(t, err)
+		result.Resources = append(result.Resources, azlist.AzureResource{
+			Id:             id,
+			Properties:     props,
+			APIVersion:     "2022-01-01",
+			SubscriptionId: testSubscription,
+		})
+	}
+	sort.Slice(result.Resources, func(i, j int) bool {
+		return result.Resources[i].Id.String() < result.Resources[j].Id.String()
+	})
+	return result
+}
+
+// checkGolden compares the output with the golden file in the testdata directory, or updates the golden file if -update is specified.
+func checkGolden(t *testing.T, name string, got []byte) {
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		require.NoError(t, os.WriteFile(path, got, 0644))
+		return
+	}
+	expect, err := os.ReadFile(path)
+	require.NoError(t, err, "run `go test ./output -update` to create the golden file")
+	require.Equal(t, string(expect), string(got))
+}
+
+func TestOutputGolden(t *testing.T) {
+	now = func() time.Time { return time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC) }
+	newUUID = func() string { return "00000000-0000-0000-0000-000000000001" }
+
+	cases := []struct {
+		name  string
+		write func(w io.Writer, result *azlist.ListResult) error
+	}{
+		{"json", JSON},
+		{"azapi-import", AzapiImport},
+		{"crossplane", Crossplane},
+		{"ansible", Ansible},
+		{"ssh-config", SSHConfig},
+		{"cyclonedx", CycloneDX},
+		{"csv", func(w io.Writer, result *azlist.ListResult) error { return CSV(w, result, nil) }},
+		{"ocsf", OCSF},
+		{"tree", func(w io.Writer, result *azlist.ListResult) error { return Tree(w, result, nil) }},
+		{"dot", DOT},
+		{"tf-import", TFImport},
+		{"expiry", Expiry},
+		{"identity-map", IdentityMap},
+		{"template", func(w io.Writer, result *azlist.ListResult) error {
+			tmpl, err := ParseTemplate(`{{ .Name }} {{ .Type }} {{ get .Properties "location" }}`)
+			if err != nil {
+				return err
+			}
+			return Template(w, result, tmpl)
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, c.write(&buf, testResult(t)))
+			checkGolden(t, c.name, buf.Bytes())
+		})
+	}
+}
+
+func TestSteampipeGolden(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, Steampipe(dir, testResult(t)))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		b, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		require.NoError(t, err)
+		buf.WriteString("# " + entry.Name() + "\n")
+		buf.Write(b)
+	}
+	checkGolden(t, "steampipe", buf.Bytes())
+}
+
+func TestAzapiImportNameCollisionGolden(t *testing.T) {
+	result := &azlist.ListResult{}
+	// The names are suffixed in order, where a suffixed name might be taken by a resource named so.
+	for _, id := range []string{
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/a",
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg2/providers/Microsoft.Network/virtualNetworks/a",
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg3/providers/Microsoft.Network/virtualNetworks/a_2",
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg4/providers/Microsoft.Network/virtualNetworks/a",
+	} {
+		azureId, err := armid.ParseResourceId(id)
+		require.NoError(t, err)
+		result.Resources = append(result.Resources, azlist.AzureResource{Id: azureId, APIVersion: "2022-01-01"})
+	}
+	var buf bytes.Buffer
+	require.NoError(t, AzapiImport(&buf, result))
+	checkGolden(t, "azapi-import-collision", buf.Bytes())
+}
+
+func TestDiffGolden(t *testing.T) {
+	baseline := testResult(t)
+	current := testResult(t)
+	// Remove the subnet, add a new vnet and change the tags of the existing vnet.
+	var subnet azlist.AzureResource
+	for i, res := range current.Resources {
+		if azlist.ResourceType(res.Id) == "Microsoft.Network/virtualNetworks/subnets" {
+			subnet = res
+			current.Resources = append(current.Resources[:i], current.Resources[i+1:]...)
+			break
+		}
+	}
+	require.NotNil(t, subnet.Id)
+	for _, res := range current.Resources {
+		if azlist.ResourceType(res.Id) == "Microsoft.Network/virtualNetworks" {
+			res.Properties["tags"] = map[string]interface{}{"env": "prod"}
+			res.Properties["etag"] = "volatile"
+		}
+	}
+	id, err := armid.ParseResourceId("/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet2")
+	require.NoError(t, err)
+	current.Resources = append(current.Resources, azlist.AzureResource{Id: id, Properties: map[string]interface{}{}})
+
+	diffs := azlist.DiffResources(baseline.Resources, current.Resources, true, azlist.DefaultVolatileFields)
+	var buf bytes.Buffer
+	require.NoError(t, Diff(&buf, diffs))
+	checkGolden(t, "diff", buf.Bytes())
+
+	buf.Reset()
+	require.NoError(t, DiffJSONPatch(&buf, diffs))
+	checkGolden(t, "diff-json-patch", buf.Bytes())
+
+	buf.Reset()
+	require.NoError(t, DiffMergePatch(&buf, diffs))
+	checkGolden(t, "diff-merge-patch", buf.Bytes())
+}
+
+func TestSSHConfigHostAliases(t *testing.T) {
+	result := testResult(t)
+	// The same VM name in another resource group, and in another subscription.
+	for _, id := range []string{
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg2/providers/Microsoft.Compute/virtualMachines/VM1",
+		"/subscriptions/11111111-1111-1111-1111-111111111111/resourceGroups/rg1/providers/Microsoft.Compute/virtualMachines/vm1",
+		"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg3/providers/Microsoft.Compute/virtualMachines/vm2",
+	} {
+		azureId, err := armid.ParseResourceId(id)
+		require.NoError(t, err)
+		result.Resources = append(result.Resources, azlist.AzureResource{Id: azureId, Properties: map[string]interface{}{}})
+	}
+	require.Equal(t, map[string]string{
+		"/SUBSCRIPTIONS/00000000-0000-0000-0000-000000000000/RESOURCEGROUPS/RG1/PROVIDERS/MICROSOFT.COMPUTE/VIRTUALMACHINES/VM1": "00000000-0000-0000-0000-000000000000-rg1-vm1",
+		"/SUBSCRIPTIONS/11111111-1111-1111-1111-111111111111/RESOURCEGROUPS/RG1/PROVIDERS/MICROSOFT.COMPUTE/VIRTUALMACHINES/VM1": "11111111-1111-1111-1111-111111111111-rg1-vm1",
+		"/SUBSCRIPTIONS/00000000-0000-0000-0000-000000000000/RESOURCEGROUPS/RG2/PROVIDERS/MICROSOFT.COMPUTE/VIRTUALMACHINES/VM1": "rg2-VM1",
+		"/SUBSCRIPTIONS/00000000-0000-0000-0000-000000000000/RESOURCEGROUPS/RG3/PROVIDERS/MICROSOFT.COMPUTE/VIRTUALMACHINES/VM2": "vm2",
+	}, sshHostAliases(result.Resources))
+}
+
+func TestDetectTerminalWidth(t *testing.T) {
+	require.Equal(t, Terminal{}, DetectTerminal(&bytes.Buffer{}))
+
+	// The /dev/null is a character device, which is taken as a terminal.
+	f, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Skipf("opening %s: %v", os.DevNull, err)
+	}
+	defer f.Close()
+	if fi, err := f.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		t.Skipf("%s is not a character device", os.DevNull)
+	}
+	origTerminalWidth := terminalWidth
+	defer func() { terminalWidth = origTerminalWidth }()
+	terminalWidth = func(*os.File) (int, error) { return 120, nil }
+
+	t.Setenv("COLUMNS", "")
+	require.Equal(t, 120, DetectTerminal(f).Width)
+	// The COLUMNS takes precedence.
+	t.Setenv("COLUMNS", "80")
+	require.Equal(t, 80, DetectTerminal(f).Width)
+}
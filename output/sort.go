@@ -0,0 +1,51 @@
+package output
+
+// ByteLess compares the strings (e.g. resource ids) byte-wise, which is independent of the locale, hence the default order of all the outputs.
+func ByteLess(a, b string) bool {
+	return a < b
+}
+
+// NaturalLess compares the strings in the natural order, where the digit sequences are compared by their numeric values (e.g. "vm2" < "vm10").
+// The other characters are compared byte-wise, and the strings with equal natural orders fall back to the byte-wise order, so the order is still total.
+func NaturalLess(a, b string) bool {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if isDigit(a[i]) && isDigit(b[j]) {
+			si, sj := i, j
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+			na, nb := trimZeros(a[si:i]), trimZeros(b[sj:j])
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			continue
+		}
+		if a[i] != b[j] {
+			return a[i] < b[j]
+		}
+		i++
+		j++
+	}
+	if len(a)-i != len(b)-j {
+		return len(a)-i < len(b)-j
+	}
+	return a < b
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func trimZeros(s string) string {
+	for len(s) > 1 && s[0] == '0' {
+		s = s[1:]
+	}
+	return s
+}
@@ -0,0 +1,162 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/magodo/armid"
+	"github.com/magodo/azlist/azlist"
+)
+
+// SSHConfig writes an OpenSSH client configuration snippet for the listed Linux virtual machines.
+// The endpoints are resolved from the listed network interfaces and public IP addresses, which means they should be included in the list result.
+// The public IP address is preferred over the private one. Virtual machines sitting in a virtual network that has a bastion host are annotated with it.
+// The host alias is the name of the virtual machine, unless it is shared by other virtual machines (see sshHostAliases).
+func SSHConfig(w io.Writer, result *azlist.ListResult) error {
+	byId := map[string]azlist.AzureResource{}
+	bastions := map[string]string{}
+	aliases := sshHostAliases(result.Resources)
+	for _, res := range result.Resources {
+		byId[strings.ToUpper(res.Id.String())] = res
+		if strings.EqualFold(azlist.ResourceType(res.Id), "Microsoft.Network/bastionHosts") {
+			ipConfigs, _ := lookupPath(res.Properties, "properties.ipConfigurations")
+			for _, ipConfig := range asSlice(ipConfigs) {
+				if vnetId := subnetVnet(ipConfig); vnetId != "" {
+					bastions[vnetId] = res.Id.String()
+				}
+			}
+		}
+	}
+
+	for _, res := range result.Resources {
+		if !strings.EqualFold(azlist.ResourceType(res.Id), "Microsoft.Compute/virtualMachines") {
+			continue
+		}
+		if osType, _ := lookupPath(res.Properties, "properties.storageProfile.osDisk.osType"); osType == "Windows" {
+			continue
+		}
+
+		var (
+			privateIPs []string
+			publicIPs  []string
+			bastion    string
+		)
+		nics, _ := lookupPath(res.Properties, "properties.networkProfile.networkInterfaces")
+		for _, nicRef := range asSlice(nics) {
+			nicId, _ := lookupPath(asMap(nicRef), "id")
+			nic, ok := byId[strings.ToUpper(fmt.Sprint(nicId))]
+			if !ok {
+				continue
+			}
+			ipConfigs, _ := lookupPath(nic.Properties, "properties.ipConfigurations")
+			for _, ipConfig := range asSlice(ipConfigs) {
+				if ip, ok := lookupPath(asMap(ipConfig), "properties.privateIPAddress"); ok {
+					privateIPs = append(privateIPs, fmt.Sprint(ip))
+				}
+				if pipId, ok := lookupPath(asMap(ipConfig), "properties.publicIPAddress.id"); ok {
+					if pip, ok := byId[strings.ToUpper(fmt.Sprint(pipId))]; ok {
+						if ip, ok := lookupPath(pip.Properties, "properties.ipAddress"); ok {
+							publicIPs = append(publicIPs, fmt.Sprint(ip))
+						}
+					}
+				}
+				if b, ok := bastions[subnetVnet(ipConfig)]; ok {
+					bastion = b
+				}
+			}
+		}
+
+		var hostName string
+		switch {
+		case len(publicIPs) != 0:
+			hostName = publicIPs[0]
+		case len(privateIPs) != 0:
+			hostName = privateIPs[0]
+		default:
+			if _, err := fmt.Fprintf(w, "# Skipping %s: no IP address resolved\n\n", res.Id.String()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "# %s\n", res.Id.String())
+		if len(privateIPs) != 0 {
+			fmt.Fprintf(&b, "# Private IP: %s\n", strings.Join(privateIPs, ", "))
+		}
+		if bastion != "" {
+			fmt.Fprintf(&b, "# Bastion: %s\n", bastion)
+		}
+		fmt.Fprintf(&b, "Host %s\n", aliases[strings.ToUpper(res.Id.String())])
+		fmt.Fprintf(&b, "  HostName %s\n", hostName)
+		if user, ok := lookupPath(res.Properties, "properties.osProfile.adminUsername"); ok {
+			fmt.Fprintf(&b, "  User %s\n", user)
+		}
+		b.WriteString("\n")
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sshHostAliases returns the host aliases of the virtual machines, keyed by the upper cased ids.
+// The alias is the virtual machine name, or "<resource group>-<name>" if the name is shared by other virtual machines,
+// or "<subscription>-<resource group>-<name>" if even that is shared.
+func sshHostAliases(rl []azlist.AzureResource) map[string]string {
+	type vm struct {
+		key        string
+		candidates []string
+	}
+	var vms []vm
+	for _, res := range rl {
+		if !strings.EqualFold(azlist.ResourceType(res.Id), "Microsoft.Compute/virtualMachines") {
+			continue
+		}
+		name := res.Id.Names()[0]
+		var sub, rg string
+		if root, ok := res.Id.RootScope().(*armid.ResourceGroup); ok {
+			sub, rg = root.SubscriptionId, root.Name
+		}
+		vms = append(vms, vm{
+			key:        strings.ToUpper(res.Id.String()),
+			candidates: []string{name, rg + "-" + name, sub + "-" + rg + "-" + name},
+		})
+	}
+
+	aliases := map[string]string{}
+	for level := 0; level < 3; level++ {
+		// The aliases taken at the previous levels count as well, e.g. a virtual machine named "rg1-vm1".
+		count := map[string]int{}
+		for _, vm := range vms {
+			if alias, ok := aliases[vm.key]; ok {
+				count[strings.ToLower(alias)]++
+			} else {
+				count[strings.ToLower(vm.candidates[level])]++
+			}
+		}
+		for _, vm := range vms {
+			if _, ok := aliases[vm.key]; ok {
+				continue
+			}
+			if count[strings.ToLower(vm.candidates[level])] == 1 || level == 2 {
+				aliases[vm.key] = vm.candidates[level]
+			}
+		}
+	}
+	return aliases
+}
+
+// subnetVnet returns the upper cased virtual network id of the subnet referenced by an IP configuration.
+func subnetVnet(ipConfig interface{}) string {
+	subnetId, ok := lookupPath(asMap(ipConfig), "properties.subnet.id")
+	if !ok {
+		return ""
+	}
+	id, err := armid.ParseResourceId(fmt.Sprint(subnetId))
+	if err != nil || id.Parent() == nil {
+		return ""
+	}
+	return strings.ToUpper(id.Parent().String())
+}
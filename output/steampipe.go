@@ -0,0 +1,115 @@
+package output
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/magodo/azlist/azlist"
+)
+
+// steampipeTables maps the (upper cased) ARM resource types to the table names of the Steampipe Azure plugin.
+var steampipeTables = map[string]string{
+	"MICROSOFT.RESOURCES/RESOURCEGROUPS":               "azure_resource_group",
+	"MICROSOFT.COMPUTE/VIRTUALMACHINES":                "azure_compute_virtual_machine",
+	"MICROSOFT.COMPUTE/VIRTUALMACHINESCALESETS":        "azure_compute_virtual_machine_scale_set",
+	"MICROSOFT.COMPUTE/DISKS":                          "azure_compute_disk",
+	"MICROSOFT.COMPUTE/SNAPSHOTS":                      "azure_compute_snapshot",
+	"MICROSOFT.COMPUTE/IMAGES":                         "azure_compute_image",
+	"MICROSOFT.NETWORK/VIRTUALNETWORKS":                "azure_virtual_network",
+	"MICROSOFT.NETWORK/VIRTUALNETWORKS/SUBNETS":        "azure_subnet",
+	"MICROSOFT.NETWORK/NETWORKSECURITYGROUPS":          "azure_network_security_group",
+	"MICROSOFT.NETWORK/NETWORKINTERFACES":              "azure_network_interface",
+	"MICROSOFT.NETWORK/PUBLICIPADDRESSES":              "azure_public_ip",
+	"MICROSOFT.NETWORK/APPLICATIONGATEWAYS":            "azure_application_gateway",
+	"MICROSOFT.NETWORK/LOADBALANCERS":                  "azure_lb",
+	"MICROSOFT.NETWORK/ROUTETABLES":                    "azure_route_table",
+	"MICROSOFT.NETWORK/FIREWALLS":                      "azure_firewall",
+	"MICROSOFT.STORAGE/STORAGEACCOUNTS":                "azure_storage_account",
+	"MICROSOFT.KEYVAULT/VAULTS":                        "azure_key_vault",
+	"MICROSOFT.CONTAINERSERVICE/MANAGEDCLUSTERS":       "azure_kubernetes_cluster",
+	"MICROSOFT.CONTAINERREGISTRY/REGISTRIES":           "azure_container_registry",
+	"MICROSOFT.SQL/SERVERS":                            "azure_sql_server",
+	"MICROSOFT.SQL/SERVERS/DATABASES":                  "azure_sql_database",
+	"MICROSOFT.WEB/SITES":                              "azure_app_service_web_app",
+	"MICROSOFT.WEB/SERVERFARMS":                        "azure_app_service_plan",
+	"MICROSOFT.DOCUMENTDB/DATABASEACCOUNTS":            "azure_cosmosdb_account",
+	"MICROSOFT.OPERATIONALINSIGHTS/WORKSPACES":         "azure_log_analytics_workspace",
+	"MICROSOFT.MANAGEDIDENTITY/USERASSIGNEDIDENTITIES": "azure_user_assigned_identity",
+}
+
+var steampipeColumns = []string{"name", "id", "type", "provisioning_state", "etag", "region", "resource_group", "subscription_id", "tags", "sku", "kind", "properties"}
+
+var (
+	snakeCaseBoundary = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+	snakeCaseInvalid  = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+func snakeCase(s string) string {
+	s = snakeCaseBoundary.ReplaceAllString(s, "${1}_${2}")
+	return strings.Trim(snakeCaseInvalid.ReplaceAllString(strings.ToLower(s), "_"), "_")
+}
+
+// steampipeTable returns the Steampipe table name of the resource type. Types unknown to the Azure plugin are named after the resource type,
+// e.g. "azure_microsoft_foo_bars" for "Microsoft.Foo/bars".
+func steampipeTable(rt string) string {
+	if table, ok := steampipeTables[strings.ToUpper(rt)]; ok {
+		return table
+	}
+	return "azure_" + snakeCase(rt)
+}
+
+// Steampipe writes one CSV file per Steampipe Azure plugin table into the directory, which can then be queried via the Steampipe CSV plugin.
+// Each file contains the common columns of the Azure plugin tables, with the rest of the resource body kept in the "properties" column.
+func Steampipe(dir string, result *azlist.ListResult) error {
+	tables := map[string][][]string{}
+	for _, res := range result.Resources {
+		table := steampipeTable(azlist.ResourceType(res.Id))
+		var record []string
+		for _, col := range []string{"name", "id", "type", "properties.provisioningState", "etag", "location", "resourceGroup", "subscriptionId", "tags", "sku", "kind", "properties"} {
+			v, err := csvValue(res, col)
+			if err != nil {
+				return err
+			}
+			record = append(record, v)
+		}
+		tables[table] = append(tables[table], record)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	var names []string
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := writeCSVFile(filepath.Join(dir, name+".csv"), steampipeColumns, tables[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeCSVFile(path string, header []string, records [][]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(f)
+	if err := cw.Write(header); err != nil {
+		f.Close()
+		return err
+	}
+	if err := cw.WriteAll(records); err != nil {
+		f.Close()
+		return fmt.Errorf("writing %s: %v", path, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,69 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/template"
+
+	"github.com/magodo/azlist/azlist"
+)
+
+// TemplateData is the data that the template is executed with for each resource.
+type TemplateData struct {
+	Id         string
+	Type       string
+	Name       string
+	Location   string
+	APIVersion string
+	// Scope and DiscoveredThrough are only set for the extension resources (see azlist.AzureResource).
+	Scope             string
+	DiscoveredThrough string
+	Properties        map[string]interface{}
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"get": func(props map[string]interface{}, path string) interface{} {
+		v, _ := lookupPath(props, path)
+		return v
+	},
+}
+
+// ParseTemplate parses a Go text/template used by Template. Besides the builtin functions, there are:
+//   - json: JSON encodes a value
+//   - get: looks up a dotted path from the resource body, e.g. {{ get .Properties "tags.env" }}
+func ParseTemplate(text string) (*template.Template, error) {
+	return template.New("resource").Funcs(templateFuncs).Parse(text)
+}
+
+// Template executes the template for each resource, each followed by a new line.
+func Template(w io.Writer, result *azlist.ListResult, tmpl *template.Template) error {
+	for _, res := range result.Resources {
+		location, _ := res.Properties["location"].(string)
+		data := TemplateData{
+			Id:         res.Id.String(),
+			Type:       azlist.ResourceType(res.Id),
+			Name:       resourceName(res),
+			Location:   location,
+			APIVersion: res.APIVersion,
+			Properties: res.Properties,
+		}
+		if res.Scope != nil {
+			data.Scope = res.Scope.String()
+		}
+		if res.DiscoveredThrough != nil {
+			data.DiscoveredThrough = res.DiscoveredThrough.String()
+		}
+		if err := tmpl.Execute(w, data); err != nil {
+			return fmt.Errorf("executing template for %s: %v", res.Id.String(), err)
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
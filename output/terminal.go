@@ -0,0 +1,91 @@
+package output
+
+import (
+	"io"
+	"os"
+	"strconv"
+
+	"golang.org/x/term"
+)
+
+// Terminal describes the capabilities of the terminal that an output is written to.
+// The output writers shall only emit colors, or other terminal specific sequences, when the capabilities allow, so that the redirected output is always clean.
+type Terminal struct {
+	// TTY tells whether the output is a terminal.
+	TTY bool
+	// Color tells whether colors are allowed, i.e. the output is a terminal, NO_COLOR is not set and TERM is not "dumb".
+	Color bool
+	// Width is the number of columns of the terminal, read from the COLUMNS environment variable, otherwise the terminal itself. Zero means unknown.
+	Width int
+}
+
+// terminalWidth returns the number of columns of the terminal file, which is replaced in the tests.
+var terminalWidth = func(f *os.File) (int, error) {
+	width, _, err := term.GetSize(int(f.Fd()))
+	return width, err
+}
+
+// DetectTerminal detects the terminal capabilities of the writer. Writers other than a terminal *os.File have no capability.
+func DetectTerminal(w io.Writer) Terminal {
+	f, ok := w.(*os.File)
+	if !ok {
+		return Terminal{}
+	}
+	fi, err := f.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return Terminal{}
+	}
+	t := Terminal{TTY: true}
+	if _, ok := os.LookupEnv("NO_COLOR"); !ok && os.Getenv("TERM") != "dumb" {
+		t.Color = true
+	}
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		t.Width = n
+	} else if n, err := terminalWidth(f); err == nil && n > 0 {
+		t.Width = n
+	}
+	return t
+}
+
+const (
+	colorBold   = "1"
+	colorDim    = "2"
+	colorRed    = "31"
+	colorYellow = "33"
+)
+
+// colorize wraps s with the SGR color code, if colors are allowed.
+func (t Terminal) colorize(s, code string) string {
+	if !t.Color || s == "" {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+// Truncate truncates s to the terminal width, if known.
+func (t Terminal) Truncate(s string) string {
+	r := []rune(s)
+	if t.Width == 0 || len(r) <= t.Width {
+		return s
+	}
+	return string(r[:t.Width-1]) + "…"
+}
+
+// StatusLine writes a one-line status. On a terminal the previous status line is overwritten, otherwise each status takes its own line.
+func StatusLine(w io.Writer, t Terminal, s string) error {
+	if !t.TTY {
+		_, err := io.WriteString(w, s+"\n")
+		return err
+	}
+	_, err := io.WriteString(w, "\r\x1b[K"+t.Truncate(s))
+	return err
+}
+
+// ClearStatusLine clears the status line written by StatusLine, if any, so that it doesn't mix with the following output.
+func ClearStatusLine(w io.Writer, t Terminal) error {
+	if !t.TTY {
+		return nil
+	}
+	_, err := io.WriteString(w, "\r\x1b[K")
+	return err
+}
@@ -0,0 +1,164 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/magodo/armid"
+	"github.com/magodo/azlist/azlist"
+)
+
+var tfNameInvalidChars = regexp.MustCompile(`[^a-z0-9_-]`)
+
+// tfNamer allocates unique Terraform resource names, which are derived from the resource names.
+type tfNamer map[string]int
+
+func (n tfNamer) name(tfType string, id armid.ResourceId) string {
+	var name string
+	if names := id.Names(); len(names) != 0 {
+		name = names[len(names)-1]
+	} else if rg, ok := id.(*armid.ResourceGroup); ok {
+		name = rg.Name
+	}
+	name = tfNameInvalidChars.ReplaceAllString(strings.ToLower(name), "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') || name[0] == '-' {
+		name = "res_" + name
+	}
+	key := tfType + "." + name
+	if n[key] == 0 {
+		n[key] = 1
+		return name
+	}
+	// The suffixed name might have been taken by a resource named so, e.g. "a", "a_2", "a".
+	for {
+		n[key]++
+		suffixed := fmt.Sprintf("%s_%d", name, n[key])
+		if n[tfType+"."+suffixed] == 0 {
+			n[tfType+"."+suffixed] = 1
+			return suffixed
+		}
+	}
+}
+
+func writeImportBlock(w io.Writer, id, to string) error {
+	_, err := fmt.Fprintf(w, "import {\n  id = %q\n  to = %s\n}\n\n", id, to)
+	return err
+}
+
+// AzapiImport writes a Terraform import block for each resource, targeting an azapi_resource with the api-version that azlist used to list it.
+// Resources whose api-version is unknown are emitted as comments.
+func AzapiImport(w io.Writer, result *azlist.ListResult) error {
+	namer := tfNamer{}
+	for _, res := range result.Resources {
+		if err := writeAzapiImportBlock(w, namer, res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeAzapiImportBlock(w io.Writer, namer tfNamer, res azlist.AzureResource) error {
+	if res.APIVersion == "" {
+		_, err := fmt.Fprintf(w, "# Skipping %s: no api-version known\n\n", res.Id.String())
+		return err
+	}
+	to := "azapi_resource." + namer.name("azapi_resource", res.Id)
+	return writeImportBlock(w, res.Id.String()+"?api-version="+res.APIVersion, to)
+}
+
+// azurermTypes maps the (upper cased) ARM resource types to the AzureRM provider resource types, where the import id is the resource id.
+var azurermTypes = map[string]string{
+	"MICROSOFT.RESOURCES/RESOURCEGROUPS":                       "azurerm_resource_group",
+	"MICROSOFT.NETWORK/VIRTUALNETWORKS":                        "azurerm_virtual_network",
+	"MICROSOFT.NETWORK/VIRTUALNETWORKS/SUBNETS":                "azurerm_subnet",
+	"MICROSOFT.NETWORK/VIRTUALNETWORKS/VIRTUALNETWORKPEERINGS": "azurerm_virtual_network_peering",
+	"MICROSOFT.NETWORK/NETWORKSECURITYGROUPS":                  "azurerm_network_security_group",
+	"MICROSOFT.NETWORK/NETWORKSECURITYGROUPS/SECURITYRULES":    "azurerm_network_security_rule",
+	"MICROSOFT.NETWORK/PUBLICIPADDRESSES":                      "azurerm_public_ip",
+	"MICROSOFT.NETWORK/NETWORKINTERFACES":                      "azurerm_network_interface",
+	"MICROSOFT.NETWORK/ROUTETABLES":                            "azurerm_route_table",
+	"MICROSOFT.NETWORK/ROUTETABLES/ROUTES":                     "azurerm_route",
+	"MICROSOFT.NETWORK/LOADBALANCERS":                          "azurerm_lb",
+	"MICROSOFT.NETWORK/APPLICATIONGATEWAYS":                    "azurerm_application_gateway",
+	"MICROSOFT.NETWORK/PRIVATEENDPOINTS":                       "azurerm_private_endpoint",
+	"MICROSOFT.NETWORK/PRIVATEDNSZONES":                        "azurerm_private_dns_zone",
+	"MICROSOFT.NETWORK/PRIVATEDNSZONES/VIRTUALNETWORKLINKS":    "azurerm_private_dns_zone_virtual_network_link",
+	"MICROSOFT.NETWORK/DNSZONES":                               "azurerm_dns_zone",
+	"MICROSOFT.NETWORK/BASTIONHOSTS":                           "azurerm_bastion_host",
+	"MICROSOFT.NETWORK/NATGATEWAYS":                            "azurerm_nat_gateway",
+	"MICROSOFT.STORAGE/STORAGEACCOUNTS":                        "azurerm_storage_account",
+	"MICROSOFT.KEYVAULT/VAULTS":                                "azurerm_key_vault",
+	"MICROSOFT.CONTAINERSERVICE/MANAGEDCLUSTERS":               "azurerm_kubernetes_cluster",
+	"MICROSOFT.CONTAINERSERVICE/MANAGEDCLUSTERS/AGENTPOOLS":    "azurerm_kubernetes_cluster_node_pool",
+	"MICROSOFT.CONTAINERREGISTRY/REGISTRIES":                   "azurerm_container_registry",
+	"MICROSOFT.MANAGEDIDENTITY/USERASSIGNEDIDENTITIES":         "azurerm_user_assigned_identity",
+	"MICROSOFT.COMPUTE/DISKS":                                  "azurerm_managed_disk",
+	"MICROSOFT.COMPUTE/AVAILABILITYSETS":                       "azurerm_availability_set",
+	"MICROSOFT.SQL/SERVERS":                                    "azurerm_mssql_server",
+	"MICROSOFT.SQL/SERVERS/DATABASES":                          "azurerm_mssql_database",
+	"MICROSOFT.WEB/SERVERFARMS":                                "azurerm_service_plan",
+	"MICROSOFT.OPERATIONALINSIGHTS/WORKSPACES":                 "azurerm_log_analytics_workspace",
+	"MICROSOFT.INSIGHTS/COMPONENTS":                            "azurerm_application_insights",
+	"MICROSOFT.DOCUMENTDB/DATABASEACCOUNTS":                    "azurerm_cosmosdb_account",
+	"MICROSOFT.CACHE/REDIS":                                    "azurerm_redis_cache",
+	"MICROSOFT.EVENTHUB/NAMESPACES":                            "azurerm_eventhub_namespace",
+	"MICROSOFT.SERVICEBUS/NAMESPACES":                          "azurerm_servicebus_namespace",
+}
+
+// azurermType returns the AzureRM provider resource type of the resource, or empty string if there is none.
+func azurermType(res azlist.AzureResource) string {
+	rt := strings.ToUpper(azlist.ResourceType(res.Id))
+	if t, ok := azurermTypes[rt]; ok {
+		return t
+	}
+
+	// Some ARM resource types are split into several AzureRM resource types by the OS.
+	var osTypePath, suffix string
+	switch rt {
+	case "MICROSOFT.COMPUTE/VIRTUALMACHINES":
+		osTypePath, suffix = "properties.storageProfile.osDisk.osType", "_virtual_machine"
+	case "MICROSOFT.COMPUTE/VIRTUALMACHINESCALESETS":
+		osTypePath, suffix = "properties.virtualMachineProfile.storageProfile.osDisk.osType", "_virtual_machine_scale_set"
+	case "MICROSOFT.WEB/SITES":
+		kind, _ := res.Properties["kind"].(string)
+		kind = strings.ToLower(kind)
+		os := "windows"
+		if strings.Contains(kind, "linux") {
+			os = "linux"
+		}
+		if strings.Contains(kind, "functionapp") {
+			return "azurerm_" + os + "_function_app"
+		}
+		return "azurerm_" + os + "_web_app"
+	default:
+		return ""
+	}
+	osType, _ := lookupPath(res.Properties, osTypePath)
+	switch osType {
+	case "Linux":
+		return "azurerm_linux" + suffix
+	case "Windows":
+		return "azurerm_windows" + suffix
+	}
+	return ""
+}
+
+// TFImport writes a Terraform import block for each resource. Resources supported by the AzureRM provider are imported as the corresponding AzureRM resources,
+// others are imported as azapi_resource (see AzapiImport).
+func TFImport(w io.Writer, result *azlist.ListResult) error {
+	namer := tfNamer{}
+	for _, res := range result.Resources {
+		if tfType := azurermType(res); tfType != "" {
+			if err := writeImportBlock(w, res.Id.String(), tfType+"."+namer.name(tfType, res.Id)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := writeAzapiImportBlock(w, namer, res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
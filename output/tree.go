@@ -0,0 +1,100 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/magodo/armid"
+	"github.com/magodo/azlist/azlist"
+)
+
+type treeNode struct {
+	key      string
+	label    string
+	children []*treeNode
+}
+
+// Tree writes the resources as an indented tree, following the resource hierarchy: root scopes (e.g. resource groups) -> resources -> child resources.
+// Each resource is put under its nearest listed ancestor, or its root scope if none of its ancestors is listed.
+// The siblings are ordered by their ids with the less function, which defaults to ByteLess.
+func Tree(w io.Writer, result *azlist.ListResult, less func(a, b string) bool) error {
+	if less == nil {
+		less = ByteLess
+	}
+	term := DetectTerminal(w)
+	nodes := map[string]*treeNode{}
+	for _, res := range result.Resources {
+		key := strings.ToUpper(res.Id.String())
+		label := fmt.Sprintf("%s %s", resourceName(res), term.colorize("("+azlist.ResourceType(res.Id)+")", colorDim))
+		if _, ok := res.Id.(armid.RootScope); ok {
+			label = term.colorize(res.Id.String(), colorBold)
+		}
+		nodes[key] = &treeNode{key: key, label: label}
+	}
+
+	roots := map[string]*treeNode{}
+	for _, res := range result.Resources {
+		node := nodes[strings.ToUpper(res.Id.String())]
+		if _, ok := res.Id.(armid.RootScope); ok {
+			roots[node.key] = node
+			continue
+		}
+		var parent *treeNode
+		for pid := parentId(res); pid != nil; pid = parentId(azlist.AzureResource{Id: pid}) {
+			if p, ok := nodes[strings.ToUpper(pid.String())]; ok {
+				parent = p
+				break
+			}
+		}
+		if parent == nil {
+			root := res.Id.RootScope()
+			key := strings.ToUpper(root.String())
+			if _, ok := nodes[key]; !ok {
+				nodes[key] = &treeNode{key: key, label: term.colorize(root.String(), colorBold)}
+			}
+			parent = nodes[key]
+			roots[key] = parent
+		}
+		parent.children = append(parent.children, node)
+	}
+
+	var rootList []*treeNode
+	for _, root := range roots {
+		rootList = append(rootList, root)
+	}
+	sortTreeNodes(rootList, less)
+	for _, root := range rootList {
+		if _, err := fmt.Fprintln(w, root.label); err != nil {
+			return err
+		}
+		if err := writeTreeChildren(w, root, "", less); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortTreeNodes(nodes []*treeNode, less func(a, b string) bool) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return less(nodes[i].key, nodes[j].key)
+	})
+}
+
+func writeTreeChildren(w io.Writer, node *treeNode, prefix string, less func(a, b string) bool) error {
+	sortTreeNodes(node.children, less)
+	for i, child := range node.children {
+		branch, indent := "├── ", "│   "
+		if i == len(node.children)-1 {
+			branch, indent = "└── ", "    "
+		}
+		if _, err := fmt.Fprintln(w, prefix+branch+child.label); err != nil {
+			return err
+		}
+		if err := writeTreeChildren(w, child, prefix+indent, less); err != nil {
+			return err
+		}
+	}
+	return nil
+}
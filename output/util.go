@@ -0,0 +1,58 @@
+package output
+
+import (
+	"strings"
+
+	"github.com/magodo/armid"
+	"github.com/magodo/azlist/azlist"
+)
+
+// lookupPath looks up the value of a dotted path (e.g. "properties.hardwareProfile.vmSize") from the resource body.
+func lookupPath(props map[string]interface{}, path string) (interface{}, bool) {
+	var v interface{} = props
+	for _, seg := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[seg]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func asMap(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func asSlice(v interface{}) []interface{} {
+	l, _ := v.([]interface{})
+	return l
+}
+
+// resourceName returns the name of the resource, which is the last segment of its id.
+func resourceName(res azlist.AzureResource) string {
+	switch id := res.Id.(type) {
+	case *armid.ResourceGroup:
+		return id.Name
+	case *armid.SubscriptionId:
+		return id.Id
+	case *armid.ManagementGroup:
+		return id.Name
+	}
+	names := res.Id.Names()
+	if len(names) == 0 {
+		return ""
+	}
+	return names[len(names)-1]
+}
+
+// parentId returns the id of the parent resource if any, otherwise the parent scope.
+func parentId(res azlist.AzureResource) armid.ResourceId {
+	if parent := res.Id.Parent(); parent != nil {
+		return parent
+	}
+	return res.Id.ParentScope()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// readPredicate returns the predicate argument, which is read from stdin if it is "-", e.g. to pipe a saved predicate file.
+func readPredicate(arg string) (string, error) {
+	if arg != "-" {
+		return arg, nil
+	}
+	b, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("reading the predicate from stdin: %v", err)
+	}
+	predicate := strings.TrimSpace(string(b))
+	if predicate == "" {
+		return "", fmt.Errorf("empty predicate read from stdin")
+	}
+	return predicate, nil
+}
+
+// parseVars parses the --var, each in form of "<name>=<value>".
+// As the flag values are split by commas, a value without "=" is appended to the previous one, so that e.g. "regions='eastus','westus'" is kept as is.
+func parseVars(values []string) (map[string]string, error) {
+	out := map[string]string{}
+	var last string
+	for _, v := range values {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok {
+			if last == "" {
+				return nil, fmt.Errorf(`malformed --var %q, expect "<name>=<value>"`, v)
+			}
+			out[last] += "," + v
+			continue
+		}
+		if !predicateVarName.MatchString(name) {
+			return nil, fmt.Errorf(`malformed --var %q, the name must be a letter or underscore followed by letters, digits or underscores`, v)
+		}
+		out[name] = value
+		last = name
+	}
+	return out, nil
+}
+
+var (
+	predicateVarName    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	predicateVarPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+)
+
+// expandPredicate substitutes each "${name}" in the predicate with the value of the variable. It is an error if any variable is undefined.
+// The values are substituted as is, so that the string values shall be quoted in the predicate, e.g. "tags.env =~ '${env}'".
+func expandPredicate(predicate string, vars map[string]string) (string, error) {
+	undefined := map[string]bool{}
+	out := predicateVarPattern.ReplaceAllStringFunc(predicate, func(s string) string {
+		name := predicateVarPattern.FindStringSubmatch(s)[1]
+		v, ok := vars[name]
+		if !ok {
+			undefined[name] = true
+		}
+		return v
+	})
+	if len(undefined) != 0 {
+		var names []string
+		for name := range undefined {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("undefined predicate variables (set by --var): %s", strings.Join(names, ", "))
+	}
+	return out, nil
+}
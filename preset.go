@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/magodo/azlist/azlist"
+)
+
+type preset struct {
+	table                    string
+	predicate                string
+	authorizationScopeFilter string
+	recursive                bool
+}
+
+// presets are the builtin listings, which set the ARG table, predicate and authorization scope filter.
+var presets = map[string]preset{
+	// Policy exemptions at the subscription, its resource groups and resources, as well as the management groups above.
+	"policy-exemptions": {
+		table:                    "PolicyResources",
+		predicate:                `type =~ "microsoft.authorization/policyexemptions"`,
+		authorizationScopeFilter: "AtScopeAboveAndBelow",
+	},
+	// Resources having certificates or secrets, whose expiries are reported by the "expiry" output.
+	// Key Vault secrets and keys are child resources, hence listed recursively.
+	"certificates": {
+		predicate: `type in~ ("microsoft.keyvault/vaults", "microsoft.network/applicationgateways", "microsoft.web/certificates", "microsoft.certificateregistration/certificateorders", "microsoft.app/managedenvironments", "microsoft.apimanagement/service")`,
+		recursive: true,
+	},
+}
+
+func presetNames() string {
+	var names []string
+	for name := range presets {
+		names = append(names, fmt.Sprintf("%q", name))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// filterExpiring returns the resources that have any expiry within the duration from now, including the expired ones.
+func filterExpiring(rl []azlist.AzureResource, within time.Duration) []azlist.AzureResource {
+	deadline := time.Now().Add(within)
+	var out []azlist.AzureResource
+	for _, res := range rl {
+		for _, expiry := range azlist.ResourceExpiries(res) {
+			if expiry.Time.Before(deadline) {
+				out = append(out, res)
+				break
+			}
+		}
+	}
+	return out
+}
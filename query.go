@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jmespath/go-jmespath"
+	"github.com/magodo/azlist/azlist"
+)
+
+// applyQuery applies the JMESPath query to each resource body, where the result of the query determines the new body:
+//   - object: replaces the body
+//   - null or false: the resource is filtered out
+//   - true: the body is kept as is
+//   - others: replaces the body as {"result": <value>}
+func applyQuery(rl []azlist.AzureResource, query *jmespath.JMESPath) ([]azlist.AzureResource, error) {
+	var out []azlist.AzureResource
+	for _, res := range rl {
+		v, err := query.Search(res.Properties)
+		if err != nil {
+			return nil, fmt.Errorf("querying the body of %s: %v", res.Id.String(), err)
+		}
+		switch v := v.(type) {
+		case nil:
+			continue
+		case bool:
+			if !v {
+				continue
+			}
+		case map[string]interface{}:
+			res.Properties = v
+		default:
+			res.Properties = map[string]interface{}{"result": v}
+		}
+		out = append(out, res)
+	}
+	return out, nil
+}
+
+// selectPaths projects each resource body to only the dotted paths (e.g. "properties.hardwareProfile.vmSize"), keeping their nesting.
+// Paths absent in a body are skipped.
+func selectPaths(rl []azlist.AzureResource, paths []string) []azlist.AzureResource {
+	out := make([]azlist.AzureResource, 0, len(rl))
+	for _, res := range rl {
+		props := map[string]interface{}{}
+		for _, path := range paths {
+			segs := strings.Split(path, ".")
+			v, ok := lookupSegs(res.Properties, segs)
+			if !ok {
+				continue
+			}
+			m := props
+			for _, seg := range segs[:len(segs)-1] {
+				child, ok := m[seg].(map[string]interface{})
+				if !ok {
+					child = map[string]interface{}{}
+					m[seg] = child
+				}
+				m = child
+			}
+			m[segs[len(segs)-1]] = v
+		}
+		res.Properties = props
+		out = append(out, res)
+	}
+	return out
+}
+
+func lookupSegs(props map[string]interface{}, segs []string) (interface{}, bool) {
+	var v interface{} = props
+	for _, seg := range segs {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[seg]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
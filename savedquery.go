@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// savedQuery is a named predicate together with the global options to run it, which is saved in the query directory (see savedQueryDir).
+type savedQuery struct {
+	Predicate string `json:"predicate"`
+	// Flags are the global options explicitly set when saving, keyed by the flag names.
+	Flags map[string][]string `json:"flags,omitempty"`
+}
+
+var savedQueryName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// savedQueryDir returns the directory of the saved queries, which is "$AZLIST_QUERY_DIR" if set (e.g. a directory shared by a team), otherwise "azlist/queries" in the user config directory.
+func savedQueryDir() (string, error) {
+	if dir := os.Getenv("AZLIST_QUERY_DIR"); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "azlist", "queries"), nil
+}
+
+func savedQueryPath(name string) (string, error) {
+	if !savedQueryName.MatchString(name) {
+		return "", fmt.Errorf("invalid query name %q, which must start with a letter or digit, followed by letters, digits, dots, underscores or hyphens", name)
+	}
+	dir, err := savedQueryDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// saveQuery saves the query of the name, which fails if the name exists unless overwrite is set.
+func saveQuery(name string, q savedQuery, overwrite bool) (string, error) {
+	path, err := savedQueryPath(name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err == nil && !overwrite {
+		return "", fmt.Errorf("query %q already exists, use --force to overwrite it", name)
+	}
+	b, err := json.MarshalIndent(q, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func loadQuery(name string) (*savedQuery, error) {
+	path, err := savedQueryPath(name)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("query %q not found in %s", name, filepath.Dir(path))
+		}
+		return nil, err
+	}
+	var q savedQuery
+	if err := json.Unmarshal(b, &q); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	return &q, nil
+}
+
+// listQueries returns the saved queries keyed by their names, together with the names in order.
+func listQueries() (map[string]*savedQuery, []string, error) {
+	dir, err := savedQueryDir()
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+	queries := map[string]*savedQuery{}
+	var names []string
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		if entry.IsDir() || name == entry.Name() || !savedQueryName.MatchString(name) {
+			continue
+		}
+		q, err := loadQuery(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		queries[name] = q
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return queries, names, nil
+}
+
+// rootFlags returns the global flags keyed by each of their names (including the aliases), together with the root context.
+func rootFlags(ctx *cli.Context) (map[string]cli.Flag, *cli.Context) {
+	root := rootContext(ctx)
+	flags := map[string]cli.Flag{}
+	for _, f := range root.App.Flags {
+		for _, name := range f.Names() {
+			flags[name] = f
+		}
+	}
+	return flags, root
+}
+
+// explicitFlags returns the values of the global flags that are explicitly set in the command line, keyed by the flag names.
+// The ones set by the environment variables are not included, as they belong to the environment rather than the query.
+func explicitFlags(ctx *cli.Context) map[string][]string {
+	flags, root := rootFlags(ctx)
+	out := map[string][]string{}
+	for _, name := range root.LocalFlagNames() {
+		f, ok := flags[name]
+		if !ok {
+			continue
+		}
+		name := f.Names()[0]
+		if name == "help" || name == "version" {
+			continue
+		}
+		switch v := flagValue(root, name).(type) {
+		case []string:
+			out[name] = v
+		default:
+			out[name] = []string{fmt.Sprint(v)}
+		}
+	}
+	return out
+}
+
+// applyFlags sets the global flags by the values, except the ones explicitly set in the command line or by the environment variables, which take precedence.
+func applyFlags(ctx *cli.Context, values map[string][]string) error {
+	flags, root := rootFlags(ctx)
+	set := map[string]bool{}
+	for _, name := range root.LocalFlagNames() {
+		if f, ok := flags[name]; ok {
+			set[f.Names()[0]] = true
+		}
+	}
+	for _, f := range root.App.Flags {
+		// The IsSet of a flag tells whether it is set by the environment variables, while the command line ones are covered above.
+		if f.IsSet() {
+			set[f.Names()[0]] = true
+		}
+	}
+	for name, vs := range values {
+		if _, ok := flags[name]; !ok {
+			return fmt.Errorf("unknown option %q", name)
+		}
+		if set[name] {
+			continue
+		}
+		for _, v := range vs {
+			if err := root.Set(name, v); err != nil {
+				return fmt.Errorf("setting option %q to %q: %v", name, v, err)
+			}
+		}
+	}
+	return nil
+}
+
+// contextWithArgs returns a child context of the context, whose arguments are the args.
+func contextWithArgs(ctx *cli.Context, args ...string) (*cli.Context, error) {
+	set := flag.NewFlagSet(ctx.Command.Name, flag.ContinueOnError)
+	if err := set.Parse(append([]string{"--"}, args...)); err != nil {
+		return nil, err
+	}
+	return cli.NewContext(ctx.App, set, ctx), nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/magodo/azlist/azlist"
+)
+
+// schemaShow prints the api-versions and the child resource types of the resource type in the ARM schema tree, as well as the api-version that is picked.
+func schemaShow(w io.Writer, tree azlist.ARMSchemaTree, rt string, apiVersions map[string]string, strategy azlist.VersionStrategy) error {
+	rt = strings.TrimSuffix(rt, "/")
+	entry, ok := tree[strings.ToUpper(rt)]
+	if !ok {
+		return fmt.Errorf("resource type %q is not found in the ARM schema", rt)
+	}
+
+	fmt.Fprintf(w, "Resource type: %s\n", rt)
+	fmt.Fprintf(w, "API versions: %s\n", strings.Join(entry.Versions, ", "))
+
+	picked, reason := "", ""
+	for k, v := range apiVersions {
+		if strings.EqualFold(k, rt) {
+			picked, reason = v, "pinned by --api-version"
+		}
+	}
+	if picked == "" {
+		if strategy == "" {
+			strategy = azlist.VersionStrategyLatest
+		}
+		picked, reason = strategy.Pick(entry.Versions), fmt.Sprintf("by the %q strategy", strategy)
+	}
+	fmt.Fprintf(w, "Picked API version: %s (%s)\n", picked, reason)
+
+	segs := strings.Split(strings.ToUpper(rt), "/")
+	if len(segs) > 2 {
+		prt := strings.Join(segs[:len(segs)-1], "/")
+		if _, ok := tree[prt]; ok {
+			fmt.Fprintf(w, "Parent resource type: %s\n", prt)
+		} else {
+			fmt.Fprintf(w, "Parent resource type: %s (not in the ARM schema, hence this type is never listed recursively)\n", prt)
+		}
+	}
+
+	var children []string
+	for name := range entry.Children {
+		children = append(children, name)
+	}
+	sort.Strings(children)
+	fmt.Fprintln(w, "Child resource types:")
+	if len(children) == 0 {
+		fmt.Fprintln(w, "\t(none)")
+	}
+	for _, name := range children {
+		child := entry.Children[name]
+		fmt.Fprintf(w, "\t%s (%d api-versions, latest %s)\n", name, len(child.Versions), azlist.VersionStrategyLatest.Pick(child.Versions))
+	}
+	return nil
+}
+
+// schemaCacheFile returns the path of the cached ARM schema file (written by "schema update"), or empty if the user cache directory is unknown.
+func schemaCacheFile() string {
+	path, err := azlist.ARMSchemaCachePath()
+	if err != nil {
+		return ""
+	}
+	return path
+}
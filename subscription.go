@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/magodo/azlist/azlist"
+)
+
+// parseSubscriptionFilter parses the --subscription-filter, each in form of "name:<regex>", "tag:<key>=<value>" or "offer:<quota id prefix>",
+// together with the --exclude-subscription.
+func parseSubscriptionFilter(filters, excludes []string) (azlist.SubscriptionFilter, error) {
+	filter := azlist.SubscriptionFilter{Exclude: excludes}
+	for _, f := range filters {
+		kind, value, ok := strings.Cut(f, ":")
+		if !ok || value == "" {
+			return filter, fmt.Errorf(`malformed --subscription-filter %q, expect one of "name:<regex>", "tag:<key>=<value>", "offer:<quota id prefix>"`, f)
+		}
+		switch strings.ToLower(kind) {
+		case "name":
+			if filter.Name != nil {
+				return filter, fmt.Errorf("--subscription-filter %q: the name filter is specified more than once", f)
+			}
+			p, err := regexp.Compile(value)
+			if err != nil {
+				return filter, fmt.Errorf("--subscription-filter %q: %v", f, err)
+			}
+			filter.Name = p
+		case "tag":
+			tags, err := parseKeyValues([]string{value})
+			if err != nil {
+				return filter, fmt.Errorf("--subscription-filter %q: %v", f, err)
+			}
+			if filter.Tags == nil {
+				filter.Tags = map[string]string{}
+			}
+			for k, v := range tags {
+				filter.Tags[k] = v
+			}
+		case "offer":
+			filter.OfferTypes = append(filter.OfferTypes, value)
+		default:
+			return filter, fmt.Errorf(`malformed --subscription-filter %q, unknown kind %q, expect one of "name", "tag", "offer"`, f, kind)
+		}
+	}
+	return filter, nil
+}
+
+// filterSubscriptions returns the ids of the subscriptions accessible in the tenant that are selected by the filter.
+// If candidates is not nil, only the subscriptions among them are considered.
+func filterSubscriptions(ctx context.Context, opt azlist.Option, filter azlist.SubscriptionFilter, candidates []string) ([]string, error) {
+	opt.AllSubscriptions = true
+	opt.LiveSchema = false
+	l, err := azlist.NewLister(opt)
+	if err != nil {
+		return nil, err
+	}
+	subs, err := l.Subscriptions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing subscriptions: %v", err)
+	}
+	var cset map[string]bool
+	if candidates != nil {
+		cset = map[string]bool{}
+		for _, id := range candidates {
+			cset[strings.ToLower(id)] = true
+		}
+	}
+	var ids []string
+	for _, sub := range subs {
+		if cset != nil && !cset[strings.ToLower(sub.Id)] {
+			continue
+		}
+		if filter.Match(sub) {
+			ids = append(ids, sub.Id)
+		}
+	}
+	return ids, nil
+}
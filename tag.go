@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/magodo/azlist/azlist"
+)
+
+// parseKeyValues parses a list of "key=value" strings into a map.
+func parseKeyValues(kvs []string) (map[string]string, error) {
+	m := map[string]string{}
+	for _, kv := range kvs {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf(`malformed %q, expect "key=value"`, kv)
+		}
+		m[k] = v
+	}
+	return m, nil
+}
+
+// tagApply merges the tags into the existing tags of each listed resource, by the lister of its subscription. Resources whose tags are already up to date are skipped.
+func tagApply(ctx context.Context, w io.Writer, ls listers, result *azlist.ListResult, tags map[string]string, dryRun bool) error {
+	return bulkRun(w, "tag", ls[0].Parallelism, result.Resources, func(res azlist.AzureResource) (string, error) {
+		oldTags, _ := res.Properties["tags"].(map[string]interface{})
+		changed := false
+		for k, v := range tags {
+			if ov, ok := oldTags[k]; !ok || fmt.Sprint(ov) != v {
+				changed = true
+				break
+			}
+		}
+		if !changed {
+			return "", nil
+		}
+		if dryRun {
+			return fmt.Sprintf("Would tag %s", res.Id.String()), nil
+		}
+		l, err := ls.listerOf(res)
+		if err != nil {
+			return "", err
+		}
+		if err := l.Client.MergeTags(ctx, res.Id, tags); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Tagged %s", res.Id.String()), nil
+	})
+}
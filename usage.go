@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/magodo/azlist/azlist"
+)
+
+// The documented throttling limits, see:
+// - https://learn.microsoft.com/en-us/azure/azure-resource-manager/management/request-limits-and-throttling
+// - https://learn.microsoft.com/en-us/azure/governance/resource-graph/concepts/guidance-for-throttled-requests
+const (
+	// ARM read requests are throttled by a token bucket per subscription (and region), which holds 250 tokens and refills 25 tokens per second.
+	armReadBucketSize       = 250
+	armReadRefillsPerSecond = 25
+	// ARG queries are throttled per user, by 15 queries per 5 seconds window.
+	argQueriesPerWindow = 15
+	argWindow           = 5 * time.Second
+)
+
+// printUsageEstimate prints the API requests sent by a run, compared to the throttling limits.
+// If interval is non-zero, it also warns if running azlist at this interval would exceed the limits.
+func printUsageEstimate(w io.Writer, stats azlist.RequestStats, interval time.Duration) {
+	fmt.Fprintf(w, "API usage: %d ARG queries, %d ARM requests\n", stats.ARGRequests, stats.ARMRequests)
+	if stats.ARMRequests > armReadBucketSize {
+		fmt.Fprintf(w, "\tThe ARM requests exceed the throttling burst limit (%d), at least %s is spent on waiting for the throttling bucket to refill\n",
+			armReadBucketSize, time.Duration(stats.ARMRequests-armReadBucketSize)*time.Second/armReadRefillsPerSecond)
+	}
+	if stats.ARGRequests > argQueriesPerWindow {
+		fmt.Fprintf(w, "\tThe ARG queries exceed the throttling limit (%d per %s)\n", argQueriesPerWindow, argWindow)
+	}
+	if interval <= 0 {
+		return
+	}
+
+	runsPerHour := float64(time.Hour) / float64(interval)
+	armPerHour := float64(stats.ARMRequests) * runsPerHour
+	argPerHour := float64(stats.ARGRequests) * runsPerHour
+	armLimitPerHour := float64(armReadRefillsPerSecond * 3600)
+	argLimitPerHour := float64(argQueriesPerWindow) * float64(time.Hour) / float64(argWindow)
+	fmt.Fprintf(w, "Running every %s: ~%.0f ARM requests/hour (limit %.0f), ~%.0f ARG queries/hour (limit %.0f)\n", interval, armPerHour, armLimitPerHour, argPerHour, argLimitPerHour)
+	if armPerHour > armLimitPerHour {
+		fmt.Fprintf(w, "\tWarning: the ARM requests would exceed the throttling limit, consider a longer interval than %s\n", time.Duration(float64(stats.ARMRequests)/armReadRefillsPerSecond*float64(time.Second)).Round(time.Second))
+	}
+	if argPerHour > argLimitPerHour {
+		fmt.Fprintf(w, "\tWarning: the ARG queries would exceed the throttling limit, consider a longer interval than %s\n", time.Duration(float64(stats.ARGRequests)/argQueriesPerWindow*float64(argWindow)).Round(time.Second))
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resourcegraph/armresourcegraph"
+	"github.com/magodo/azlist/azlist"
+)
+
+// validateOption validates the list option built from the flags, before any network call is made.
+// The combinations that are valid but likely unintended are reported as warnings to w.
+func validateOption(w io.Writer, opt *azlist.Option) error {
+	if opt.ARGAuthorizationScopeFilter != "" {
+		var (
+			found  bool
+			values []string
+		)
+		for _, v := range armresourcegraph.PossibleAuthorizationScopeFilterValues() {
+			values = append(values, fmt.Sprintf("%q", v))
+			if strings.EqualFold(string(v), string(opt.ARGAuthorizationScopeFilter)) {
+				opt.ARGAuthorizationScopeFilter = v
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("invalid --authorization-scope-filter %q: possible values are %s", opt.ARGAuthorizationScopeFilter, strings.Join(values, ", "))
+		}
+	}
+
+	if opt.VersionStrategy != "" {
+		if err := opt.VersionStrategy.Validate(); err != nil {
+			return fmt.Errorf("invalid --api-version-strategy: %v", err)
+		}
+	}
+
+	if opt.LiveSchema && opt.AllSubscriptions {
+		return fmt.Errorf("--live-schema can't be used with --all-subscriptions, as the resource provider metadata is subscription scoped")
+	}
+
+	if opt.Parallelism < 0 {
+		return fmt.Errorf("invalid --parallelism %d: must not be negative, where 0 means the number of CPUs", opt.Parallelism)
+	}
+	if opt.Top < 0 {
+		return fmt.Errorf("invalid --top %d: must not be negative", opt.Top)
+	}
+	if opt.ARGPageSize < 0 || opt.ARGPageSize > 1000 {
+		return fmt.Errorf("invalid --arg-page-size %d: must be between 1 and 1000", opt.ARGPageSize)
+	}
+	if opt.ARGSkip < 0 {
+		return fmt.Errorf("invalid --arg-skip %d: must not be negative", opt.ARGSkip)
+	}
+	if opt.MaxDepth < 0 {
+		return fmt.Errorf("invalid --max-depth %d: must not be negative", opt.MaxDepth)
+	}
+	if opt.MaxDepth > 0 && !opt.Recursive {
+		fmt.Fprintf(w, "Warning: --max-depth has no effect without --recursive\n")
+	}
+	if (len(opt.RecurseOnlyTypes) != 0 || len(opt.SkipChildTypes) != 0) && !opt.Recursive {
+		fmt.Fprintf(w, "Warning: --recurse-only-type and --skip-child-type have no effect without --recursive\n")
+	}
+	if len(opt.ExpandMetadataTypes) != 0 && !opt.Recursive && len(opt.ExtensionResourceTypes) == 0 {
+		fmt.Fprintf(w, "Warning: --expand-metadata has no effect without --recursive or --extension\n")
+	}
+	if opt.SkipManagedResourceGroups && !opt.Recursive {
+		fmt.Fprintf(w, "Warning: --skip-managed-rg has no effect without --recursive\n")
+	}
+
+	tree, err := azlist.LoadARMSchemaTree(opt.ARMSchema, opt.ARMSchemaOverlay, opt.ARMSchemaCacheFile)
+	if err != nil {
+		return fmt.Errorf("invalid ARM schema: %v", err)
+	}
+	for rt, version := range opt.APIVersions {
+		if version == "" {
+			return fmt.Errorf("invalid --api-version for %q: empty api-version", rt)
+		}
+		if _, ok := tree[strings.ToUpper(rt)]; !ok && !opt.LiveSchema {
+			fmt.Fprintf(w, "Warning: --api-version pins %q, which is not a resource type known by the ARM schema\n", rt)
+		}
+	}
+
+	for rt := range opt.ListQueryParameters {
+		if _, ok := tree[strings.ToUpper(rt)]; !ok && !opt.LiveSchema {
+			fmt.Fprintf(w, "Warning: --list-query-param sets %q, which is not a resource type known by the ARM schema\n", rt)
+		}
+	}
+	for _, rt := range opt.ExpandMetadataTypes {
+		if _, ok := tree[strings.ToUpper(rt)]; !ok && !opt.LiveSchema && !azlist.IsBuiltinExtensionType(rt) {
+			fmt.Fprintf(w, "Warning: --expand-metadata sets %q, which is not a resource type known by the ARM schema\n", rt)
+		}
+	}
+	for rt := range opt.ListURLTemplates {
+		if _, ok := tree[strings.ToUpper(rt)]; !ok && !opt.LiveSchema {
+			fmt.Fprintf(w, "Warning: --list-url-template sets %q, which is not a resource type known by the ARM schema\n", rt)
+		}
+	}
+
+	if len(opt.ExtensionResourceTypes) != 0 {
+		for _, ext := range opt.ExtensionResourceTypes {
+			if _, ok := tree[strings.ToUpper(ext.Type)]; !ok && !azlist.IsBuiltinExtensionType(ext.Type) {
+				if opt.LiveSchema {
+					fmt.Fprintf(w, "Warning: --extension %q is not a resource type known by the embedded ARM schema, it relies on the live schema\n", ext.Type)
+					continue
+				}
+				return fmt.Errorf("invalid --extension %q: not a resource type known by the ARM schema, it shall be in form of <provider>/<type> (e.g. %q)", ext.Type, "Microsoft.Authorization/roleAssignments")
+			}
+		}
+		if opt.Top != 0 {
+			fmt.Fprintf(w, "Warning: --extension resources are not listed once --top is reached by the other resources\n")
+		}
+	}
+
+	if opt.ARGTable != "" && !strings.EqualFold(opt.ARGTable, "Resources") && opt.Recursive {
+		fmt.Fprintf(w, "Warning: --recursive with table %q only lists the child resources of the records that have a resource id known by the ARM schema, the others are listed as they are\n", opt.ARGTable)
+	}
+	return nil
+}
+
+// validateTypePatterns validates the --include-type and --exclude-type patterns.
+func validateTypePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(strings.ToLower(pattern), ""); err != nil {
+			return fmt.Errorf("invalid type pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
@@ -1,6 +1,12 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/magodo/azlist/azlist"
+	"github.com/urfave/cli/v2"
+)
 
 // To set this from outside, use go build -ldflags "-X 'main.version=$(VERSION)'"
 var version string = "dev"
@@ -16,3 +22,27 @@ func getVersion() string {
 	}
 	return version
 }
+
+// printVersion prints the version, together with the provenance of the ARM schema.
+func printVersion(ctx *cli.Context) {
+	fmt.Fprintf(ctx.App.Writer, "%s version %s\n", ctx.App.Name, ctx.App.Version)
+	info, err := azlist.SchemaInfo(schemaCacheFile())
+	if err != nil {
+		fmt.Fprintf(ctx.App.Writer, "schema: %v\n", err)
+		return
+	}
+	details := []string{
+		fmt.Sprintf("%d resource types", info.ResourceTypes),
+		"latest api-version " + info.LatestAPIVersion,
+	}
+	if info.Path != "" {
+		details = append(details, "path "+info.Path)
+	}
+	if info.Commit != "" {
+		details = append(details, "commit "+info.Commit)
+	}
+	if info.GeneratedAt != "" {
+		details = append(details, "generated at "+info.GeneratedAt)
+	}
+	fmt.Fprintf(ctx.App.Writer, "schema: %s (%s)\n", info.Source, strings.Join(details, ", "))
+}